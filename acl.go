@@ -0,0 +1,450 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// Privileges defined in RFC 3744 section 3. PrivilegeAll contains all other
+// privileges, PrivilegeWrite contains PrivilegeWriteProperties,
+// PrivilegeWriteContent, PrivilegeBind and PrivilegeUnbind.
+var (
+	PrivilegeAll                         = internal.PrivilegeAll
+	PrivilegeRead                        = internal.PrivilegeRead
+	PrivilegeWrite                       = internal.PrivilegeWrite
+	PrivilegeWriteProperties             = internal.PrivilegeWriteProperties
+	PrivilegeWriteContent                = internal.PrivilegeWriteContent
+	PrivilegeUnlock                      = internal.PrivilegeUnlock
+	PrivilegeReadACL                     = internal.PrivilegeReadACL
+	PrivilegeReadCurrentUserPrivilegeSet = internal.PrivilegeReadCurrentUserPrivilegeSet
+	PrivilegeWriteACL                    = internal.PrivilegeWriteACL
+	PrivilegeBind                        = internal.PrivilegeBind
+	PrivilegeUnbind                      = internal.PrivilegeUnbind
+)
+
+// Authorizer decides whether a principal is allowed to perform an operation.
+// Handlers consult it before each request, with the privileges listed in RFC
+// 3744 appendix B.
+type Authorizer interface {
+	// Authorize returns nil if the principal holds the privilege on the
+	// resource, and a 403 error otherwise. An empty principal designates an
+	// unauthenticated user. For aggregate privileges, all contained
+	// privileges must be held.
+	Authorize(ctx context.Context, principal, name string, priv xml.Name) error
+}
+
+// ACLStore is an optional interface which can be implemented by an
+// Authorizer to expose access control lists via the DAV:owner and DAV:acl
+// properties, and to let clients edit them with the ACL method.
+type ACLStore interface {
+	// Owner returns the principal owning a resource, or an empty string if
+	// none.
+	Owner(ctx context.Context, name string) (string, error)
+	// ACL returns the access control entries applying to a resource,
+	// including inherited ones.
+	ACL(ctx context.Context, name string) ([]ACE, error)
+	// SetACL replaces the access control entries of a resource. Inherited
+	// entries are ignored.
+	SetACL(ctx context.Context, name string, aces []ACE) error
+}
+
+// Special principals, as defined in RFC 3744 section 5.5.1.
+const (
+	PrincipalAll             = "DAV:all"
+	PrincipalAuthenticated   = "DAV:authenticated"
+	PrincipalUnauthenticated = "DAV:unauthenticated"
+	// PrincipalOwner matches the owner of the resource.
+	PrincipalOwner = "DAV:owner"
+)
+
+// ACE is an access control entry, as defined in RFC 3744 section 5.5.
+type ACE struct {
+	// Principal is the path of a principal, or one of PrincipalAll,
+	// PrincipalAuthenticated, PrincipalUnauthenticated and PrincipalOwner.
+	Principal string
+	// Deny is true if the privileges are denied rather than granted.
+	Deny       bool
+	Privileges []xml.Name
+	// Protected entries can't be removed with the ACL method.
+	Protected bool
+	// Inherited is the path of the resource the entry is inherited from, or
+	// an empty string.
+	Inherited string
+}
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the path of the
+// authenticated user's principal. Handler uses it to authorize requests.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the path of the authenticated user's
+// principal, or an empty string if the user isn't authenticated.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalContextKey{}).(string)
+	return principal
+}
+
+// MemAuthorizer is an Authorizer and ACLStore which keeps access control
+// lists in memory. Resources inherit the entries of their ancestors, which
+// are evaluated after their own entries, and the owner of their closest
+// ancestor when they don't have one. The first entry matching the
+// principal and a privilege decides whether the privilege is granted. It is
+// safe for concurrent use.
+type MemAuthorizer struct {
+	mutex  sync.RWMutex
+	acls   map[string][]ACE
+	owners map[string]string
+}
+
+var (
+	_ Authorizer = (*MemAuthorizer)(nil)
+	_ ACLStore   = (*MemAuthorizer)(nil)
+)
+
+// NewMemAuthorizer creates a new MemAuthorizer which denies everything until
+// entries are added with SetACL.
+func NewMemAuthorizer() *MemAuthorizer {
+	return &MemAuthorizer{
+		acls:   make(map[string][]ACE),
+		owners: make(map[string]string),
+	}
+}
+
+// acl returns the entries applying to a resource. The caller must hold the
+// lock.
+func (a *MemAuthorizer) acl(name string) []ACE {
+	name = path.Clean(name)
+
+	var l []ACE
+	for p := name; ; p = path.Dir(p) {
+		for _, ace := range a.acls[p] {
+			if p != name {
+				ace.Inherited = p
+			}
+			l = append(l, ace)
+		}
+		if p == "/" {
+			break
+		}
+	}
+	return l
+}
+
+// owner returns the owner of a resource. The caller must hold the lock.
+func (a *MemAuthorizer) owner(name string) string {
+	for p := path.Clean(name); ; p = path.Dir(p) {
+		if owner, ok := a.owners[p]; ok || p == "/" {
+			return owner
+		}
+	}
+}
+
+func (a *MemAuthorizer) Authorize(ctx context.Context, principal, name string, priv xml.Name) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	acl := a.acl(name)
+	owner := a.owner(name)
+	for _, leaf := range leafPrivileges(priv) {
+		if !isGranted(acl, principal, owner, leaf) {
+			return internal.HTTPErrorf(http.StatusForbidden, "webdav: %v privilege required on %q", priv.Local, name)
+		}
+	}
+	return nil
+}
+
+func leafPrivileges(priv xml.Name) []xml.Name {
+	var l []xml.Name
+	for _, p := range []xml.Name{
+		PrivilegeRead,
+		PrivilegeWriteProperties,
+		PrivilegeWriteContent,
+		PrivilegeBind,
+		PrivilegeUnbind,
+		PrivilegeUnlock,
+		PrivilegeReadACL,
+		PrivilegeReadCurrentUserPrivilegeSet,
+		PrivilegeWriteACL,
+	} {
+		if internal.PrivilegeContains(priv, p) {
+			l = append(l, p)
+		}
+	}
+	if len(l) == 0 {
+		// Unknown privilege, only granted by an entry listing it
+		l = append(l, priv)
+	}
+	return l
+}
+
+func isGranted(acl []ACE, principal, owner string, priv xml.Name) bool {
+	for _, ace := range acl {
+		if !aceMatches(&ace, principal, owner) {
+			continue
+		}
+		for _, p := range ace.Privileges {
+			if internal.PrivilegeContains(p, priv) {
+				return !ace.Deny
+			}
+		}
+	}
+	return false
+}
+
+func aceMatches(ace *ACE, principal, owner string) bool {
+	switch ace.Principal {
+	case PrincipalAll:
+		return true
+	case PrincipalAuthenticated:
+		return principal != ""
+	case PrincipalUnauthenticated:
+		return principal == ""
+	case PrincipalOwner:
+		return principal != "" && principal == owner
+	default:
+		return principal != "" && path.Clean(principal) == path.Clean(ace.Principal)
+	}
+}
+
+func (a *MemAuthorizer) Owner(ctx context.Context, name string) (string, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.owner(name), nil
+}
+
+// SetOwner sets the principal owning a resource. An empty principal removes
+// the owner.
+func (a *MemAuthorizer) SetOwner(ctx context.Context, name, principal string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if principal == "" {
+		delete(a.owners, path.Clean(name))
+	} else {
+		a.owners[path.Clean(name)] = principal
+	}
+	return nil
+}
+
+func (a *MemAuthorizer) ACL(ctx context.Context, name string) ([]ACE, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.acl(name), nil
+}
+
+func (a *MemAuthorizer) SetACL(ctx context.Context, name string, aces []ACE) error {
+	var l []ACE
+	for _, ace := range aces {
+		if ace.Inherited == "" {
+			l = append(l, ace)
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(l) == 0 {
+		delete(a.acls, path.Clean(name))
+	} else {
+		a.acls[path.Clean(name)] = l
+	}
+	return nil
+}
+
+func encodeACE(ace *ACE) *internal.ACE {
+	var principal internal.ACEPrincipal
+	switch ace.Principal {
+	case PrincipalAll:
+		principal.All = &struct{}{}
+	case PrincipalAuthenticated:
+		principal.Authenticated = &struct{}{}
+	case PrincipalUnauthenticated:
+		principal.Unauthenticated = &struct{}{}
+	case PrincipalOwner:
+		principal.Property = &internal.ACEProperty{
+			Raw: []internal.RawXMLValue{*internal.NewRawXMLElement(internal.OwnerName, nil, nil)},
+		}
+	default:
+		principal.Href = &internal.Href{Path: ace.Principal}
+	}
+
+	privs := make([]internal.Privilege, len(ace.Privileges))
+	for i, priv := range ace.Privileges {
+		privs[i] = *internal.NewPrivilege(priv)
+	}
+
+	out := internal.ACE{Principal: &principal}
+	if ace.Deny {
+		out.Deny = &internal.ACEPrivileges{Privileges: privs}
+	} else {
+		out.Grant = &internal.ACEPrivileges{Privileges: privs}
+	}
+	if ace.Protected {
+		out.Protected = &struct{}{}
+	}
+	if ace.Inherited != "" {
+		out.Inherited = &internal.ACEInherited{Href: internal.Href{Path: ace.Inherited}}
+	}
+	return &out
+}
+
+func decodeACE(in *internal.ACE) (*ACE, error) {
+	if in.Invert != nil {
+		return nil, internal.NewConditionError(http.StatusForbidden, xml.Name{internal.Namespace, "no-invert"})
+	}
+	if in.Inherited != nil {
+		return nil, internal.NewConditionError(http.StatusForbidden, xml.Name{internal.Namespace, "no-inherited-ace-conflict"})
+	}
+	if in.Principal == nil || (in.Grant == nil) == (in.Deny == nil) {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "webdav: malformed ACE")
+	}
+
+	var ace ACE
+	p := in.Principal
+	switch {
+	case p.Href != nil:
+		ace.Principal = p.Href.Path
+	case p.All != nil:
+		ace.Principal = PrincipalAll
+	case p.Authenticated != nil:
+		ace.Principal = PrincipalAuthenticated
+	case p.Unauthenticated != nil:
+		ace.Principal = PrincipalUnauthenticated
+	case p.Property != nil && len(p.Property.Raw) == 1:
+		if name, ok := p.Property.Raw[0].XMLName(); ok && name == internal.OwnerName {
+			ace.Principal = PrincipalOwner
+		}
+	}
+	if ace.Principal == "" {
+		return nil, internal.NewConditionError(http.StatusForbidden, xml.Name{internal.Namespace, "recognized-principal"})
+	}
+
+	privs := in.Grant
+	if in.Deny != nil {
+		privs = in.Deny
+		ace.Deny = true
+	}
+	for _, priv := range privs.Privileges {
+		name, ok := priv.Name()
+		if !ok || !internal.IsSupportedPrivilege(name) {
+			return nil, internal.NewConditionError(http.StatusForbidden, xml.Name{internal.Namespace, "not-supported-privilege"})
+		}
+		ace.Privileges = append(ace.Privileges, name)
+	}
+
+	return &ace, nil
+}
+
+// authorize checks whether the current user is allowed to perform a request.
+func (b *backend) authorize(r *http.Request) error {
+	if b.Authorizer == nil {
+		return nil
+	}
+	exists := func(name string) (bool, error) {
+		_, err := b.FileSystem.Stat(r.Context(), name)
+		if internal.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return internal.AuthorizeRequest(r, b.Authorizer, PrincipalFromContext(r.Context()), exists)
+}
+
+// authorizeRead checks whether the current user can read a resource listed
+// in a response, in addition to the request-URI.
+func (b *backend) authorizeRead(ctx context.Context, name string) error {
+	if b.Authorizer == nil {
+		return nil
+	}
+	return b.Authorizer.Authorize(ctx, PrincipalFromContext(ctx), name, PrivilegeRead)
+}
+
+// aclProps adds RFC 3744 properties to a PROPFIND response.
+func (b *backend) aclProps(ctx context.Context, props map[xml.Name]internal.PropFindFunc, name string) {
+	principal := PrincipalFromContext(ctx)
+	authorized := func(priv xml.Name) error {
+		return b.Authorizer.Authorize(ctx, principal, name, priv)
+	}
+
+	props[internal.CurrentUserPrivilegeSetName] = func(*internal.RawXMLValue) (interface{}, error) {
+		if err := authorized(PrivilegeReadCurrentUserPrivilegeSet); err != nil {
+			return nil, err
+		}
+		privs, err := internal.CurrentUserPrivileges(ctx, b.Authorizer, principal, name)
+		if err != nil {
+			return nil, err
+		}
+		return internal.NewCurrentUserPrivilegeSet(privs...), nil
+	}
+
+	store, ok := b.Authorizer.(ACLStore)
+	if !ok {
+		return
+	}
+
+	props[internal.OwnerName] = func(*internal.RawXMLValue) (interface{}, error) {
+		owner, err := store.Owner(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		out := &internal.ACLOwner{}
+		if owner != "" {
+			out.Href = &internal.Href{Path: owner}
+		}
+		return out, nil
+	}
+	props[internal.ACLName] = func(*internal.RawXMLValue) (interface{}, error) {
+		if err := authorized(PrivilegeReadACL); err != nil {
+			return nil, err
+		}
+		aces, err := store.ACL(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		acl := &internal.ACL{ACEs: make([]internal.ACE, len(aces))}
+		for i := range aces {
+			acl.ACEs[i] = *encodeACE(&aces[i])
+		}
+		return acl, nil
+	}
+}
+
+func (b *backend) ACL(r *http.Request, acl *internal.ACL) error {
+	store, ok := b.Authorizer.(ACLStore)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
+	}
+	if _, err := b.FileSystem.Stat(r.Context(), r.URL.Path); err != nil {
+		return err
+	}
+
+	cur, err := store.ACL(r.Context(), r.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	// Protected entries are kept, clients only edit the other ones
+	var aces []ACE
+	for _, ace := range cur {
+		if ace.Protected && ace.Inherited == "" {
+			aces = append(aces, ace)
+		}
+	}
+	for i := range acl.ACEs {
+		ace, err := decodeACE(&acl.ACEs[i])
+		if err != nil {
+			return err
+		}
+		aces = append(aces, *ace)
+	}
+
+	return store.SetACL(r.Context(), r.URL.Path, aces)
+}
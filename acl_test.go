@@ -0,0 +1,161 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMemAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	az := NewMemAuthorizer()
+	az.SetOwner(ctx, "/alice", "/principals/alice")
+	az.SetACL(ctx, "/", []ACE{
+		{Principal: PrincipalAll, Privileges: []xml.Name{PrivilegeRead}},
+	})
+	az.SetACL(ctx, "/alice", []ACE{
+		{Principal: "/principals/bob", Deny: true, Privileges: []xml.Name{PrivilegeRead}},
+		{Principal: PrincipalOwner, Privileges: []xml.Name{PrivilegeAll}},
+		{Principal: PrincipalAuthenticated, Privileges: []xml.Name{PrivilegeWriteContent}},
+	})
+
+	tests := []struct {
+		principal, name string
+		priv            xml.Name
+		want            bool
+	}{
+		{"", "/", PrivilegeRead, true},
+		{"", "/", PrivilegeWrite, false},
+		{"", "/alice/file.txt", PrivilegeRead, true},
+		{"/principals/bob", "/alice", PrivilegeRead, false},
+		{"/principals/bob", "/alice/file.txt", PrivilegeRead, false},
+		{"/principals/bob", "/alice", PrivilegeWriteContent, true},
+		{"/principals/bob", "/alice", PrivilegeWrite, false},
+		{"/principals/alice", "/alice", PrivilegeAll, true},
+		{"/principals/alice", "/alice/file.txt", PrivilegeWrite, true},
+	}
+	for _, tc := range tests {
+		err := az.Authorize(ctx, tc.principal, tc.name, tc.priv)
+		if got := err == nil; got != tc.want {
+			t.Errorf("Authorize(%q, %q, %v) = %v, want granted = %v", tc.principal, tc.name, tc.priv.Local, err, tc.want)
+		} else if err != nil && !isHTTPStatus(err, http.StatusForbidden) {
+			t.Errorf("Authorize(%q, %q, %v) = %v, want 403", tc.principal, tc.name, tc.priv.Local, err)
+		}
+	}
+
+	aces, err := az.ACL(ctx, "/alice/file.txt")
+	if err != nil || len(aces) != 4 || aces[0].Inherited != "/alice" || aces[3].Inherited != "/" {
+		t.Errorf("ACL() = %+v, %v", aces, err)
+	}
+}
+
+const aclGrantAllRead = `<?xml version="1.0" encoding="utf-8" ?>
+<D:acl xmlns:D="DAV:">
+  <D:ace>
+    <D:principal><D:all/></D:principal>
+    <D:grant>
+      <D:privilege><D:read/></D:privilege>
+      <D:privilege><D:read-current-user-privilege-set/></D:privilege>
+    </D:grant>
+  </D:ace>
+</D:acl>`
+
+const propFindACL = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:current-user-privilege-set/><D:acl/><D:owner/></D:prop></D:propfind>`
+
+func TestHandler_acl(t *testing.T) {
+	ctx := context.Background()
+	az := NewMemAuthorizer()
+	az.SetOwner(ctx, "/", "/principals/admin")
+	az.SetACL(ctx, "/", []ACE{
+		{Principal: PrincipalOwner, Privileges: []xml.Name{PrivilegeAll}, Protected: true},
+	})
+	h := &Handler{FileSystem: NewMemFileSystem(), Authorizer: az}
+	admin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), "/principals/admin")))
+	})
+
+	res := doTestRequest(h, http.MethodPut, "/file.txt", "hello", nil)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("anonymous PUT: status = %v, want %v", res.StatusCode, http.StatusForbidden)
+	}
+	res = doTestRequest(admin, http.MethodPut, "/file.txt", "hello", nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("admin PUT: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+
+	res = doTestRequest(admin, http.MethodOptions, "/", "", nil)
+	if !strings.Contains(res.Header.Get("DAV"), "access-control") || !strings.Contains(res.Header.Get("Allow"), "ACL") {
+		t.Errorf("OPTIONS: DAV = %q, Allow = %q", res.Header.Get("DAV"), res.Header.Get("Allow"))
+	}
+
+	res = doTestRequest(h, "ACL", "/file.txt", aclGrantAllRead, nil)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("anonymous ACL: status = %v, want %v", res.StatusCode, http.StatusForbidden)
+	}
+	res = doTestRequest(admin, "ACL", "/file.txt", aclGrantAllRead, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("admin ACL: status = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+
+	res = doTestRequest(h, http.MethodGet, "/file.txt", "", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("anonymous GET: status = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/file.txt", propFindACL, map[string]string{"Depth": "0"})
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "<read xmlns=\"DAV:\"></read>") || strings.Contains(body, "<write") {
+		t.Errorf("anonymous PROPFIND: body = %v", body)
+	}
+	if !strings.Contains(body, "<acl xmlns=\"DAV:\"></acl></prop><status>HTTP/1.1 403 Forbidden") {
+		t.Errorf("anonymous PROPFIND: acl not forbidden: %v", body)
+	}
+
+	req := httptest.NewRequest("PROPFIND", "/file.txt", strings.NewReader(propFindACL))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", "0")
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, req)
+	body = w.Body.String()
+	if !strings.Contains(body, "<protected></protected>") || !strings.Contains(body, "<inherited xmlns=\"DAV:\"><href>/</href></inherited>") || !strings.Contains(body, "<all></all>") {
+		t.Errorf("admin PROPFIND: body = %v", body)
+	}
+
+	invert := strings.Replace(aclGrantAllRead, "<D:principal><D:all/></D:principal>", "<D:invert><D:principal><D:all/></D:principal></D:invert>", 1)
+	res = doTestRequest(admin, "ACL", "/file.txt", invert, nil)
+	if body := readTestBody(t, res); res.StatusCode != http.StatusForbidden || !strings.Contains(body, "no-invert") {
+		t.Errorf("ACL with invert: status = %v, body = %v", res.StatusCode, body)
+	}
+}
+
+func TestHandler_aclPropFindMembers(t *testing.T) {
+	ctx := context.Background()
+	fs := NewMemFileSystem()
+	for _, name := range []string{"/public.txt", "/secret.txt"} {
+		if _, _, err := fs.Create(ctx, name, ioutil.NopCloser(strings.NewReader("hello")), &CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	az := NewMemAuthorizer()
+	az.SetACL(ctx, "/", []ACE{
+		{Principal: PrincipalAll, Privileges: []xml.Name{PrivilegeRead}},
+	})
+	az.SetACL(ctx, "/secret.txt", []ACE{
+		{Principal: PrincipalAll, Deny: true, Privileges: []xml.Name{PrivilegeRead}},
+	})
+	h := &Handler{FileSystem: fs, Authorizer: az}
+
+	res := doTestRequest(h, "PROPFIND", "/", `<propfind xmlns="DAV:"><prop><getcontentlength/></prop></propfind>`, map[string]string{"Depth": "1"})
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "<getcontentlength xmlns=\"DAV:\">5</getcontentlength>") {
+		t.Errorf("PROPFIND: readable member missing: %v", body)
+	}
+	if !strings.Contains(body, "<href>/secret.txt</href><responsedescription>") || strings.Count(body, "403 Forbidden") != 2 {
+		t.Errorf("PROPFIND: unreadable member not forbidden: %v", body)
+	}
+}
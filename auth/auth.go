@@ -0,0 +1,152 @@
+// Package auth provides HTTP authentication middleware for WebDAV, CalDAV and
+// CardDAV servers.
+//
+// The middleware stores the authenticated user in the request context. The
+// user's principal path is also stored with webdav.ContextWithPrincipal, so
+// that webdav.Handler.Authorizer and webdav.ServePrincipal can use it, and
+// PrincipalBackend can be embedded in CalDAV and CardDAV backends to
+// implement webdav.UserPrincipalBackend.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/emersion/go-webdav"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request
+	// doesn't carry credentials it understands.
+	ErrNoCredentials = errors.New("auth: no credentials")
+	// ErrInvalidCredentials is returned by an Authenticator when the
+	// request carries credentials which can't be verified.
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+)
+
+// Authenticator authenticates HTTP requests.
+type Authenticator interface {
+	// Authenticate returns the name of the user making the request. It
+	// returns ErrNoCredentials if the request doesn't carry credentials for
+	// this authenticator, and an error wrapping ErrInvalidCredentials if the
+	// credentials are rejected.
+	Authenticate(r *http.Request) (username string, err error)
+	// Challenge returns the value of the WWW-Authenticate header field sent
+	// along with 401 responses, or an empty string.
+	Challenge() string
+}
+
+// User is an authenticated user.
+type User struct {
+	Name string
+	// Principal is the path of the user's principal.
+	Principal string
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying an authenticated user.
+func NewContext(ctx context.Context, user *User) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, user)
+	return webdav.ContextWithPrincipal(ctx, user.Principal)
+}
+
+// FromContext returns the authenticated user stored in ctx, if any.
+func FromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(contextKey{}).(*User)
+	return user, ok
+}
+
+// PrincipalBackend implements webdav.UserPrincipalBackend with the user
+// stored in the request context by Middleware. It can be embedded in CalDAV
+// and CardDAV backends.
+type PrincipalBackend struct{}
+
+var _ webdav.UserPrincipalBackend = PrincipalBackend{}
+
+func (PrincipalBackend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	user, ok := FromContext(ctx)
+	if !ok {
+		return "", webdav.NewHTTPError(http.StatusUnauthorized, errors.New("auth: user isn't authenticated"))
+	}
+	return user.Principal, nil
+}
+
+// DefaultPrincipalPath returns "/<username>/".
+func DefaultPrincipalPath(username string) string {
+	return "/" + url.PathEscape(username) + "/"
+}
+
+// Middleware authenticates requests before passing them to the next handler.
+type Middleware struct {
+	// Authenticators are tried in order. The first one finding credentials
+	// in a request decides whether it is authenticated.
+	Authenticators []Authenticator
+	// PrincipalPath maps a user name to the path of the user's principal. If
+	// nil, DefaultPrincipalPath is used.
+	PrincipalPath func(username string) string
+	// AllowAnonymous lets requests without credentials through, leaving the
+	// decision to the next handler.
+	AllowAnonymous bool
+	// ErrorLog is used to log unexpected authentication errors, which aren't
+	// sent to clients. If nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// Handler wraps an HTTP handler with authentication.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, a := range m.Authenticators {
+			username, err := a.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			} else if errors.Is(err, ErrInvalidCredentials) {
+				m.unauthorized(w)
+				return
+			} else if err != nil {
+				m.logf("auth: failed to authenticate request: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			principalPath := m.PrincipalPath
+			if principalPath == nil {
+				principalPath = DefaultPrincipalPath
+			}
+			user := &User{Name: username, Principal: principalPath(username)}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), user)))
+			return
+		}
+
+		if !m.AllowAnonymous {
+			m.unauthorized(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Middleware) logf(format string, v ...interface{}) {
+	if m.ErrorLog != nil {
+		m.ErrorLog.Printf(format, v...)
+	} else {
+		log.Printf(format, v...)
+	}
+}
+
+func (m *Middleware) unauthorized(w http.ResponseWriter) {
+	for _, a := range m.Authenticators {
+		if challenge := a.Challenge(); challenge != "" {
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+	}
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
+
+func quoteRealm(realm string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(realm) + `"`
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHtpasswd(t *testing.T) *Htpasswd {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {SHA} hash of "password"
+	data := "# users\nalice:" + string(hash) + "\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"
+	h, err := ReadHtpasswd(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadHtpasswd() = %v", err)
+	}
+	return h
+}
+
+func TestHtpasswd(t *testing.T) {
+	h := newTestHtpasswd(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		username, password string
+		ok                 bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "password", false},
+		{"bob", "password", true},
+		{"bob", "secret", false},
+		{"eve", "secret", false},
+	}
+	for _, tc := range tests {
+		err := h.CheckPassword(ctx, tc.username, tc.password)
+		if tc.ok && err != nil {
+			t.Errorf("CheckPassword(%q, %q) = %v", tc.username, tc.password, err)
+		} else if !tc.ok && !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("CheckPassword(%q, %q) = %v, want ErrInvalidCredentials", tc.username, tc.password, err)
+		}
+	}
+
+	if _, err := ReadHtpasswd(strings.NewReader("carol:$apr1$abc$def\n")); err == nil {
+		t.Errorf("ReadHtpasswd() with MD5 hash succeeded")
+	}
+}
+
+func signTestJWT(t *testing.T, header, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(header) + "." + encode(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func TestBearer(t *testing.T) {
+	hmacKey := []byte("0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bearer{
+		HMACKey:  hmacKey,
+		RSAKeys:  map[string]*rsa.PublicKey{"k1": &rsaKey.PublicKey},
+		Issuer:   "https://issuer.example.org",
+		Audience: "dav",
+	}
+
+	signHS256 := func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, hmacKey)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+	signRS256 := func(signed []byte) []byte {
+		sum := sha256.Sum256(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://issuer.example.org",
+		"aud": []string{"dav", "other"},
+		"exp": exp,
+	}
+	withClaim := func(k string, v interface{}) map[string]interface{} {
+		m := make(map[string]interface{})
+		for k, v := range claims {
+			m[k] = v
+		}
+		m[k] = v
+		return m
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"HS256", signTestJWT(t, map[string]interface{}{"alg": "HS256"}, claims, signHS256), true},
+		{"RS256", signTestJWT(t, map[string]interface{}{"alg": "RS256", "kid": "k1"}, claims, signRS256), true},
+		{"unknown kid", signTestJWT(t, map[string]interface{}{"alg": "RS256", "kid": "k2"}, claims, signRS256), false},
+		{"none", signTestJWT(t, map[string]interface{}{"alg": "none"}, claims, func([]byte) []byte { return nil }), false},
+		{"bad signature", signTestJWT(t, map[string]interface{}{"alg": "HS256"}, withClaim("sub", "bob"), func([]byte) []byte { return make([]byte, 32) }), false},
+		{"expired", signTestJWT(t, map[string]interface{}{"alg": "HS256"}, withClaim("exp", time.Now().Add(-time.Hour).Unix()), signHS256), false},
+		{"not before", signTestJWT(t, map[string]interface{}{"alg": "HS256"}, withClaim("nbf", time.Now().Add(time.Hour).Unix()), signHS256), false},
+		{"issuer", signTestJWT(t, map[string]interface{}{"alg": "HS256"}, withClaim("iss", "https://evil.example.org"), signHS256), false},
+		{"audience", signTestJWT(t, map[string]interface{}{"alg": "HS256"}, withClaim("aud", "other"), signHS256), false},
+		{"malformed", "not-a-token", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			username, err := b.Authenticate(req)
+			if tc.ok && (err != nil || username != "alice") {
+				t.Errorf("Authenticate() = %q, %v, want alice", username, err)
+			} else if !tc.ok && !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Authenticate() = %q, %v, want ErrInvalidCredentials", username, err)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "secret")
+	if _, err := b.Authenticate(req); err != ErrNoCredentials {
+		t.Errorf("Authenticate() with Basic credentials = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestClientCert(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	c := &ClientCert{}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := c.Authenticate(req); err != ErrNoCredentials {
+		t.Errorf("Authenticate() without TLS = %v, want ErrNoCredentials", err)
+	}
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if _, err := c.Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() with unverified certificate = %v, want ErrInvalidCredentials", err)
+	}
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	if username, err := c.Authenticate(req); err != nil || username != "alice" {
+		t.Errorf("Authenticate() = %q, %v, want alice", username, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	az := webdav.NewMemAuthorizer()
+	az.SetACL(ctx, "/", []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Privileges: []xml.Name{webdav.PrivilegeRead}},
+		{Principal: "/alice/", Privileges: []xml.Name{webdav.PrivilegeAll}},
+	})
+	mw := &Middleware{
+		Authenticators: []Authenticator{&Basic{Realm: "test", Passwords: newTestHtpasswd(t)}},
+		AllowAnonymous: true,
+	}
+	h := mw.Handler(&webdav.Handler{FileSystem: webdav.NewMemFileSystem(), Authorizer: az})
+
+	do := func(method, username, password string) *http.Response {
+		req := httptest.NewRequest(method, "/file.txt", strings.NewReader("hello"))
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	if res := do(http.MethodPut, "alice", "wrong"); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("PUT with wrong password: status = %v, want %v", res.StatusCode, http.StatusUnauthorized)
+	} else if challenge := res.Header.Get("WWW-Authenticate"); !strings.HasPrefix(challenge, `Basic realm="test"`) {
+		t.Errorf("PUT with wrong password: WWW-Authenticate = %q", challenge)
+	}
+	if res := do(http.MethodPut, "", ""); res.StatusCode != http.StatusForbidden {
+		t.Errorf("anonymous PUT: status = %v, want %v", res.StatusCode, http.StatusForbidden)
+	}
+	if res := do(http.MethodPut, "bob", "password"); res.StatusCode != http.StatusForbidden {
+		t.Errorf("PUT as bob: status = %v, want %v", res.StatusCode, http.StatusForbidden)
+	}
+	if res := do(http.MethodPut, "alice", "secret"); res.StatusCode != http.StatusCreated {
+		t.Errorf("PUT as alice: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	if res := do(http.MethodGet, "", ""); res.StatusCode != http.StatusOK {
+		t.Errorf("anonymous GET: status = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+
+	mw.AllowAnonymous = false
+	if res := do(http.MethodGet, "", ""); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous GET without AllowAnonymous: status = %v, want %v", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+type brokenPasswordChecker struct{}
+
+func (brokenPasswordChecker) CheckPassword(ctx context.Context, username, password string) error {
+	return errors.New("dial tcp 10.0.0.1:389: connection refused")
+}
+
+func TestMiddleware_error(t *testing.T) {
+	var logs bytes.Buffer
+	mw := &Middleware{
+		Authenticators: []Authenticator{&Basic{Realm: "test", Passwords: brokenPasswordChecker{}}},
+		ErrorLog:       log.New(&logs, "", 0),
+	}
+	h := mw.Handler(&webdav.Handler{FileSystem: webdav.NewMemFileSystem()})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	res := w.Result()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %v, want %v", res.StatusCode, http.StatusInternalServerError)
+	}
+	if strings.Contains(string(body), "10.0.0.1") {
+		t.Errorf("body = %q, leaks the error", body)
+	}
+	if !strings.Contains(logs.String(), "connection refused") {
+		t.Errorf("log = %q, want the error", logs.String())
+	}
+}
+
+func TestPrincipalBackend(t *testing.T) {
+	var b PrincipalBackend
+	if _, err := b.CurrentUserPrincipal(context.Background()); err == nil {
+		t.Errorf("CurrentUserPrincipal() without user succeeded")
+	}
+
+	ctx := NewContext(context.Background(), &User{Name: "alice", Principal: "/alice/"})
+	if p, err := b.CurrentUserPrincipal(ctx); err != nil || p != "/alice/" {
+		t.Errorf("CurrentUserPrincipal() = %q, %v, want /alice/", p, err)
+	}
+	if p := webdav.PrincipalFromContext(ctx); p != "/alice/" {
+		t.Errorf("webdav.PrincipalFromContext() = %q, want /alice/", p)
+	}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordChecker checks user passwords.
+type PasswordChecker interface {
+	// CheckPassword returns ErrInvalidCredentials if the user doesn't exist
+	// or if the password is wrong.
+	CheckPassword(ctx context.Context, username, password string) error
+}
+
+// Basic authenticates requests with the Basic HTTP authentication scheme
+// defined in RFC 7617.
+type Basic struct {
+	Realm     string
+	Passwords PasswordChecker
+}
+
+var _ Authenticator = (*Basic)(nil)
+
+func (b *Basic) Authenticate(r *http.Request) (string, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", ErrNoCredentials
+	}
+	if err := b.Passwords.CheckPassword(r.Context(), username, password); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+func (b *Basic) Challenge() string {
+	return "Basic realm=" + quoteRealm(b.Realm) + `, charset="UTF-8"`
+}
+
+// Htpasswd is a PasswordChecker backed by an Apache htpasswd file. Only
+// bcrypt ("$2y$") and SHA-1 ("{SHA}") hashes are supported. It is safe for
+// concurrent use.
+type Htpasswd struct {
+	path string
+
+	mutex  sync.RWMutex
+	hashes map[string]string
+}
+
+var _ PasswordChecker = (*Htpasswd)(nil)
+
+// OpenHtpasswd loads an htpasswd file. The file can be loaded again with
+// Reload.
+func OpenHtpasswd(path string) (*Htpasswd, error) {
+	h := &Htpasswd{path: path}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ReadHtpasswd parses the contents of an htpasswd file.
+func ReadHtpasswd(r io.Reader) (*Htpasswd, error) {
+	hashes, err := readHtpasswd(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Htpasswd{hashes: hashes}, nil
+}
+
+// Reload loads the htpasswd file again. It is a no-op if the Htpasswd has
+// been created with ReadHtpasswd.
+func (h *Htpasswd) Reload() error {
+	if h.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hashes, err := readHtpasswd(f)
+	if err != nil {
+		return fmt.Errorf("auth: failed to read %q: %w", h.path, err)
+	}
+
+	h.mutex.Lock()
+	h.hashes = hashes
+	h.mutex.Unlock()
+	return nil
+}
+
+func readHtpasswd(r io.Reader) (map[string]string, error) {
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("auth: malformed htpasswd line %v", i)
+		}
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("auth: unsupported hash for user %q on htpasswd line %v", username, i)
+		}
+		hashes[username] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// htpasswdDummyHash is a bcrypt hash checked against when the user doesn't
+// exist, so that unknown users can't be told apart by the response time.
+const htpasswdDummyHash = "$2a$10$YDpPUxPn4Vc21lvpw2AyCuNBETATn99PeekIEud9B.DYHGy4WmRna"
+
+func (h *Htpasswd) CheckPassword(ctx context.Context, username, password string) error {
+	h.mutex.RLock()
+	hash, ok := h.hashes[username]
+	h.mutex.RUnlock()
+	if !ok {
+		bcrypt.CompareHashAndPassword([]byte(htpasswdDummyHash), []byte(password))
+		return ErrInvalidCredentials
+	}
+
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		want := strings.TrimPrefix(hash, "{SHA}")
+		if subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(sum[:])), []byte(want)) != 1 {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Bearer authenticates requests carrying a JSON Web Token (RFC 7519) with the
+// Bearer HTTP authentication scheme defined in RFC 6750. Tokens signed with
+// HS256, HS384, HS512, RS256, RS384 and RS512 are verified with local keys.
+//
+// Tokens must have an "exp" claim.
+type Bearer struct {
+	Realm string
+	// HMACKey verifies tokens signed with HS256, HS384 and HS512.
+	HMACKey []byte
+	// RSAKeys verify tokens signed with RS256, RS384 and RS512, indexed by
+	// key ID. The key with an empty ID verifies tokens without a "kid"
+	// header parameter.
+	RSAKeys map[string]*rsa.PublicKey
+	// If non-empty, Issuer must match the "iss" claim.
+	Issuer string
+	// If non-empty, Audience must be listed in the "aud" claim.
+	Audience string
+	// UsernameClaim is the claim holding the user name. If empty, "sub" is
+	// used.
+	UsernameClaim string
+	// Leeway is the clock skew tolerated when checking the "exp" and "nbf"
+	// claims.
+	Leeway time.Duration
+}
+
+var _ Authenticator = (*Bearer)(nil)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+func (b *Bearer) Authenticate(r *http.Request) (string, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", ErrNoCredentials
+	}
+
+	claims, err := b.verify(strings.TrimSpace(token))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if err := b.checkClaims(claims, time.Now()); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	usernameClaim := b.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "sub"
+	}
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		return "", fmt.Errorf("%w: missing %q claim", ErrInvalidCredentials, usernameClaim)
+	}
+	return username, nil
+}
+
+func (b *Bearer) Challenge() string {
+	return "Bearer realm=" + quoteRealm(b.Realm)
+}
+
+// verify checks the signature of a token and returns its claims.
+func (b *Bearer) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %v", err)
+	}
+
+	// Only accept the algorithm family matching the configured keys, so that
+	// a public RSA key can't be used as an HMAC secret
+	if len(header.Alg) != 5 {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	hash, ok := jwtHashes[header.Alg[2:]]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch header.Alg[:2] {
+	case "HS":
+		if len(b.HMACKey) == 0 {
+			return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+		}
+		mac := hmac.New(hash.New, b.HMACKey)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	case "RS":
+		key, ok := b.RSAKeys[header.Kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", header.Kid)
+		}
+		h := hash.New()
+		h.Write(signed)
+		if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig); err != nil {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %v", err)
+	}
+	return claims, nil
+}
+
+func (b *Bearer) checkClaims(claims map[string]interface{}, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("missing expiration time")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(b.Leeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-b.Leeway)) {
+		return fmt.Errorf("token not valid yet")
+	}
+
+	if b.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != b.Issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+
+	if b.Audience != "" {
+		var audiences []interface{}
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []interface{}{aud}
+		case []interface{}:
+			audiences = aud
+		}
+		found := false
+		for _, aud := range audiences {
+			if aud == b.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("token not intended for this audience")
+		}
+	}
+
+	return nil
+}
+
+func decodeJWTPart(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// ClientCert authenticates requests with TLS client certificates. The
+// http.Server's tls.Config must verify client certificates, by setting
+// ClientAuth to tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert
+// and ClientCAs to the trusted certificate authorities.
+type ClientCert struct {
+	// Username returns the user name for a verified certificate. If nil, the
+	// subject common name is used.
+	Username func(cert *x509.Certificate) (string, error)
+}
+
+var _ Authenticator = (*ClientCert)(nil)
+
+func (c *ClientCert) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", ErrNoCredentials
+	}
+	if len(r.TLS.VerifiedChains) == 0 {
+		return "", fmt.Errorf("%w: client certificate not verified", ErrInvalidCredentials)
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	if c.Username != nil {
+		return c.Username(cert)
+	}
+	if cert.Subject.CommonName == "" {
+		return "", fmt.Errorf("%w: client certificate without common name", ErrInvalidCredentials)
+	}
+	return cert.Subject.CommonName, nil
+}
+
+func (c *ClientCert) Challenge() string {
+	return ""
+}
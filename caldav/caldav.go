@@ -71,6 +71,9 @@ type Calendar struct {
 	SupportedComponentSet []string
 }
 
+// CalendarCompRequest describes the parts of calendar objects requested by a
+// client, as defined in RFC 4791 section 9.6. Only the properties and
+// sub-components listed are returned, unless AllProps or AllComps is set.
 type CalendarCompRequest struct {
 	Name string
 
@@ -80,9 +83,21 @@ type CalendarCompRequest struct {
 	AllComps bool
 	Comps    []CalendarCompRequest
 
+	// Expand requests recurring components to be expanded into the
+	// instances overlapping the time range. Times are converted to UTC.
 	Expand *CalendarExpandRequest
+	// LimitRecurrenceSet requests only the overridden instances overlapping
+	// the time range to be returned, along with the master component. It
+	// can't be used together with Expand.
+	LimitRecurrenceSet *CalendarExpandRequest
+	// LimitFreeBusySet requests only the FREEBUSY periods overlapping the
+	// time range to be returned.
+	LimitFreeBusySet *CalendarExpandRequest
 }
 
+// CalendarExpandRequest is the time range of a CalendarCompRequest.Expand,
+// LimitRecurrenceSet or LimitFreeBusySet request. The start is inclusive, the
+// end is exclusive.
 type CalendarExpandRequest struct {
 	Start, End time.Time
 }
@@ -112,13 +127,34 @@ type PropFilter struct {
 type TextMatch struct {
 	Text            string
 	NegateCondition bool
+	Collation       Collation // defaults to CollationASCIICaseMap
 }
 
+// Collation defines how text is compared in a TextMatch.
+type Collation string
+
+// Collations supported by text matches, defined in RFC 4790 and RFC 5051.
+const (
+	CollationOctet          Collation = internal.CollationOctet
+	CollationASCIICaseMap   Collation = internal.CollationASCIICaseMap
+	CollationUnicodeCaseMap Collation = internal.CollationUnicodeCaseMap
+)
+
 type CalendarQuery struct {
 	CompRequest CalendarCompRequest
 	CompFilter  CompFilter
 }
 
+// SyncQuery is a sync-collection request, defined in RFC 6578.
+type SyncQuery struct {
+	CompRequest CalendarCompRequest
+	// SyncToken identifies the state of the calendar known by the client, or
+	// is empty for an initial synchronization.
+	SyncToken string
+	// Limit is the maximum number of changes to return. Zero means no limit.
+	Limit int
+}
+
 type CalendarMultiGet struct {
 	Paths       []string
 	CompRequest CalendarCompRequest
@@ -43,6 +43,13 @@ func NewClient(c webdav.HTTPClient, endpoint string) (*Client, error) {
 	return &Client{wc, ic, nil}, nil
 }
 
+// SetRetryPolicy sets the policy used to retry failed requests. A nil policy
+// disables retries, which is the default.
+func (c *Client) SetRetryPolicy(policy *webdav.RetryPolicy) {
+	c.Client.SetRetryPolicy(policy)
+	c.ic.SetRetryPolicy(policy)
+}
+
 // SetConflictResolver sets the conflict resolution strategy for this client.
 // Pass nil to disable automatic conflict resolution (default behavior).
 func (c *Client) SetConflictResolver(resolver ConflictResolver) {
@@ -164,9 +171,22 @@ func encodeCalendarReq(c *CalendarCompRequest) (*internal.Prop, error) {
 		return nil, err
 	}
 
-	expandReq := encodeExpandRequest(c.Expand)
-
-	calDataReq := calendarDataReq{Comp: compReq, Expand: expandReq}
+	calDataReq := calendarDataReq{
+		Comp:   compReq,
+		Expand: encodeExpandRequest(c.Expand),
+	}
+	if c.LimitRecurrenceSet != nil {
+		calDataReq.LimitRecurrenceSet = &limitRecurrenceSet{
+			Start: dateWithUTCTime(c.LimitRecurrenceSet.Start),
+			End:   dateWithUTCTime(c.LimitRecurrenceSet.End),
+		}
+	}
+	if c.LimitFreeBusySet != nil {
+		calDataReq.LimitFreeBusySet = &limitFreeBusySet{
+			Start: dateWithUTCTime(c.LimitFreeBusySet.Start),
+			End:   dateWithUTCTime(c.LimitFreeBusySet.End),
+		}
+	}
 
 	getLastModReq := internal.NewRawXMLElement(internal.GetLastModifiedName, nil, nil)
 	getETagReq := internal.NewRawXMLElement(internal.GetETagName, nil, nil)
@@ -220,6 +240,7 @@ func encodeTextMatch(tm *TextMatch) *textMatch {
 
 	encoded := &textMatch{
 		Text:            tm.Text,
+		Collation:       string(tm.Collation),
 		NegateCondition: negateCondition(tm.NegateCondition),
 	}
 	return encoded
@@ -237,47 +258,64 @@ func encodeExpandRequest(e *CalendarExpandRequest) *expand {
 }
 
 func decodeCalendarObjectList(ms *internal.MultiStatus) ([]CalendarObject, error) {
-	addrs := make([]CalendarObject, 0, len(ms.Responses))
-	errs := make([]error, 0, len(ms.Responses))
-	for _, resp := range ms.Responses {
+	l := make([]CalendarObject, 0, len(ms.Responses))
+	var errs []error
+	decode := calendarObjectDecoder(&errs, func(obj *CalendarObject) error {
+		l = append(l, *obj)
+		return nil
+	})
+	for i := range ms.Responses {
+		if err := decode(&ms.Responses[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, errors.Join(errs...)
+}
+
+// calendarObjectDecoder returns a multi-status response callback which decodes
+// objects and passes them to fn. Responses with an error status are appended
+// to errs.
+func calendarObjectDecoder(errs *[]error, fn func(obj *CalendarObject) error) func(resp *internal.Response) error {
+	return func(resp *internal.Response) error {
 		path, err := resp.Path()
 		if err != nil {
-			errs = append(errs, err)
-			continue
+			*errs = append(*errs, err)
+			return nil
 		}
 
 		var calData calendarDataResp
 		if err := resp.DecodeProp(&calData); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		// Skip responses without calendar-data (e.g., calendar collection itself)
 		if len(calData.Data) == 0 {
-			continue
+			return nil
 		}
 
 		var getLastMod internal.GetLastModified
 		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		var getETag internal.GetETag
 		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		var getContentLength internal.GetContentLength
 		if err := resp.DecodeProp(&getContentLength); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		r := bytes.NewReader(calData.Data)
 		data, err := ical.NewDecoder(r).Decode()
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		addrs = append(addrs, CalendarObject{
+		return fn(&CalendarObject{
 			Path:          path,
 			ModTime:       time.Time(getLastMod.LastModified),
 			ContentLength: getContentLength.Length,
@@ -285,8 +323,6 @@ func decodeCalendarObjectList(ms *internal.MultiStatus) ([]CalendarObject, error
 			Data:          data,
 		})
 	}
-
-	return addrs, errors.Join(errs...)
 }
 
 // QueryCalendar queries calendar objects using a calendar-query REPORT.
@@ -301,6 +337,38 @@ func decodeCalendarObjectList(ms *internal.MultiStatus) ([]CalendarObject, error
 //
 // This automatically adds a time-range filter to the calendar query.
 func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *CalendarQuery, opts *QueryOptions) ([]CalendarObject, error) {
+	req, err := c.newQueryCalendarRequest(calendar, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeCalendarObjectList(ms)
+}
+
+// QueryCalendarFunc is like QueryCalendar, but calls fn for each calendar
+// object as soon as it's received, instead of holding the whole result in
+// memory.
+//
+// If fn returns an error, QueryCalendarFunc stops and returns that error.
+func (c *Client) QueryCalendarFunc(ctx context.Context, calendar string, query *CalendarQuery, opts *QueryOptions, fn func(co *CalendarObject) error) error {
+	req, err := c.newQueryCalendarRequest(calendar, query, opts)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if _, err := c.ic.DoMultiStatusFunc(req.WithContext(ctx), calendarObjectDecoder(&errs, fn)); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) newQueryCalendarRequest(calendar string, query *CalendarQuery, opts *QueryOptions) (*http.Request, error) {
 	modifiedQuery := *query
 
 	if opts == nil {
@@ -330,12 +398,7 @@ func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *Cale
 	}
 	req.Header.Add("Depth", "1")
 
-	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
-	if err != nil {
-		return nil, err
-	}
-
-	return decodeCalendarObjectList(ms)
+	return req, nil
 }
 
 func (c *Client) MultiGetCalendar(ctx context.Context, path string, multiGet *CalendarMultiGet) ([]CalendarObject, error) {
@@ -577,7 +640,7 @@ func (c *Client) DeleteCalendarObject(ctx context.Context, path string) error {
 // It returns created, updated, and deleted events since the last sync-token.
 // Use an empty syncToken for initial synchronization.
 //
-// If the sync-token has expired (HTTP 410 Gone), ErrSyncTokenExpired is returned.
+// If the sync-token is no longer valid, ErrSyncTokenExpired is returned.
 //
 // Example:
 //
@@ -587,6 +650,32 @@ func (c *Client) DeleteCalendarObject(ctx context.Context, path string) error {
 //	    result, err = client.SyncCalendar(ctx, calendarPath, "")
 //	}
 func (c *Client) SyncCalendar(ctx context.Context, calendar string, syncToken string, opts *SyncOptions) (*SyncResult, error) {
+	result := NewSyncResult("")
+	newSyncToken, err := c.SyncCalendarFunc(ctx, calendar, syncToken, opts, func(change *SyncChange) error {
+		switch change.Type {
+		case SyncChangeCreated:
+			result.AddCreated(change.Object)
+		case SyncChangeUpdated:
+			result.AddUpdated(change.Object)
+		case SyncChangeDeleted:
+			result.AddDeleted(change.Object.Path, c.extractUIDFromPath(change.Object.Path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.SyncToken = newSyncToken
+	return result, nil
+}
+
+// SyncCalendarFunc is like SyncCalendar, but calls fn for each change as soon
+// as it's received, instead of holding the whole result in memory. The new
+// sync-token is returned.
+//
+// If fn returns an error, SyncCalendarFunc stops and returns that error.
+func (c *Client) SyncCalendarFunc(ctx context.Context, calendar string, syncToken string, opts *SyncOptions, fn func(change *SyncChange) error) (newSyncToken string, err error) {
 	syncLevel := internal.DepthOne
 	if opts != nil && opts.SyncLevel != 0 {
 		syncLevel = opts.SyncLevel
@@ -598,103 +687,106 @@ func (c *Client) SyncCalendar(ctx context.Context, calendar string, syncToken st
 		internal.NewRawXMLElement(internal.GetETagName, nil, nil),
 	)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	ms, err := c.ic.SyncCollection(ctx, calendar, syncToken, syncLevel, nil, prop)
-	if err != nil {
-		// Handle expired sync-token (HTTP 410 Gone)
-		var httpErr *internal.HTTPError
-		if errors.As(err, &httpErr) && httpErr.Code == http.StatusGone {
-			return nil, ErrSyncTokenExpired
+	ms, err := c.ic.SyncCollectionFunc(ctx, calendar, syncToken, syncLevel, nil, prop, func(resp *internal.Response) error {
+		change := decodeSyncChange(calendar, resp)
+		if change == nil {
+			return nil
 		}
-		return nil, err
+		return fn(change)
+	})
+	if internal.IsSyncTokenError(err) {
+		return "", ErrSyncTokenExpired
+	} else if err != nil {
+		return "", err
 	}
 
-	result := NewSyncResult(ms.SyncToken)
+	return ms.SyncToken, nil
+}
 
-	for _, resp := range ms.Responses {
-		path, err := resp.Path()
-		if err != nil {
-			continue
-		}
+// decodeSyncChange decodes a sync-collection response element. It returns
+// nil if the element doesn't describe a change to a calendar object.
+func decodeSyncChange(calendar string, resp *internal.Response) *SyncChange {
+	path, err := resp.Path()
+	if err != nil {
+		return nil
+	}
 
-		// Skip root calendar
-		if path == calendar {
-			continue
-		}
+	// Skip root calendar
+	if path == calendar {
+		return nil
+	}
 
-		var status string
-		var etag string
-		var calendarData []byte
+	var status string
+	var etag string
+	var calendarData []byte
 
-		// Extract status and data from response
-		if resp.Status != nil {
-			status = resp.Status.Text
-		}
+	// Extract status and data from response
+	if resp.Status != nil {
+		status = resp.Status.Text
+	}
 
-		// Extract properties from propstat
-		for _, propstat := range resp.PropStats {
-			if err := resp.Err(); err != nil {
-				continue
-			}
+	// Extract properties from propstat
+	for _, propstat := range resp.PropStats {
+		if err := resp.Err(); err != nil {
+			continue
+		}
 
-			// Extract ETag
-			var getETag internal.GetETag
-			if err := propstat.Prop.Decode(&getETag); err == nil {
-				etag = string(getETag.ETag)
-			}
+		// Extract ETag
+		var getETag internal.GetETag
+		if err := propstat.Prop.Decode(&getETag); err == nil {
+			etag = string(getETag.ETag)
+		}
 
-			// Extract calendar-data if available
-			var calData calendarDataResp
-			if err := propstat.Prop.Decode(&calData); err == nil {
-				calendarData = calData.Data
-			}
+		// Extract calendar-data if available
+		var calData calendarDataResp
+		if err := propstat.Prop.Decode(&calData); err == nil {
+			calendarData = calData.Data
+		}
 
-			// Determine status from propstat
-			if status == "" {
-				status = propstat.Status.Text
-			}
+		// Determine status from propstat
+		if status == "" {
+			status = propstat.Status.Text
 		}
+	}
 
-		// Process events based on status
-		if strings.Contains(status, "404") {
-			// Event deleted
-			uid := c.extractUIDFromPath(path)
-			result.AddDeleted(path, uid)
+	// Process events based on status
+	if strings.Contains(status, "404") {
+		// Event deleted
+		return &SyncChange{Type: SyncChangeDeleted, Object: CalendarObject{Path: path}}
 
-		} else if strings.Contains(status, "200") && len(calendarData) > 0 {
-			// Event created/updated (with calendar-data)
-			r := bytes.NewReader(calendarData)
-			cal, err := ical.NewDecoder(r).Decode()
-			if err != nil {
-				continue
-			}
+	} else if strings.Contains(status, "200") && len(calendarData) > 0 {
+		// Event created/updated (with calendar-data)
+		r := bytes.NewReader(calendarData)
+		cal, err := ical.NewDecoder(r).Decode()
+		if err != nil {
+			return nil
+		}
 
-			obj := CalendarObject{
-				Path:    path,
-				ETag:    etag,
-				Data:    cal,
-				ModTime: time.Now(),
-			}
+		obj := CalendarObject{
+			Path:    path,
+			ETag:    etag,
+			Data:    cal,
+			ModTime: time.Now(),
+		}
 
-			if strings.Contains(status, "201") {
-				result.AddCreated(obj)
-			} else {
-				result.AddUpdated(obj)
-			}
+		if strings.Contains(status, "201") {
+			return &SyncChange{Type: SyncChangeCreated, Object: obj}
+		}
+		return &SyncChange{Type: SyncChangeUpdated, Object: obj}
 
-		} else if strings.Contains(status, "200") {
-			// Event created/updated (without calendar-data - ETag only)
-			obj := CalendarObject{
-				Path: path,
-				ETag: etag,
-			}
-			result.AddUpdated(obj)
+	} else if strings.Contains(status, "200") {
+		// Event created/updated (without calendar-data - ETag only)
+		obj := CalendarObject{
+			Path: path,
+			ETag: etag,
 		}
+		return &SyncChange{Type: SyncChangeUpdated, Object: obj}
 	}
 
-	return result, nil
+	return nil
 }
 
 // extractUIDFromPath extracts event UID from CalDAV path
@@ -23,6 +23,9 @@ var (
 
 	calendarName     = xml.Name{namespace, "calendar"}
 	calendarDataName = xml.Name{namespace, "calendar-data"}
+
+	supportedCollationSetName = xml.Name{namespace, "supported-collation-set"}
+	supportedCollationName    = xml.Name{namespace, "supported-collation"}
 )
 
 // https://tools.ietf.org/html/rfc4791#section-6.2.1
@@ -180,7 +183,9 @@ type calendarDataReq struct {
 	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
 	Comp    *comp    `xml:"comp,omitempty"`
 	Expand  *expand  `xml:"expand,omitempty"`
-	// TODO: limit-recurrence-set, limit-freebusy-set
+
+	LimitRecurrenceSet *limitRecurrenceSet `xml:"limit-recurrence-set,omitempty"`
+	LimitFreeBusySet   *limitFreeBusySet   `xml:"limit-freebusy-set,omitempty"`
 }
 
 // https://tools.ietf.org/html/rfc4791#section-9.6.1
@@ -195,12 +200,27 @@ type comp struct {
 	Comp    []comp    `xml:"comp,omitempty"`
 }
 
+// https://tools.ietf.org/html/rfc4791#section-9.6.5
 type expand struct {
 	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav expand"`
 	Start   dateWithUTCTime `xml:"start,attr"`
 	End     dateWithUTCTime `xml:"end,attr"`
 }
 
+// https://tools.ietf.org/html/rfc4791#section-9.6.6
+type limitRecurrenceSet struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav limit-recurrence-set"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.6.7
+type limitFreeBusySet struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav limit-freebusy-set"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
+}
+
 // https://tools.ietf.org/html/rfc4791#section-9.6.4
 type prop struct {
 	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav prop"`
@@ -215,8 +235,9 @@ type calendarDataResp struct {
 }
 
 type reportReq struct {
-	Query    *calendarQuery
-	Multiget *calendarMultiget
+	Query          *calendarQuery
+	Multiget       *calendarMultiget
+	SyncCollection *internal.SyncCollectionQuery
 	// TODO: CALDAV:free-busy-query
 }
 
@@ -229,6 +250,9 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	case calendarMultigetName:
 		r.Multiget = &calendarMultiget{}
 		v = r.Multiget
+	case internal.SyncCollectionName:
+		r.SyncCollection = &internal.SyncCollectionQuery{}
+		v = r.SyncCollection
 	default:
 		return fmt.Errorf("caldav: unsupported REPORT root %q %q", start.Name.Space, start.Name.Local)
 	}
@@ -242,3 +266,9 @@ type mkcolReq struct {
 	DisplayName  string                `xml:"set>prop>displayname"`
 	// TODO this could theoretically contain all addressbook properties?
 }
+
+// https://tools.ietf.org/html/rfc4791#section-7.5.1
+type supportedCollationSet struct {
+	XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:caldav supported-collation-set"`
+	Collation []string `xml:"supported-collation"`
+}
@@ -4,8 +4,10 @@ import "errors"
 
 // CalDAV-specific errors
 var (
-	// ErrSyncTokenExpired returned when sync-token expired (HTTP 410 Gone)
-	ErrSyncTokenExpired = errors.New("caldav: sync token expired (HTTP 410)")
+	// ErrSyncTokenExpired returned when the server rejects a sync-token,
+	// either with the DAV:valid-sync-token precondition (HTTP 403 Forbidden)
+	// or with HTTP 410 Gone
+	ErrSyncTokenExpired = errors.New("caldav: sync token expired")
 
 	// ErrPreconditionFailed returned when preconditions failed (HTTP 412 Precondition Failed)
 	ErrPreconditionFailed = errors.New("caldav: precondition failed (HTTP 412)")
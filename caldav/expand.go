@@ -0,0 +1,459 @@
+package caldav
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// isRecurrable reports whether a component can have a recurrence set.
+func isRecurrable(comp *ical.Component) bool {
+	switch comp.Name {
+	case ical.CompEvent, ical.CompToDo, ical.CompJournal:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRecurring reports whether a component defines a recurrence set.
+func isRecurring(comp *ical.Component) bool {
+	return comp.Props.Get(ical.PropRecurrenceRule) != nil || comp.Props.Get(ical.PropRecurrenceDates) != nil
+}
+
+// isDate reports whether a property holds a DATE value.
+func isDate(prop *ical.Prop) bool {
+	t := prop.ValueType()
+	return t == ical.ValueDate || (t == ical.ValueDefault || t == ical.ValueDateTime) && len(prop.Value) == len("20060102")
+}
+
+// parseDateTimeList parses a property holding a comma-separated list of
+// DATE, DATE-TIME or PERIOD values. Only the start of periods is returned.
+func parseDateTimeList(prop *ical.Prop, loc *time.Location) ([]time.Time, error) {
+	var l []time.Time
+	for _, v := range strings.Split(prop.Value, ",") {
+		if i := strings.IndexByte(v, '/'); i >= 0 {
+			v = v[:i]
+		}
+		single := ical.NewProp(prop.Name)
+		single.Value = v
+		if tzid := prop.Params.Get(ical.PropTimezoneID); tzid != "" {
+			single.Params.Set(ical.PropTimezoneID, tzid)
+		}
+		if isDate(prop) {
+			single.SetValueType(ical.ValueDate)
+		}
+		t, err := single.DateTime(loc)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, t)
+	}
+	return l, nil
+}
+
+// recurrenceSet builds the recurrence set of a component from its DTSTART,
+// RRULE, RDATE and EXDATE properties. It returns nil if the component isn't
+// recurring.
+//
+// Unlike ical.Component.RecurrenceSet, it handles components without RRULE
+// and properties with multiple values.
+func recurrenceSet(comp *ical.Component, loc *time.Location) (*rrule.Set, error) {
+	if !isRecurring(comp) {
+		return nil, nil
+	}
+
+	dtstart, err := comp.Props.DateTime(ical.PropDateTimeStart, loc)
+	if err != nil {
+		return nil, err
+	} else if dtstart.IsZero() {
+		return nil, fmt.Errorf("caldav: recurring %v without DTSTART", comp.Name)
+	}
+
+	var set rrule.Set
+	set.DTStart(dtstart)
+	// The first instance is always defined by DTSTART
+	set.RDate(dtstart)
+
+	roption, err := comp.Props.RecurrenceRule()
+	if err != nil {
+		return nil, err
+	}
+	if roption != nil {
+		roption.Dtstart = dtstart
+		rule, err := rrule.NewRRule(*roption)
+		if err != nil {
+			return nil, err
+		}
+		set.RRule(rule)
+	}
+
+	for _, prop := range comp.Props.Values(ical.PropRecurrenceDates) {
+		l, err := parseDateTimeList(&prop, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range l {
+			set.RDate(t)
+		}
+	}
+	for _, prop := range comp.Props.Values(ical.PropExceptionDates) {
+		l, err := parseDateTimeList(&prop, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range l {
+			set.ExDate(t)
+		}
+	}
+
+	return &set, nil
+}
+
+// recurrenceID returns the key identifying an instance in a recurrence set.
+func recurrenceID(t time.Time) int64 {
+	return t.Unix()
+}
+
+// recurringComponent is a recurring component along with its overridden
+// instances.
+type recurringComponent struct {
+	master    *ical.Component
+	overrides map[int64]*ical.Component
+	// order keeps the overrides in the order of the original calendar
+	order []*ical.Component
+}
+
+// groupRecurringComponents groups the components of a calendar by UID.
+// Components which can't recur are left out.
+func groupRecurringComponents(cal *ical.Calendar) ([]*recurringComponent, error) {
+	var l []*recurringComponent
+	byUID := make(map[string]*recurringComponent)
+	for _, child := range cal.Children {
+		if !isRecurrable(child) {
+			continue
+		}
+
+		uid, err := child.Props.Text(ical.PropUID)
+		if err != nil {
+			return nil, err
+		}
+		rc, ok := byUID[uid]
+		if !ok {
+			rc = &recurringComponent{overrides: make(map[int64]*ical.Component)}
+			byUID[uid] = rc
+			l = append(l, rc)
+		}
+
+		ridProp := child.Props.Get(ical.PropRecurrenceID)
+		if ridProp == nil {
+			rc.master = child
+			continue
+		}
+		rid, err := ridProp.DateTime(time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		rc.overrides[recurrenceID(rid)] = child
+		rc.order = append(rc.order, child)
+	}
+	return l, nil
+}
+
+// copyComponent returns a copy of a component whose properties can be set
+// without altering the original one. Children are shared.
+func copyComponent(comp *ical.Component) *ical.Component {
+	props := make(ical.Props, len(comp.Props))
+	for name, l := range comp.Props {
+		props[name] = append([]ical.Prop(nil), l...)
+	}
+	return &ical.Component{
+		Name:     comp.Name,
+		Props:    props,
+		Children: comp.Children,
+	}
+}
+
+// setTime sets a DATE or DATE-TIME property. DATE-TIME values are converted
+// to UTC.
+func setTime(props ical.Props, name string, t time.Time, date bool) {
+	if date {
+		props.SetDate(name, t)
+	} else {
+		props.SetDateTime(name, t.UTC())
+	}
+}
+
+// convertTimesToUTC converts the DTSTART, DTEND, DUE and RECURRENCE-ID
+// properties of a component to UTC.
+func convertTimesToUTC(comp *ical.Component) error {
+	for _, name := range []string{ical.PropDateTimeStart, ical.PropDateTimeEnd, ical.PropDue, ical.PropRecurrenceID} {
+		prop := comp.Props.Get(name)
+		if prop == nil || isDate(prop) {
+			continue
+		}
+		t, err := prop.DateTime(time.UTC)
+		if err != nil {
+			return err
+		}
+		comp.Props.SetDateTime(name, t.UTC())
+	}
+	return nil
+}
+
+// expandInstances returns the instances of a recurring component which
+// match the time range r, as defined in RFC 4791 section 9.9. Overridden
+// instances are replaced with their override. Each instance defines exactly
+// one occurrence, with its times in UTC.
+func expandInstances(rc *recurringComponent, r matchRange) ([]*ical.Component, error) {
+	var instances []*ical.Component
+
+	if master := rc.master; master != nil {
+		ct, err := parseCompTimes(master)
+		if err != nil {
+			return nil, err
+		}
+
+		set, err := recurrenceSet(master, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+
+		if set == nil {
+			if r.matchOccurrence(master.Name, *ct) {
+				inst := copyComponent(master)
+				if err := convertTimesToUTC(inst); err != nil {
+					return nil, err
+				}
+				instances = append(instances, inst)
+			}
+		} else {
+			endName := ical.PropDateTimeEnd
+			if master.Name == ical.CompToDo {
+				endName = ical.PropDue
+			}
+
+			for _, t := range set.Between(r.start.Add(-ct.span()), r.end, true) {
+				if _, ok := rc.overrides[recurrenceID(t)]; ok {
+					continue
+				}
+				occ := ct.at(t)
+				if !r.matchOccurrence(master.Name, occ) {
+					continue
+				}
+
+				inst := copyComponent(master)
+				inst.Props.Del(ical.PropRecurrenceRule)
+				inst.Props.Del(ical.PropRecurrenceDates)
+				inst.Props.Del(ical.PropExceptionDates)
+				setTime(inst.Props, ical.PropDateTimeStart, t, ct.startIsDate)
+				if !occ.end.IsZero() {
+					setTime(inst.Props, endName, occ.end, ct.startIsDate)
+				}
+				setTime(inst.Props, ical.PropRecurrenceID, t, ct.startIsDate)
+				instances = append(instances, inst)
+			}
+		}
+	}
+
+	for _, override := range rc.order {
+		ct, err := parseCompTimes(override)
+		if err != nil {
+			return nil, err
+		}
+		if !r.matchOccurrence(override.Name, *ct) {
+			continue
+		}
+
+		inst := copyComponent(override)
+		inst.Props.Del(ical.PropRecurrenceRule)
+		inst.Props.Del(ical.PropRecurrenceDates)
+		inst.Props.Del(ical.PropExceptionDates)
+		if err := convertTimesToUTC(inst); err != nil {
+			return nil, err
+		}
+		instances = append(instances, inst)
+	}
+
+	// Sort instances by start time, keeping the master instances first on
+	// ties
+	var sortErr error
+	sort.SliceStable(instances, func(i, j int) bool {
+		ti, err := instances[i].Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		if err != nil {
+			sortErr = err
+		}
+		tj, err := instances[j].Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		if err != nil {
+			sortErr = err
+		}
+		return ti.Before(tj)
+	})
+	return instances, sortErr
+}
+
+// expandCalendar expands the recurring components of a calendar into
+// instances which match the time range [start, end), as defined in RFC 4791
+// sections 9.6.5 and 9.9. The returned calendar doesn't contain any VTIMEZONE, since
+// all times are converted to UTC.
+func expandCalendar(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	groups, err := groupRecurringComponents(cal)
+	if err != nil {
+		return nil, err
+	}
+
+	out := ical.NewCalendar()
+	out.Props = cal.Props
+	for _, child := range cal.Children {
+		if child.Name != ical.CompTimezone && !isRecurrable(child) {
+			out.Children = append(out.Children, child)
+		}
+	}
+	for _, rc := range groups {
+		instances, err := expandInstances(rc, matchRange{start, end})
+		if err != nil {
+			return nil, err
+		}
+		out.Children = append(out.Children, instances...)
+	}
+	return out, nil
+}
+
+// limitCalendarRecurrenceSet removes the overridden instances of recurring components
+// which don't match the time range [start, end), as defined in RFC 4791
+// sections 9.6.6 and 9.9. An override is kept if either the instance it
+// replaces or the override itself matches the time range.
+func limitCalendarRecurrenceSet(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	groups, err := groupRecurringComponents(cal)
+	if err != nil {
+		return nil, err
+	}
+
+	// The times of the master component, for each override
+	masterTimes := make(map[*ical.Component]*compTimes)
+	for _, rc := range groups {
+		var ct *compTimes
+		if rc.master != nil {
+			ct, err = parseCompTimes(rc.master)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, override := range rc.order {
+			masterTimes[override] = ct
+		}
+	}
+
+	r := matchRange{start, end}
+	out := ical.NewCalendar()
+	out.Props = cal.Props
+	for _, child := range cal.Children {
+		mct, ok := masterTimes[child]
+		if !ok {
+			out.Children = append(out.Children, child)
+			continue
+		}
+
+		ridProp := child.Props.Get(ical.PropRecurrenceID)
+		rid, err := ridProp.DateTime(time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		ct, err := parseCompTimes(child)
+		if err != nil {
+			return nil, err
+		}
+
+		keep := r.matchOccurrence(child.Name, *ct)
+		if mct != nil {
+			keep = keep || r.matchOccurrence(child.Name, mct.at(rid))
+		}
+		// An override of this and future instances affects the time range
+		// if it starts before its end
+		if strings.EqualFold(ridProp.Params.Get(ical.ParamRange), "THISANDFUTURE") {
+			keep = keep || r.endGT(rid)
+		}
+		if keep {
+			out.Children = append(out.Children, child)
+		}
+	}
+	return out, nil
+}
+
+// parsePeriod parses a PERIOD value, either explicit or with a duration.
+func parsePeriod(s string) (start, end time.Time, err error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("caldav: malformed period %q", s)
+	}
+
+	startProp := ical.NewProp(ical.PropDateTimeStart)
+	startProp.Value = s[:i]
+	start, err = startProp.DateTime(time.UTC)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if v := s[i+1:]; strings.HasPrefix(v, "P") || strings.HasPrefix(v, "+P") || strings.HasPrefix(v, "-P") {
+		durProp := ical.NewProp(ical.PropDuration)
+		durProp.Value = v
+		dur, err := durProp.Duration()
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = start.Add(dur)
+	} else {
+		endProp := ical.NewProp(ical.PropDateTimeEnd)
+		endProp.Value = v
+		end, err = endProp.DateTime(time.UTC)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return start, end, nil
+}
+
+// limitCalendarFreeBusySet removes the FREEBUSY periods of VFREEBUSY components which
+// don't overlap the time range [start, end), as defined in RFC 4791 section
+// 9.6.7.
+func limitCalendarFreeBusySet(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	r := matchRange{start, end}
+	out := ical.NewCalendar()
+	out.Props = cal.Props
+	for _, child := range cal.Children {
+		if child.Name != ical.CompFreeBusy {
+			out.Children = append(out.Children, child)
+			continue
+		}
+
+		comp := copyComponent(child)
+		var props []ical.Prop
+		for _, prop := range child.Props.Values(ical.PropFreeBusy) {
+			var periods []string
+			for _, v := range strings.Split(prop.Value, ",") {
+				periodStart, periodEnd, err := parsePeriod(v)
+				if err != nil {
+					return nil, err
+				}
+				if r.startLT(periodEnd) && r.endGT(periodStart) {
+					periods = append(periods, v)
+				}
+			}
+			if len(periods) > 0 {
+				prop.Value = strings.Join(periods, ",")
+				props = append(props, prop)
+			}
+		}
+		if len(props) > 0 {
+			comp.Props[ical.PropFreeBusy] = props
+		} else {
+			comp.Props.Del(ical.PropFreeBusy)
+		}
+		out.Children = append(out.Children, comp)
+	}
+	return out, nil
+}
@@ -0,0 +1,304 @@
+package caldav
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-ical"
+)
+
+func decodeTestCalendar(t *testing.T, s string) *ical.Calendar {
+	cal, err := ical.NewDecoder(strings.NewReader(strings.ReplaceAll(s, "\n", "\r\n"))).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+// instanceSummary formats the main properties of a component, to compare
+// expansion results.
+func instanceSummary(comp *ical.Component) string {
+	var l []string
+	for _, name := range []string{ical.PropRecurrenceID, ical.PropDateTimeStart, ical.PropDateTimeEnd, ical.PropDue, ical.PropSummary} {
+		if prop := comp.Props.Get(name); prop != nil {
+			l = append(l, name+"="+prop.Value)
+		}
+	}
+	for _, name := range []string{ical.PropRecurrenceRule, ical.PropRecurrenceDates, ical.PropExceptionDates} {
+		if comp.Props.Get(name) != nil {
+			l = append(l, name)
+		}
+	}
+	return comp.Name + " " + strings.Join(l, " ")
+}
+
+func calendarSummary(cal *ical.Calendar) []string {
+	var l []string
+	for _, child := range cal.Children {
+		l = append(l, instanceSummary(child))
+	}
+	return l
+}
+
+const testRecurringEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTIMEZONE
+TZID:Europe/Paris
+BEGIN:STANDARD
+DTSTART:19701025T030000
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:weekly@example.com
+DTSTAMP:20240101T000000Z
+DTSTART;TZID=Europe/Paris:20240108T100000
+DTEND;TZID=Europe/Paris:20240108T110000
+RRULE:FREQ=WEEKLY;COUNT=6
+EXDATE;TZID=Europe/Paris:20240115T100000,20240122T100000
+RDATE:20240131T150000Z
+SUMMARY:Weekly
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@example.com
+DTSTAMP:20240101T000000Z
+RECURRENCE-ID;TZID=Europe/Paris:20240129T100000
+DTSTART;TZID=Europe/Paris:20240129T140000
+DTEND;TZID=Europe/Paris:20240129T150000
+SUMMARY:Moved
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@example.com
+DTSTAMP:20240101T000000Z
+RECURRENCE-ID;TZID=Europe/Paris:20240205T100000
+DTSTART;TZID=Europe/Paris:20240301T100000
+DTEND;TZID=Europe/Paris:20240301T110000
+SUMMARY:Moved far
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestExpandCalendar(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		cal        string
+		start, end string
+		want       []string
+	}{
+		{
+			name:  "recurring",
+			cal:   testRecurringEvent,
+			start: "20240101T000000Z",
+			end:   "20240210T000000Z",
+			want: []string{
+				"VEVENT RECURRENCE-ID=20240108T090000Z DTSTART=20240108T090000Z DTEND=20240108T100000Z SUMMARY=Weekly",
+				"VEVENT RECURRENCE-ID=20240129T090000Z DTSTART=20240129T130000Z DTEND=20240129T140000Z SUMMARY=Moved",
+				"VEVENT RECURRENCE-ID=20240131T150000Z DTSTART=20240131T150000Z DTEND=20240131T160000Z SUMMARY=Weekly",
+			},
+		},
+		{
+			name:  "instance ending at range start",
+			cal:   testRecurringEvent,
+			start: "20240108T100000Z",
+			end:   "20240131T150000Z",
+			want: []string{
+				"VEVENT RECURRENCE-ID=20240129T090000Z DTSTART=20240129T130000Z DTEND=20240129T140000Z SUMMARY=Moved",
+			},
+		},
+		{
+			name:  "override moved into range",
+			cal:   testRecurringEvent,
+			start: "20240301T000000Z",
+			end:   "20240401T000000Z",
+			want: []string{
+				"VEVENT RECURRENCE-ID=20240205T090000Z DTSTART=20240301T090000Z DTEND=20240301T100000Z SUMMARY=Moved far",
+			},
+		},
+		{
+			name: "all-day",
+			cal: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:daily@example.com
+DTSTAMP:20240101T000000Z
+DTSTART;VALUE=DATE:20240101
+RRULE:FREQ=DAILY
+SUMMARY:Daily
+END:VEVENT
+END:VCALENDAR
+`,
+			start: "20240102T120000Z",
+			end:   "20240104T000000Z",
+			want: []string{
+				"VEVENT RECURRENCE-ID=20240102 DTSTART=20240102 SUMMARY=Daily",
+				"VEVENT RECURRENCE-ID=20240103 DTSTART=20240103 SUMMARY=Daily",
+			},
+		},
+		{
+			name: "rdate only",
+			cal: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+UID:todo@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T100000Z
+DURATION:PT30M
+RDATE:20240105T100000Z,20240110T100000Z
+SUMMARY:Todo
+END:VTODO
+END:VCALENDAR
+`,
+			start: "20240101T100000Z",
+			end:   "20240110T100000Z",
+			want: []string{
+				"VTODO RECURRENCE-ID=20240101T100000Z DTSTART=20240101T100000Z SUMMARY=Todo",
+				"VTODO RECURRENCE-ID=20240105T100000Z DTSTART=20240105T100000Z SUMMARY=Todo",
+			},
+		},
+		{
+			name: "not recurring",
+			cal: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:single@example.com
+DTSTAMP:20240101T000000Z
+DTSTART;TZID=Europe/Paris:20240108T100000
+DURATION:PT1H
+SUMMARY:Single
+END:VEVENT
+END:VCALENDAR
+`,
+			start: "20240108T000000Z",
+			end:   "20240109T000000Z",
+			want: []string{
+				"VEVENT DTSTART=20240108T090000Z SUMMARY=Single",
+			},
+		},
+		{
+			name: "not recurring outside range",
+			cal: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:single@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240108T100000Z
+SUMMARY:Single
+END:VEVENT
+END:VCALENDAR
+`,
+			start: "20240109T000000Z",
+			end:   "20240110T000000Z",
+			want:  nil,
+		},
+		{
+			name: "recurring todo",
+			cal: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+UID:daily@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T090000Z
+DUE:20240101T170000Z
+RRULE:FREQ=DAILY;COUNT=5
+SUMMARY:Daily
+END:VTODO
+BEGIN:VTODO
+UID:due@example.com
+DTSTAMP:20240101T000000Z
+DUE:20240102T180000Z
+SUMMARY:Due
+END:VTODO
+BEGIN:VTODO
+UID:completed@example.com
+DTSTAMP:20240101T000000Z
+COMPLETED:20240104T100000Z
+SUMMARY:Completed
+END:VTODO
+END:VCALENDAR
+`,
+			start: "20240102T170000Z",
+			end:   "20240103T100000Z",
+			want: []string{
+				"VTODO RECURRENCE-ID=20240103T090000Z DTSTART=20240103T090000Z DUE=20240103T170000Z SUMMARY=Daily",
+				"VTODO DUE=20240102T180000Z SUMMARY=Due",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cal := decodeTestCalendar(t, tc.cal)
+			orig := calendarSummary(cal)
+			got, err := expandCalendar(cal, toDate(t, tc.start), toDate(t, tc.end))
+			if err != nil {
+				t.Fatalf("expandCalendar() = %v", err)
+			}
+			if l := calendarSummary(got); !reflect.DeepEqual(l, tc.want) {
+				t.Errorf("expandCalendar() = \n%v\nwant:\n%v", strings.Join(l, "\n"), strings.Join(tc.want, "\n"))
+			}
+			if !reflect.DeepEqual(calendarSummary(cal), orig) {
+				t.Errorf("expandCalendar() altered the original calendar")
+			}
+		})
+	}
+}
+
+func TestLimitCalendarRecurrenceSet(t *testing.T) {
+	cal := decodeTestCalendar(t, testRecurringEvent)
+
+	got, err := limitCalendarRecurrenceSet(cal, toDate(t, "20240201T000000Z"), toDate(t, "20240210T000000Z"))
+	if err != nil {
+		t.Fatalf("limitCalendarRecurrenceSet() = %v", err)
+	}
+	want := []string{
+		"VTIMEZONE ",
+		"VEVENT DTSTART=20240108T100000 DTEND=20240108T110000 SUMMARY=Weekly RRULE RDATE EXDATE",
+		"VEVENT RECURRENCE-ID=20240205T100000 DTSTART=20240301T100000 DTEND=20240301T110000 SUMMARY=Moved far",
+	}
+	if l := calendarSummary(got); !reflect.DeepEqual(l, want) {
+		t.Errorf("limitCalendarRecurrenceSet() = \n%v\nwant:\n%v", strings.Join(l, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLimitCalendarFreeBusySet(t *testing.T) {
+	cal := decodeTestCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VFREEBUSY
+UID:fb@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T000000Z
+DTEND:20240201T000000Z
+FREEBUSY:20240102T100000Z/20240102T110000Z,20240110T100000Z/PT1H
+FREEBUSY;FBTYPE=BUSY-TENTATIVE:20240103T100000Z/PT2H
+FREEBUSY:20240120T100000Z/20240120T120000Z
+END:VFREEBUSY
+END:VCALENDAR
+`)
+
+	got, err := limitCalendarFreeBusySet(cal, toDate(t, "20240102T103000Z"), toDate(t, "20240115T000000Z"))
+	if err != nil {
+		t.Fatalf("limitCalendarFreeBusySet() = %v", err)
+	}
+	var values []string
+	for _, prop := range got.Children[0].Props.Values(ical.PropFreeBusy) {
+		values = append(values, prop.Value)
+	}
+	want := []string{
+		"20240102T100000Z/20240102T110000Z,20240110T100000Z/PT1H",
+		"20240103T100000Z/PT2H",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("limitCalendarFreeBusySet() = %v, want %v", values, want)
+	}
+	if l := cal.Children[0].Props.Values(ical.PropFreeBusy); len(l) != 3 {
+		t.Errorf("limitCalendarFreeBusySet() altered the original calendar")
+	}
+}
@@ -1,14 +1,19 @@
 package caldav
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/internal"
 )
 
 // Filter returns the filtered list of calendar objects matching the provided query.
 // A nil query will return the full list of calendar objects.
+//
+// The calendar data of the objects is left untouched: the CompRequest of the
+// query is applied by Handler when replying.
 func Filter(query *CalendarQuery, cos []CalendarObject) ([]CalendarObject, error) {
 	if query == nil {
 		// FIXME: should we always return a copy of the provided slice?
@@ -25,28 +30,109 @@ func Filter(query *CalendarQuery, cos []CalendarObject) ([]CalendarObject, error
 			continue
 		}
 
-		// TODO properties are not currently filtered even if requested
 		out = append(out, co)
 	}
 	return out, nil
 }
 
+// filterCalendarData applies a calendar-data request to a calendar object:
+// recurring components are expanded or limited to a time range, then
+// components and properties which weren't requested are removed. A request
+// without a component name leaves the components and properties untouched.
+func filterCalendarData(req *CalendarCompRequest, co *CalendarObject) (*CalendarObject, error) {
+	prune := req.Name != "" && !(req.AllProps && req.AllComps)
+	if co.Data == nil || (!prune && req.Expand == nil && req.LimitRecurrenceSet == nil && req.LimitFreeBusySet == nil) {
+		return co, nil
+	}
+
+	cal := co.Data
+	var err error
+	if req.Expand != nil {
+		cal, err = expandCalendar(cal, req.Expand.Start, req.Expand.End)
+	} else if req.LimitRecurrenceSet != nil {
+		cal, err = limitCalendarRecurrenceSet(cal, req.LimitRecurrenceSet.Start, req.LimitRecurrenceSet.End)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.LimitFreeBusySet != nil {
+		cal, err = limitCalendarFreeBusySet(cal, req.LimitFreeBusySet.Start, req.LimitFreeBusySet.End)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if prune {
+		cal = &ical.Calendar{Component: pruneComponent(req, cal.Component)}
+	}
+
+	result := *co
+	result.Data = cal
+	// The length of the original object doesn't apply anymore
+	result.ContentLength = 0
+	return &result, nil
+}
+
+// requiredProps lists the properties which are always kept when pruning
+// components, since the result would be invalid without them. RECURRENCE-ID
+// distinguishes overridden instances from the master component.
+var requiredProps = map[string][]string{
+	ical.CompCalendar:         {ical.PropProductID, ical.PropVersion},
+	ical.CompEvent:            {ical.PropDateTimeStamp, ical.PropUID, ical.PropRecurrenceID},
+	ical.CompToDo:             {ical.PropDateTimeStamp, ical.PropUID, ical.PropRecurrenceID},
+	ical.CompJournal:          {ical.PropDateTimeStamp, ical.PropUID, ical.PropRecurrenceID},
+	ical.CompFreeBusy:         {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompTimezone:         {ical.PropTimezoneID},
+	ical.CompTimezoneStandard: {ical.PropDateTimeStart, ical.PropTimezoneOffsetTo, ical.PropTimezoneOffsetFrom},
+	ical.CompTimezoneDaylight: {ical.PropDateTimeStart, ical.PropTimezoneOffsetTo, ical.PropTimezoneOffsetFrom},
+}
+
+// pruneComponent returns a copy of a component holding only the properties
+// and sub-components requested, as defined in RFC 4791 section 9.6.1.
+func pruneComponent(req *CalendarCompRequest, comp *ical.Component) *ical.Component {
+	out := ical.NewComponent(comp.Name)
+	if req.AllProps {
+		out.Props = comp.Props
+	} else {
+		for _, name := range append(requiredProps[comp.Name], req.Props...) {
+			if l := comp.Props.Values(name); len(l) > 0 {
+				out.Props[strings.ToUpper(name)] = l
+			}
+		}
+	}
+
+	if req.AllComps {
+		out.Children = comp.Children
+		return out
+	}
+	for _, child := range comp.Children {
+		for i := range req.Comps {
+			if strings.EqualFold(req.Comps[i].Name, child.Name) {
+				out.Children = append(out.Children, pruneComponent(&req.Comps[i], child))
+				break
+			}
+		}
+	}
+	return out
+}
+
 // Match reports whether the provided CalendarObject matches the query.
 func Match(query CompFilter, co *CalendarObject) (matched bool, err error) {
 	if co.Data == nil || co.Data.Component == nil {
 		panic("request to process empty calendar object")
 	}
-	return match(query, co.Data.Component)
+	return match(query, co.Data.Component, nil)
 }
 
-func match(filter CompFilter, comp *ical.Component) (bool, error) {
+// match reports whether a component matches a filter. parents holds the
+// ancestors of the component, closest last.
+func match(filter CompFilter, comp *ical.Component, parents []*ical.Component) (bool, error) {
 	if comp.Name != filter.Name {
 		return filter.IsNotDefined, nil
 	}
 
-	var zeroDate time.Time
-	if filter.Start != zeroDate {
-		match, err := matchCompTimeRange(filter.Start, filter.End, comp)
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		r := matchRange{start: filter.Start, end: filter.End}
+		match, err := matchCompTimeRange(r, comp, parents)
 		if err != nil {
 			return false, err
 		}
@@ -55,7 +141,7 @@ func match(filter CompFilter, comp *ical.Component) (bool, error) {
 		}
 	}
 	for _, compFilter := range filter.Comps {
-		match, err := matchCompFilter(compFilter, comp)
+		match, err := matchCompFilter(compFilter, comp, parents)
 		if err != nil {
 			return false, err
 		}
@@ -75,11 +161,12 @@ func match(filter CompFilter, comp *ical.Component) (bool, error) {
 	return true, nil
 }
 
-func matchCompFilter(filter CompFilter, comp *ical.Component) (bool, error) {
+func matchCompFilter(filter CompFilter, comp *ical.Component, parents []*ical.Component) (bool, error) {
 	var matches []*ical.Component
 
+	childParents := append(parents[:len(parents):len(parents)], comp)
 	for _, child := range comp.Children {
-		match, err := match(filter, child)
+		match, err := match(filter, child, childParents)
 		if err != nil {
 			return false, err
 		} else if match {
@@ -100,14 +187,18 @@ func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
 	}
 
 	for _, paramFilter := range filter.ParamFilter {
-		if !matchParamFilter(paramFilter, field) {
+		match, err := matchParamFilter(paramFilter, field)
+		if err != nil {
+			return false, err
+		}
+		if !match {
 			return false, nil
 		}
 	}
 
-	var zeroDate time.Time
-	if filter.Start != zeroDate {
-		match, err := matchPropTimeRange(filter.Start, filter.End, field)
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		r := matchRange{start: filter.Start, end: filter.End}
+		match, err := matchPropTimeRange(r, field)
 		if err != nil {
 			return false, err
 		}
@@ -115,7 +206,11 @@ func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
 			return false, nil
 		}
 	} else if filter.TextMatch != nil {
-		if !matchTextMatch(*filter.TextMatch, field.Value) {
+		match, err := matchTextMatch(*filter.TextMatch, field.Value)
+		if err != nil {
+			return false, err
+		}
+		if !match {
 			return false, nil
 		}
 		return true, nil
@@ -124,82 +219,447 @@ func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
 	return true, nil
 }
 
-func matchCompTimeRange(start, end time.Time, comp *ical.Component) (bool, error) {
-	// See https://datatracker.ietf.org/doc/html/rfc4791#section-9.9
+// matchRange is the time range of a filter. The start is inclusive, the end
+// is exclusive. A zero start or end leaves the time range open.
+//
+// The methods are named after the comparisons in RFC 4791 section 9.9, and
+// always hold for an open bound.
+type matchRange struct {
+	start, end time.Time
+}
+
+// startLT reports whether start < t.
+func (r matchRange) startLT(t time.Time) bool {
+	return r.start.IsZero() || r.start.Before(t)
+}
 
-	// evaluate recurring components
-	rset, err := comp.RecurrenceSet(start.Location())
+// startLE reports whether start <= t.
+func (r matchRange) startLE(t time.Time) bool {
+	return r.start.IsZero() || !r.start.After(t)
+}
+
+// endGT reports whether end > t.
+func (r matchRange) endGT(t time.Time) bool {
+	return r.end.IsZero() || r.end.After(t)
+}
+
+// endGE reports whether end >= t.
+func (r matchRange) endGE(t time.Time) bool {
+	return r.end.IsZero() || !r.end.Before(t)
+}
+
+// compTimes holds the time properties of an occurrence of a component. Zero
+// values indicate missing properties.
+type compTimes struct {
+	start       time.Time // DTSTART
+	startIsDate bool
+	end         time.Time // DTEND, or DUE for VTODO
+	duration    time.Duration
+	hasDuration bool
+	completed   time.Time
+	created     time.Time
+}
+
+func parseCompTimes(comp *ical.Component) (*compTimes, error) {
+	var ct compTimes
+	var err error
+	if prop := comp.Props.Get(ical.PropDateTimeStart); prop != nil {
+		if ct.start, err = prop.DateTime(time.UTC); err != nil {
+			return nil, err
+		}
+		ct.startIsDate = isDate(prop)
+	}
+
+	endName := ical.PropDateTimeEnd
+	if comp.Name == ical.CompToDo {
+		endName = ical.PropDue
+	}
+	if ct.end, err = comp.Props.DateTime(endName, time.UTC); err != nil {
+		return nil, err
+	}
+
+	if prop := comp.Props.Get(ical.PropDuration); prop != nil {
+		if ct.duration, err = prop.Duration(); err != nil {
+			return nil, err
+		}
+		ct.hasDuration = true
+	}
+
+	if ct.completed, err = comp.Props.DateTime(ical.PropCompleted, time.UTC); err != nil {
+		return nil, err
+	}
+	if ct.created, err = comp.Props.DateTime(ical.PropCreated, time.UTC); err != nil {
+		return nil, err
+	}
+	return &ct, nil
+}
+
+// at returns the times of the occurrence starting at t.
+func (ct compTimes) at(t time.Time) compTimes {
+	if !ct.end.IsZero() {
+		ct.end = ct.end.Add(t.Sub(ct.start))
+	}
+	ct.start = t
+	return ct
+}
+
+// span returns how long after its start an occurrence can overlap a time
+// range.
+func (ct compTimes) span() time.Duration {
+	var d time.Duration
+	switch {
+	case !ct.end.IsZero():
+		d = ct.end.Sub(ct.start)
+	case ct.hasDuration:
+		d = ct.duration
+	case ct.startIsDate:
+		d = 24 * time.Hour
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// matchEvent evaluates the VEVENT time range table of RFC 4791 section 9.9.
+func (r matchRange) matchEvent(ct compTimes) bool {
+	switch {
+	case ct.start.IsZero():
+		return false
+	case !ct.end.IsZero():
+		return r.startLT(ct.end) && r.endGT(ct.start)
+	case ct.hasDuration && ct.duration > 0:
+		return r.startLT(ct.start.Add(ct.duration)) && r.endGT(ct.start)
+	case ct.hasDuration:
+		return r.startLE(ct.start) && r.endGT(ct.start)
+	case ct.startIsDate:
+		return r.startLT(ct.start.AddDate(0, 0, 1)) && r.endGT(ct.start)
+	default:
+		return r.startLE(ct.start) && r.endGT(ct.start)
+	}
+}
+
+// matchToDo evaluates the VTODO time range table of RFC 4791 section 9.9.
+func (r matchRange) matchToDo(ct compTimes) bool {
+	hasStart, due := !ct.start.IsZero(), ct.end
+	switch {
+	case hasStart && ct.hasDuration:
+		end := ct.start.Add(ct.duration)
+		return r.startLE(end) && (r.endGT(ct.start) || r.endGE(end))
+	case hasStart && !due.IsZero():
+		return (r.startLT(due) || r.startLE(ct.start)) && (r.endGT(ct.start) || r.endGE(due))
+	case hasStart:
+		return r.startLE(ct.start) && r.endGT(ct.start)
+	case !due.IsZero():
+		return r.startLT(due) && r.endGE(due)
+	case !ct.completed.IsZero() && !ct.created.IsZero():
+		return (r.startLE(ct.created) || r.startLE(ct.completed)) && (r.endGE(ct.created) || r.endGE(ct.completed))
+	case !ct.completed.IsZero():
+		return r.startLE(ct.completed) && r.endGE(ct.completed)
+	case !ct.created.IsZero():
+		return r.endGT(ct.created)
+	default:
+		return true
+	}
+}
+
+// matchJournal evaluates the VJOURNAL time range table of RFC 4791 section
+// 9.9.
+func (r matchRange) matchJournal(ct compTimes) bool {
+	switch {
+	case ct.start.IsZero():
+		return false
+	case ct.startIsDate:
+		return r.startLT(ct.start.AddDate(0, 0, 1)) && r.endGT(ct.start)
+	default:
+		return r.startLE(ct.start) && r.endGT(ct.start)
+	}
+}
+
+// matchOccurrence evaluates the time range table of RFC 4791 section 9.9
+// matching an occurrence of a VEVENT, VTODO or VJOURNAL component.
+func (r matchRange) matchOccurrence(compName string, ct compTimes) bool {
+	switch compName {
+	case ical.CompToDo:
+		return r.matchToDo(ct)
+	case ical.CompJournal:
+		return r.matchJournal(ct)
+	default:
+		return r.matchEvent(ct)
+	}
+}
+
+// matchFreeBusy evaluates the VFREEBUSY time range table of RFC 4791 section
+// 9.9.
+func (r matchRange) matchFreeBusy(comp *ical.Component) (bool, error) {
+	ct, err := parseCompTimes(comp)
 	if err != nil {
 		return false, err
 	}
-	if rset != nil {
-		// TODO we can only set inclusive to true or false, but really the
-		// start time is inclusive while the end time is not :/
-		return len(rset.Between(start, end, true)) > 0, nil
+	if !ct.start.IsZero() && !ct.end.IsZero() {
+		return r.startLE(ct.end) && r.endGT(ct.start), nil
 	}
 
-	// TODO handle more than just events
-	if comp.Name != ical.CompEvent {
-		return false, nil
+	for _, prop := range comp.Props.Values(ical.PropFreeBusy) {
+		for _, v := range strings.Split(prop.Value, ",") {
+			start, end, err := parsePeriod(v)
+			if err != nil {
+				return false, err
+			}
+			if r.startLT(end) && r.endGT(start) {
+				return true, nil
+			}
+		}
 	}
-	event := ical.Event{comp}
+	return false, nil
+}
+
+// alarmTrigger is the TRIGGER of a VALARM, along with its repetitions.
+type alarmTrigger struct {
+	// date is set for absolute triggers
+	date time.Time
+	// offset is relative to the start of the parent component, or its end if
+	// relatedEnd is set
+	offset     time.Duration
+	relatedEnd bool
+
+	repeat   int
+	interval time.Duration
+}
+
+// parseAlarmTrigger parses the trigger of a VALARM. It returns nil if the
+// alarm has no trigger.
+func parseAlarmTrigger(alarm *ical.Component) (*alarmTrigger, error) {
+	prop := alarm.Props.Get(ical.PropTrigger)
+	if prop == nil {
+		return nil, nil
+	}
+
+	var tr alarmTrigger
+	var err error
+	if prop.ValueType() == ical.ValueDateTime {
+		if tr.date, err = prop.DateTime(time.UTC); err != nil {
+			return nil, err
+		}
+	} else {
+		if tr.offset, err = prop.Duration(); err != nil {
+			return nil, err
+		}
+		tr.relatedEnd = strings.EqualFold(prop.Params.Get(ical.ParamRelated), "END")
+	}
+
+	repeatProp := alarm.Props.Get(ical.PropRepeat)
+	durProp := alarm.Props.Get(ical.PropDuration)
+	if repeatProp != nil && durProp != nil {
+		if tr.repeat, err = repeatProp.Int(); err != nil {
+			return nil, err
+		}
+		if tr.interval, err = durProp.Duration(); err != nil {
+			return nil, err
+		}
+	}
+	return &tr, nil
+}
 
-	eventStart, err := event.DateTimeStart(start.Location())
+// times returns the trigger times of the alarm for an occurrence of its
+// parent component.
+func (tr *alarmTrigger) times(parent compTimes) []time.Time {
+	first := tr.date
+	if first.IsZero() {
+		base := parent.start
+		if tr.relatedEnd {
+			switch {
+			case !parent.end.IsZero():
+				base = parent.end
+			case parent.hasDuration:
+				base = parent.start.Add(parent.duration)
+			case parent.startIsDate:
+				base = parent.start.AddDate(0, 0, 1)
+			}
+		}
+		if base.IsZero() {
+			return nil
+		}
+		first = base.Add(tr.offset)
+	}
+
+	l := []time.Time{first}
+	for i := 1; i <= tr.repeat; i++ {
+		l = append(l, first.Add(time.Duration(i)*tr.interval))
+	}
+	return l
+}
+
+// overriddenOccurrences returns the recurrence IDs of the occurrences of a
+// recurring component which are overridden by other components of parent.
+func overriddenOccurrences(comp, parent *ical.Component) (map[int64]bool, error) {
+	if parent == nil {
+		return nil, nil
+	}
+	uid := comp.Props.Get(ical.PropUID)
+	if uid == nil {
+		return nil, nil
+	}
+
+	overridden := make(map[int64]bool)
+	for _, sibling := range parent.Children {
+		if sibling.Name != comp.Name {
+			continue
+		}
+		if siblingUID := sibling.Props.Get(ical.PropUID); siblingUID == nil || siblingUID.Value != uid.Value {
+			continue
+		}
+		ridProp := sibling.Props.Get(ical.PropRecurrenceID)
+		if ridProp == nil {
+			continue
+		}
+		rid, err := ridProp.DateTime(time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		overridden[recurrenceID(rid)] = true
+	}
+	return overridden, nil
+}
+
+// maxMatchOccurrences is the maximum number of occurrences of a recurring
+// component evaluated against a time range. Later occurrences don't match.
+const maxMatchOccurrences = 100000
+
+// matchOccurrences reports whether fn returns true for an occurrence of a
+// component. Occurrences overridden by other components of parent are
+// skipped. lead is how long before its start an occurrence can match the time
+// range.
+func matchOccurrences(r matchRange, comp, parent *ical.Component, lead time.Duration, fn func(ct compTimes) bool) (bool, error) {
+	ct, err := parseCompTimes(comp)
 	if err != nil {
 		return false, err
 	}
-	eventEnd, err := event.DateTimeEnd(end.Location())
+
+	set, err := recurrenceSet(comp, time.UTC)
 	if err != nil {
 		return false, err
 	}
-
-	// Event starts in time range
-	if eventStart.After(start) && (end.IsZero() || eventStart.Before(end)) {
-		return true, nil
+	if set == nil {
+		return fn(*ct), nil
 	}
-	// Event ends in time range
-	if eventEnd.After(start) && (end.IsZero() || eventEnd.Before(end)) {
-		return true, nil
+
+	overridden, err := overriddenOccurrences(comp, parent)
+	if err != nil {
+		return false, err
 	}
-	// Event covers entire time range plus some
-	if eventStart.Before(start) && (!end.IsZero() && eventEnd.After(end)) {
-		return true, nil
+
+	next := set.Iterator()
+	i := 0
+	for t, ok := next(); ok && i < maxMatchOccurrences; t, ok = next() {
+		i++
+		if !r.end.IsZero() && !t.Add(-lead).Before(r.end) {
+			break
+		}
+		if overridden[recurrenceID(t)] {
+			continue
+		}
+		if fn(ct.at(t)) {
+			return true, nil
+		}
+		// Without an end, the time range only compares its start with
+		// the times of occurrences. Once an occurrence is entirely after
+		// it, later occurrences match the same way.
+		if r.end.IsZero() && t.Add(-lead).After(r.start.Add(ct.span())) {
+			break
+		}
 	}
 	return false, nil
 }
 
-func matchPropTimeRange(start, end time.Time, field *ical.Prop) (bool, error) {
+// matchCompTimeRange evaluates a time range on a component, as defined in
+// RFC 4791 section 9.9. Recurring components match if any of their
+// occurrences does. VALARM components are evaluated against the occurrences
+// of their parent component.
+func matchCompTimeRange(r matchRange, comp *ical.Component, parents []*ical.Component) (bool, error) {
+	var parent, grandparent *ical.Component
+	if n := len(parents); n > 0 {
+		parent = parents[n-1]
+		if n > 1 {
+			grandparent = parents[n-2]
+		}
+	}
+
+	switch comp.Name {
+	case ical.CompEvent:
+		return matchOccurrences(r, comp, parent, 0, r.matchEvent)
+	case ical.CompToDo:
+		return matchOccurrences(r, comp, parent, 0, r.matchToDo)
+	case ical.CompJournal:
+		return matchOccurrences(r, comp, parent, 0, r.matchJournal)
+	case ical.CompFreeBusy:
+		return r.matchFreeBusy(comp)
+	case ical.CompAlarm:
+		tr, err := parseAlarmTrigger(comp)
+		if err != nil || tr == nil || parent == nil {
+			return false, err
+		}
+		matchTriggers := func(ct compTimes) bool {
+			for _, t := range tr.times(ct) {
+				if r.startLE(t) && r.endGT(t) {
+					return true
+				}
+			}
+			return false
+		}
+		if !tr.date.IsZero() {
+			return matchTriggers(compTimes{}), nil
+		}
+
+		var lead time.Duration
+		if tr.offset < 0 {
+			lead = -tr.offset
+		}
+		return matchOccurrences(r, parent, grandparent, lead, matchTriggers)
+	default:
+		return false, nil
+	}
+}
+
+// matchPropTimeRange evaluates a time range on a DATE or DATE-TIME property.
+func matchPropTimeRange(r matchRange, field *ical.Prop) (bool, error) {
 	// See https://datatracker.ietf.org/doc/html/rfc4791#section-9.9
 
-	ptime, err := field.DateTime(start.Location())
+	ptime, err := field.DateTime(time.UTC)
 	if err != nil {
 		return false, err
 	}
-	if ptime.After(start) && (end.IsZero() || ptime.Before(end)) {
-		return true, nil
-	}
-	return false, nil
+	return r.startLE(ptime) && r.endGT(ptime), nil
 }
 
-func matchParamFilter(filter ParamFilter, field *ical.Prop) bool {
+func matchParamFilter(filter ParamFilter, field *ical.Prop) (bool, error) {
 	// TODO there can be multiple values
 	value := field.Params.Get(filter.Name)
 	if value == "" {
-		return filter.IsNotDefined
+		return filter.IsNotDefined, nil
 	} else if filter.IsNotDefined {
-		return false
+		return false, nil
 	}
 	if filter.TextMatch != nil {
 		return matchTextMatch(*filter.TextMatch, value)
 	}
-	return true
+	return true, nil
 }
 
-func matchTextMatch(txt TextMatch, value string) bool {
-	// TODO: handle text-match collation attribute
-	match := strings.Contains(value, txt.Text)
+func matchTextMatch(txt TextMatch, value string) (bool, error) {
+	collation := txt.Collation
+	if collation == "" {
+		collation = CollationASCIICaseMap
+	}
+	text, ok := internal.FoldCollation(string(collation), txt.Text)
+	if !ok {
+		return false, fmt.Errorf("caldav: unsupported text-match collation %q", collation)
+	}
+	value, _ = internal.FoldCollation(string(collation), value)
+
+	match := strings.Contains(value, text)
 	if txt.NegateCondition {
 		match = !match
 	}
-	return match
+	return match, nil
 }
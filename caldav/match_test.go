@@ -1,6 +1,7 @@
 package caldav
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -270,6 +271,63 @@ END:VCALENDAR`)
 			addrs: []CalendarObject{event1, event2, event3, todo1},
 			want:  []CalendarObject{event1},
 		},
+		{
+			name: "events by case-insensitive description substring",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{
+						CompFilter{
+							Name: "VEVENT",
+							Props: []PropFilter{{
+								Name:      "Description",
+								TextMatch: &TextMatch{Text: "STEELERS"},
+							}},
+						},
+					},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, todo1},
+			want:  []CalendarObject{event1},
+		},
+		{
+			name: "events by case-sensitive description substring",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{
+						CompFilter{
+							Name: "VEVENT",
+							Props: []PropFilter{{
+								Name:      "Description",
+								TextMatch: &TextMatch{Text: "STEELERS", Collation: CollationOctet},
+							}},
+						},
+					},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, todo1},
+			want:  nil,
+		},
+		{
+			name: "unsupported collation",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{
+						CompFilter{
+							Name: "VEVENT",
+							Props: []PropFilter{{
+								Name:      "Description",
+								TextMatch: &TextMatch{Text: "Steelers", Collation: "i;basic"},
+							}},
+						},
+					},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, todo1},
+			err:   fmt.Errorf("caldav: unsupported text-match collation \"i;basic\""),
+		},
 		{
 			// Query a time range that only returns a result if recurrence is properly evaluated.
 			name: "recurring events in time range",
@@ -309,3 +367,306 @@ END:VCALENDAR`)
 		})
 	}
 }
+
+func TestFilterCalendarData(t *testing.T) {
+	co := CalendarObject{
+		Path:          "/user/calendars/cal/weekly.ics",
+		ContentLength: 1234,
+		Data:          decodeTestCalendar(t, testRecurringEvent),
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  CalendarCompRequest
+		want []string
+	}{
+		{
+			name: "no request",
+			req:  CalendarCompRequest{},
+			want: calendarSummary(co.Data),
+		},
+		{
+			name: "all",
+			req:  CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+			want: calendarSummary(co.Data),
+		},
+		{
+			name: "props of events",
+			req: CalendarCompRequest{
+				Name:  "VCALENDAR",
+				Props: []string{"VERSION"},
+				Comps: []CalendarCompRequest{{
+					Name:  "VEVENT",
+					Props: []string{"summary", "DTSTART"},
+				}},
+			},
+			want: []string{
+				"VEVENT DTSTART=20240108T100000 SUMMARY=Weekly",
+				"VEVENT RECURRENCE-ID=20240129T100000 DTSTART=20240129T140000 SUMMARY=Moved",
+				"VEVENT RECURRENCE-ID=20240205T100000 DTSTART=20240301T100000 SUMMARY=Moved far",
+			},
+		},
+		{
+			name: "timezones only",
+			req: CalendarCompRequest{
+				Name:  "VCALENDAR",
+				Comps: []CalendarCompRequest{{Name: "VTIMEZONE", AllProps: true, AllComps: true}},
+			},
+			want: []string{"VTIMEZONE "},
+		},
+		{
+			name: "expanded events",
+			req: CalendarCompRequest{
+				Name:     "VCALENDAR",
+				AllProps: true,
+				Comps: []CalendarCompRequest{{
+					Name:  "VEVENT",
+					Props: []string{"RECURRENCE-ID", "SUMMARY"},
+				}},
+				Expand: &CalendarExpandRequest{
+					Start: toDate(t, "20240101T000000Z"),
+					End:   toDate(t, "20240115T000000Z"),
+				},
+			},
+			want: []string{"VEVENT RECURRENCE-ID=20240108T090000Z SUMMARY=Weekly"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := filterCalendarData(&tc.req, &co)
+			if err != nil {
+				t.Fatalf("filterCalendarData() = %v", err)
+			}
+			if l := calendarSummary(got.Data); !reflect.DeepEqual(l, tc.want) {
+				t.Errorf("filterCalendarData() = \n%v\nwant:\n%v", strings.Join(l, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+
+	req := CalendarCompRequest{
+		Name:  "VCALENDAR",
+		Props: []string{"VERSION"},
+	}
+	got, err := filterCalendarData(&req, &co)
+	if err != nil {
+		t.Fatalf("filterCalendarData() = %v", err)
+	}
+	// PRODID is required, so it's always returned
+	if len(got.Data.Props) != 2 || got.Data.Props.Get(ical.PropProductID) == nil || len(got.Data.Children) != 0 {
+		t.Errorf("filterCalendarData() = %+v", got.Data.Component)
+	}
+	if got.ContentLength != 0 || co.ContentLength != 1234 || len(co.Data.Children) != 4 {
+		t.Errorf("filterCalendarData() altered the original object")
+	}
+}
+
+func TestMatchTimeRange(t *testing.T) {
+	const (
+		start = "20240110T100000Z"
+		end   = "20240110T120000Z"
+	)
+	event := func(props string) string {
+		return "BEGIN:VEVENT\nUID:event@example.com\nDTSTAMP:20240101T000000Z\n" + props + "END:VEVENT\n"
+	}
+	todo := func(props string) string {
+		return "BEGIN:VTODO\nUID:todo@example.com\nDTSTAMP:20240101T000000Z\n" + props + "END:VTODO\n"
+	}
+	journal := func(props string) string {
+		return "BEGIN:VJOURNAL\nUID:journal@example.com\nDTSTAMP:20240101T000000Z\n" + props + "END:VJOURNAL\n"
+	}
+	freeBusy := func(props string) string {
+		return "BEGIN:VFREEBUSY\nUID:fb@example.com\nDTSTAMP:20240101T000000Z\n" + props + "END:VFREEBUSY\n"
+	}
+	alarm := func(props string) string {
+		return "BEGIN:VALARM\nACTION:DISPLAY\nDESCRIPTION:Reminder\n" + props + "END:VALARM\n"
+	}
+	// eventAlarm is an event from 11:00 to 12:00 with an alarm
+	eventAlarm := func(props string) string {
+		return event("DTSTART:20240110T110000Z\nDURATION:PT1H\n" + alarm(props))
+	}
+
+	for _, tc := range []struct {
+		name       string
+		data       string
+		comps      []string
+		start, end string
+		want       bool
+	}{
+		// VEVENT with DTEND
+		{"event ending at start", event("DTSTART:20240110T090000Z\nDTEND:20240110T100000Z\n"), nil, start, end, false},
+		{"event ending after start", event("DTSTART:20240110T090000Z\nDTEND:20240110T100100Z\n"), nil, start, end, true},
+		{"event starting at end", event("DTSTART:20240110T120000Z\nDTEND:20240110T130000Z\n"), nil, start, end, false},
+		{"event starting before end", event("DTSTART:20240110T115900Z\nDTEND:20240110T130000Z\n"), nil, start, end, true},
+		{"event covering range", event("DTSTART:20240110T080000Z\nDTEND:20240110T130000Z\n"), nil, start, end, true},
+		{"event within range", event("DTSTART:20240110T103000Z\nDTEND:20240110T110000Z\n"), nil, start, end, true},
+		{"all-day event with end", event("DTSTART;VALUE=DATE:20240110\nDTEND;VALUE=DATE:20240111\n"), nil, start, end, true},
+		{"event with TZID", event("DTSTART;TZID=Europe/Paris:20240110T113000\nDTEND;TZID=Europe/Paris:20240110T114000\n"), nil, start, end, true},
+		// VEVENT with DURATION
+		{"event with duration ending at start", event("DTSTART:20240110T090000Z\nDURATION:PT1H\n"), nil, start, end, false},
+		{"event with duration ending after start", event("DTSTART:20240110T093000Z\nDURATION:PT1H\n"), nil, start, end, true},
+		{"event with zero duration at start", event("DTSTART:20240110T100000Z\nDURATION:PT0S\n"), nil, start, end, true},
+		{"event with zero duration at end", event("DTSTART:20240110T120000Z\nDURATION:PT0S\n"), nil, start, end, false},
+		// VEVENT with DTSTART only
+		{"instant event at start", event("DTSTART:20240110T100000Z\n"), nil, start, end, true},
+		{"instant event before start", event("DTSTART:20240110T095900Z\n"), nil, start, end, false},
+		{"instant event at end", event("DTSTART:20240110T120000Z\n"), nil, start, end, false},
+		{"all-day event", event("DTSTART;VALUE=DATE:20240110\n"), nil, start, end, true},
+		{"all-day event on the day before", event("DTSTART;VALUE=DATE:20240109\n"), nil, start, end, false},
+		{"all-day event on the day after", event("DTSTART;VALUE=DATE:20240111\n"), nil, start, end, false},
+		{"event without DTSTART", event("SUMMARY:No date\n"), nil, start, end, false},
+		// Open time ranges
+		{"event before open start", event("DTSTART:20240110T080000Z\nDURATION:PT1H\n"), nil, "", end, true},
+		{"event at end with open start", event("DTSTART:20240110T120000Z\nDURATION:PT1H\n"), nil, "", end, false},
+		{"event before start with open end", event("DTSTART:20240110T090000Z\nDURATION:PT1H\n"), nil, start, "", false},
+		{"event after start with open end", event("DTSTART:20240210T090000Z\nDURATION:PT1H\n"), nil, start, "", true},
+		{"infinite daily event with open end", event("DTSTART:20230110T090000Z\nDURATION:PT30M\nRRULE:FREQ=DAILY\n"), nil, start, "", true},
+		{"infinite event with too many occurrences before open end", event("DTSTART:20240101T000000Z\nRRULE:FREQ=SECONDLY\n"), nil, "20300101T000000Z", "", false},
+		// Recurring VEVENT
+		{"daily event", event("DTSTART:20240101T100000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"), nil, start, end, true},
+		{"daily event outside range", event("DTSTART:20240101T130000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"), nil, start, end, false},
+		{"daily event ending at start", event("DTSTART:20240101T090000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"), nil, start, end, false},
+		{"daily event starting at end", event("DTSTART:20240101T120000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"), nil, start, end, false},
+		{"daily instant event at start", event("DTSTART:20240101T100000Z\nRRULE:FREQ=DAILY\n"), nil, start, end, true},
+		{"daily event before open end", event("DTSTART:20240101T090000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"), nil, start, "", true},
+		{"daily event after open start", event("DTSTART:20240101T090000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"), nil, "", end, true},
+		{"finished daily event", event("DTSTART:20240101T100000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY;COUNT=5\n"), nil, start, end, false},
+		{"excluded occurrence", event("DTSTART:20240101T100000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\nEXDATE:20240109T100000Z,20240110T100000Z\n"), nil, start, end, false},
+		{"recurrence date", event("DTSTART:20240101T100000Z\nDURATION:PT1H\nRDATE:20240105T100000Z,20240110T103000Z\n"), nil, start, end, true},
+		{
+			"overridden occurrence moved out",
+			event("DTSTART:20240101T100000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n") +
+				event("RECURRENCE-ID:20240110T100000Z\nDTSTART:20240115T100000Z\nDURATION:PT1H\n"),
+			nil, start, end, false,
+		},
+		{
+			"overridden occurrence moved in",
+			event("DTSTART:20240101T100000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY;COUNT=3\n") +
+				event("RECURRENCE-ID:20240102T100000Z\nDTSTART:20240110T103000Z\nDURATION:PT1H\n"),
+			nil, start, end, true,
+		},
+		// VTODO
+		{"todo with duration ending at start", todo("DTSTART:20240110T090000Z\nDURATION:PT1H\n"), nil, start, end, true},
+		{"todo with duration ending before start", todo("DTSTART:20240110T080000Z\nDURATION:PT1H\n"), nil, start, end, false},
+		{"todo with duration starting at end", todo("DTSTART:20240110T120000Z\nDURATION:PT1H\n"), nil, start, end, false},
+		{"todo with duration within range", todo("DTSTART:20240110T103000Z\nDURATION:PT1H\n"), nil, start, end, true},
+		{"todo with due before start", todo("DTSTART:20240110T080000Z\nDUE:20240110T090000Z\n"), nil, start, end, false},
+		{"todo with due at start", todo("DTSTART:20240110T060000Z\nDUE:20240110T100000Z\n"), nil, start, end, false},
+		{"todo with due after end", todo("DTSTART:20240110T103000Z\nDUE:20240110T130000Z\n"), nil, start, end, true},
+		{"todo with due starting at end", todo("DTSTART:20240110T120000Z\nDUE:20240110T130000Z\n"), nil, start, end, false},
+		{"todo starting at start", todo("DTSTART:20240110T100000Z\n"), nil, start, end, true},
+		{"todo starting at end", todo("DTSTART:20240110T120000Z\n"), nil, start, end, false},
+		{"todo due at start", todo("DUE:20240110T100000Z\n"), nil, start, end, false},
+		{"todo due in range", todo("DUE:20240110T110000Z\n"), nil, start, end, true},
+		{"todo due at end", todo("DUE:20240110T120000Z\n"), nil, start, end, true},
+		{"todo due after end", todo("DUE:20240110T120100Z\n"), nil, start, end, false},
+		{"todo created and completed in range", todo("CREATED:20240101T000000Z\nCOMPLETED:20240110T110000Z\n"), nil, start, end, true},
+		{"todo created and completed before range", todo("CREATED:20240101T000000Z\nCOMPLETED:20240105T000000Z\n"), nil, start, end, false},
+		{"todo created and completed after range", todo("CREATED:20240111T000000Z\nCOMPLETED:20240112T000000Z\n"), nil, start, end, false},
+		{"todo completed at start", todo("COMPLETED:20240110T100000Z\n"), nil, start, end, true},
+		{"todo completed at end", todo("COMPLETED:20240110T120000Z\n"), nil, start, end, true},
+		{"todo completed before start", todo("COMPLETED:20240110T095900Z\n"), nil, start, end, false},
+		{"todo completed after end", todo("COMPLETED:20240110T120100Z\n"), nil, start, end, false},
+		{"todo created before end", todo("CREATED:20240101T000000Z\n"), nil, start, end, true},
+		{"todo created at end", todo("CREATED:20240110T120000Z\n"), nil, start, end, false},
+		{"todo without dates", todo("SUMMARY:Someday\n"), nil, start, end, true},
+		{"daily todo", todo("DTSTART:20240101T103000Z\nDUE:20240101T110000Z\nRRULE:FREQ=DAILY\n"), nil, start, end, true},
+		// VJOURNAL
+		{"journal at start", journal("DTSTART:20240110T100000Z\n"), nil, start, end, true},
+		{"journal before start", journal("DTSTART:20240110T090000Z\n"), nil, start, end, false},
+		{"journal at end", journal("DTSTART:20240110T120000Z\n"), nil, start, end, false},
+		{"all-day journal", journal("DTSTART;VALUE=DATE:20240110\n"), nil, start, end, true},
+		{"all-day journal on the day before", journal("DTSTART;VALUE=DATE:20240109\n"), nil, start, end, false},
+		{"journal without DTSTART", journal("SUMMARY:Notes\n"), nil, start, end, false},
+		{"weekly journal", journal("DTSTART:20240103T110000Z\nRRULE:FREQ=WEEKLY\n"), nil, start, end, true},
+		// VFREEBUSY
+		{"free-busy ending at start", freeBusy("DTSTART:20240101T000000Z\nDTEND:20240110T100000Z\n"), nil, start, end, true},
+		{"free-busy ending before start", freeBusy("DTSTART:20240101T000000Z\nDTEND:20240110T095900Z\n"), nil, start, end, false},
+		{"free-busy starting at end", freeBusy("DTSTART:20240110T120000Z\nDTEND:20240111T000000Z\n"), nil, start, end, false},
+		{"free-busy period in range", freeBusy("FREEBUSY:20240101T090000Z/PT1H,20240110T110000Z/PT30M\n"), nil, start, end, true},
+		{"free-busy period ending at start", freeBusy("FREEBUSY:20240110T090000Z/20240110T100000Z\n"), nil, start, end, false},
+		{"free-busy without dates", freeBusy("ORGANIZER:mailto:jane@example.com\n"), nil, start, end, false},
+		// VALARM
+		{"alarm before start", eventAlarm("TRIGGER:-PT30M\n"), []string{"VEVENT", "VALARM"}, start, end, true},
+		{"alarm before range", eventAlarm("TRIGGER:-PT2H\n"), []string{"VEVENT", "VALARM"}, start, end, false},
+		{"alarm at start", eventAlarm("TRIGGER:-PT1H\n"), []string{"VEVENT", "VALARM"}, start, end, true},
+		{"alarm related to end", eventAlarm("TRIGGER;RELATED=END:-PT5M\n"), []string{"VEVENT", "VALARM"}, start, end, true},
+		{"alarm related to end at end", eventAlarm("TRIGGER;RELATED=END:PT0S\n"), []string{"VEVENT", "VALARM"}, start, end, false},
+		{"absolute alarm", eventAlarm("TRIGGER;VALUE=DATE-TIME:20240110T100000Z\n"), []string{"VEVENT", "VALARM"}, start, end, true},
+		{"absolute alarm before range", eventAlarm("TRIGGER;VALUE=DATE-TIME:20240101T100000Z\n"), []string{"VEVENT", "VALARM"}, start, end, false},
+		{"repeated alarm", eventAlarm("TRIGGER:-PT3H\nREPEAT:4\nDURATION:PT30M\n"), []string{"VEVENT", "VALARM"}, start, end, true},
+		{"repeated alarm before range", eventAlarm("TRIGGER:-PT3H\nREPEAT:3\nDURATION:PT30M\n"), []string{"VEVENT", "VALARM"}, start, end, false},
+		{"alarm without trigger", eventAlarm("SUMMARY:Never\n"), []string{"VEVENT", "VALARM"}, start, end, false},
+		{
+			"alarm of daily event",
+			event("DTSTART:20240101T110000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n" + alarm("TRIGGER:-PT30M\n")),
+			[]string{"VEVENT", "VALARM"}, start, end, true,
+		},
+		{
+			"alarm of daily event starting after end",
+			event("DTSTART:20240101T123000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n" + alarm("TRIGGER:-PT1H\n")),
+			[]string{"VEVENT", "VALARM"}, start, end, true,
+		},
+		{
+			"alarm of overridden occurrence",
+			event("DTSTART:20240101T110000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\n"+alarm("TRIGGER:-PT30M\n")) +
+				event("RECURRENCE-ID:20240110T110000Z\nDTSTART:20240115T110000Z\nDURATION:PT1H\n"+alarm("TRIGGER:-PT30M\n")),
+			[]string{"VEVENT", "VALARM"}, start, end, false,
+		},
+		{"alarm of todo", todo("DUE:20240110T120000Z\n" + alarm("TRIGGER;RELATED=END:-PT1H\n")), []string{"VTODO", "VALARM"}, start, end, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cal := decodeTestCalendar(t, "BEGIN:VCALENDAR\nVERSION:2.0\nPRODID:-//Example Corp.//CalDAV Client//EN\n"+tc.data+"END:VCALENDAR\n")
+
+			comps := tc.comps
+			if comps == nil {
+				comps = []string{cal.Children[0].Name}
+			}
+			var filter CompFilter
+			for i := len(comps) - 1; i >= 0; i-- {
+				child := filter
+				filter = CompFilter{Name: comps[i]}
+				if i == len(comps)-1 {
+					if tc.start != "" {
+						filter.Start = toDate(t, tc.start)
+					}
+					if tc.end != "" {
+						filter.End = toDate(t, tc.end)
+					}
+				} else {
+					filter.Comps = []CompFilter{child}
+				}
+			}
+			filter = CompFilter{Name: "VCALENDAR", Comps: []CompFilter{filter}}
+
+			got, err := Match(filter, &CalendarObject{Data: cal})
+			if err != nil {
+				t.Fatalf("Match() = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Match() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchPropTimeRange(t *testing.T) {
+	r := matchRange{start: toDate(t, "20240110T100000Z"), end: toDate(t, "20240110T120000Z")}
+	for _, tc := range []struct {
+		value string
+		want  bool
+	}{
+		{"20240110T095900Z", false},
+		{"20240110T100000Z", true},
+		{"20240110T110000Z", true},
+		{"20240110T120000Z", false},
+	} {
+		prop := ical.NewProp(ical.PropDateTimeStamp)
+		prop.Value = tc.value
+		got, err := matchPropTimeRange(r, prop)
+		if err != nil {
+			t.Fatalf("matchPropTimeRange(%v) = %v", tc.value, err)
+		}
+		if got != tc.want {
+			t.Errorf("matchPropTimeRange(%v) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"mime"
 	"net/http"
@@ -44,11 +45,35 @@ type Backend interface {
 	webdav.UserPrincipalBackend
 }
 
+// SyncBackend is an optional interface which can be implemented by a Backend
+// to support the sync-collection report defined in RFC 6578, which lets
+// clients fetch the changes made to a calendar since their last
+// synchronization.
+type SyncBackend interface {
+	// CalendarSyncToken returns a token identifying the current state of a
+	// calendar. It must be an absolute URI.
+	CalendarSyncToken(ctx context.Context, path string) (string, error)
+	// SyncCalendarObjects returns the calendar objects created, updated or
+	// deleted since the state identified by query.SyncToken, along with the
+	// token of the current state. An empty token lists all objects. Deleted
+	// objects only need their Path.
+	//
+	// If there are more changes than query.Limit, the backend can either
+	// return the first ones with Truncated set, or fail with a 507 error.
+	//
+	// If the token is unknown or has expired, an error wrapping
+	// webdav.ErrInvalidSyncToken is returned.
+	SyncCalendarObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResult, error)
+}
+
 // Handler handles CalDAV HTTP requests. It can be used to create a CalDAV
 // server.
 type Handler struct {
 	Backend Backend
 	Prefix  string
+	// Authorizer enables access control when set. The current user's
+	// principal is obtained with Backend.CurrentUserPrincipal.
+	Authorizer webdav.Authorizer
 }
 
 // ServeHTTP implements http.Handler.
@@ -69,14 +94,20 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.authorize(r); err != nil {
+		internal.ServeError(w, err)
+		return
+	}
+
 	var err error
 	switch r.Method {
 	case "REPORT":
 		err = h.handleReport(w, r)
 	default:
 		b := backend{
-			Backend: h.Backend,
-			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+			Backend:    h.Backend,
+			Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+			Authorizer: h.Authorizer,
 		}
 		hh := internal.Handler{Backend: &b}
 		hh.ServeHTTP(w, r)
@@ -87,6 +118,25 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorize checks whether the current user is allowed to perform a request.
+func (h *Handler) authorize(r *http.Request) error {
+	if h.Authorizer == nil {
+		return nil
+	}
+	principal, err := h.Backend.CurrentUserPrincipal(r.Context())
+	if err != nil {
+		return err
+	}
+	exists := func(name string) (bool, error) {
+		_, err := h.Backend.GetCalendarObject(r.Context(), name, &CalendarCompRequest{})
+		if internal.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return internal.AuthorizeRequest(r, h.Authorizer, principal, exists)
+}
+
 func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 	var report reportReq
 	if err := internal.DecodeXMLRequest(r, &report); err != nil {
@@ -97,8 +147,10 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 		return h.handleQuery(r, w, report.Query)
 	} else if report.Multiget != nil {
 		return h.handleMultiget(r.Context(), w, report.Multiget)
+	} else if report.SyncCollection != nil {
+		return h.handleSyncCollection(r, w, report.SyncCollection)
 	}
-	return internal.HTTPErrorf(http.StatusBadRequest, "caldav: expected calendar-query or calendar-multiget element in REPORT request")
+	return internal.HTTPErrorf(http.StatusBadRequest, "caldav: expected calendar-query, calendar-multiget or sync-collection element in REPORT request")
 }
 
 func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
@@ -110,11 +162,26 @@ func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
 		pf.IsNotDefined = true
 	}
 	if el.TextMatch != nil {
-		pf.TextMatch = &TextMatch{Text: el.TextMatch.Text}
+		tm, err := decodeTextMatch(el.TextMatch)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatch = tm
 	}
 	return pf, nil
 }
 
+func decodeTextMatch(el *textMatch) (*TextMatch, error) {
+	if el.Collation != "" && !internal.IsSupportedCollation(el.Collation) {
+		return nil, internal.NewConditionError(http.StatusForbidden, supportedCollationName)
+	}
+	return &TextMatch{
+		Text:            el.Text,
+		NegateCondition: bool(el.NegateCondition),
+		Collation:       Collation(el.Collation),
+	}, nil
+}
+
 func decodePropFilter(el *propFilter) (*PropFilter, error) {
 	pf := &PropFilter{Name: el.Name}
 	if el.IsNotDefined != nil {
@@ -124,7 +191,11 @@ func decodePropFilter(el *propFilter) (*PropFilter, error) {
 		pf.IsNotDefined = true
 	}
 	if el.TextMatch != nil {
-		pf.TextMatch = &TextMatch{Text: el.TextMatch.Text}
+		tm, err := decodeTextMatch(el.TextMatch)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatch = tm
 	}
 	if el.TimeRange != nil {
 		pf.Start = time.Time(el.TimeRange.Start)
@@ -181,6 +252,7 @@ func decodeComp(comp *comp) (*CalendarCompRequest, error) {
 	}
 
 	req := &CalendarCompRequest{
+		Name:     comp.Name,
 		AllProps: comp.Allprop != nil,
 		AllComps: comp.Allcomp != nil,
 	}
@@ -198,18 +270,71 @@ func decodeComp(comp *comp) (*CalendarCompRequest, error) {
 }
 
 func decodeCalendarDataReq(calendarData *calendarDataReq) (*CalendarCompRequest, error) {
+	var req *CalendarCompRequest
 	if calendarData.Comp == nil {
-		return &CalendarCompRequest{
+		req = &CalendarCompRequest{
 			AllProps: true,
 			AllComps: true,
-		}, nil
+		}
+	} else {
+		var err error
+		req, err = decodeComp(calendarData.Comp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if calendarData.Expand != nil && calendarData.LimitRecurrenceSet != nil {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: only one of expand or limit-recurrence-set can be specified in calendar-data")
+	}
+
+	var err error
+	if el := calendarData.Expand; el != nil {
+		if req.Expand, err = decodeExpandRequest(el.Start, el.End); err != nil {
+			return nil, err
+		}
+	}
+	if el := calendarData.LimitRecurrenceSet; el != nil {
+		if req.LimitRecurrenceSet, err = decodeExpandRequest(el.Start, el.End); err != nil {
+			return nil, err
+		}
+	}
+	if el := calendarData.LimitFreeBusySet; el != nil {
+		if req.LimitFreeBusySet, err = decodeExpandRequest(el.Start, el.End); err != nil {
+			return nil, err
+		}
 	}
-	return decodeComp(calendarData.Comp)
+
+	return req, nil
+}
+
+func decodeExpandRequest(start, end dateWithUTCTime) (*CalendarExpandRequest, error) {
+	req := &CalendarExpandRequest{
+		Start: time.Time(start),
+		End:   time.Time(end),
+	}
+	if req.Start.IsZero() || req.End.IsZero() {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: missing start or end time range attribute in calendar-data")
+	}
+	if !req.End.After(req.Start) {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: time range end must be after start in calendar-data")
+	}
+	return req, nil
 }
 
 func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *calendarQuery) error {
 	var q CalendarQuery
-	// TODO: calendar-data in query.Prop
+	if query.Prop != nil {
+		var calendarData calendarDataReq
+		if err := query.Prop.Decode(&calendarData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		decoded, err := decodeCalendarDataReq(&calendarData)
+		if err != nil {
+			return err
+		}
+		q.CompRequest = *decoded
+	}
 	cf, err := decodeCompFilter(&query.Filter.CompFilter)
 	if err != nil {
 		return err
@@ -221,18 +346,31 @@ func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *cal
 		return err
 	}
 
+	b := backend{
+		Backend:    h.Backend,
+		Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+		Authorizer: h.Authorizer,
+	}
+
 	var resps []internal.Response
 	for _, co := range cos {
-		b := backend{
-			Backend: h.Backend,
-			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+		// Objects the user can't read don't match
+		if err := b.authorizeRead(r.Context(), co.Path); internal.IsForbidden(err) {
+			continue
+		} else if err != nil {
+			return err
 		}
+
 		propfind := internal.PropFind{
 			Prop:     query.Prop,
 			AllProp:  query.AllProp,
 			PropName: query.PropName,
 		}
-		resp, err := b.propFindCalendarObject(r.Context(), &propfind, &co)
+		co, err := filterCalendarData(&q.CompRequest, &co)
+		if err != nil {
+			return err
+		}
+		resp, err := b.propFindCalendarObject(r.Context(), &propfind, co)
 		if err != nil {
 			return err
 		}
@@ -258,8 +396,20 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 		dataReq = *decoded
 	}
 
+	b := backend{
+		Backend:    h.Backend,
+		Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+		Authorizer: h.Authorizer,
+	}
+
 	var resps []internal.Response
 	for _, href := range multiget.Hrefs {
+		if err := b.authorizeRead(ctx, href.Path); err != nil {
+			resp := internal.NewErrorResponse(href.Path, err)
+			resps = append(resps, *resp)
+			continue
+		}
+
 		co, err := h.Backend.GetCalendarObject(ctx, href.Path, &dataReq)
 		if err != nil {
 			resp := internal.NewErrorResponse(href.Path, err)
@@ -267,15 +417,15 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 			continue
 		}
 
-		b := backend{
-			Backend: h.Backend,
-			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
-		}
 		propfind := internal.PropFind{
 			Prop:     multiget.Prop,
 			AllProp:  multiget.AllProp,
 			PropName: multiget.PropName,
 		}
+		co, err = filterCalendarData(&dataReq, co)
+		if err != nil {
+			return err
+		}
 		resp, err := b.propFindCalendarObject(ctx, &propfind, co)
 		if err != nil {
 			return err
@@ -287,9 +437,86 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleSyncCollection(r *http.Request, w http.ResponseWriter, sync *internal.SyncCollectionQuery) error {
+	b := backend{
+		Backend:    h.Backend,
+		Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+		Authorizer: h.Authorizer,
+	}
+	sb, ok := h.Backend.(SyncBackend)
+	if !ok || b.resourceTypeAtPath(r.URL.Path) != resourceTypeCalendar {
+		return internal.NewConditionError(http.StatusForbidden, internal.SupportedReportName)
+	}
+
+	if err := internal.CheckSyncCollectionDepth(r.Header); err != nil {
+		return err
+	}
+	// Calendars don't contain collections, so both sync levels are
+	// equivalent
+	if _, err := sync.Recursive(); err != nil {
+		return err
+	}
+
+	query := SyncQuery{SyncToken: sync.SyncToken}
+	if sync.Limit != nil {
+		query.Limit = int(sync.Limit.NResults)
+	}
+	if sync.Prop != nil {
+		var calendarData calendarDataReq
+		if err := sync.Prop.Decode(&calendarData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		decoded, err := decodeCalendarDataReq(&calendarData)
+		if err != nil {
+			return err
+		}
+		query.CompRequest = *decoded
+	}
+
+	result, err := sb.SyncCalendarObjects(r.Context(), r.URL.Path, &query)
+	if errors.Is(err, webdav.ErrInvalidSyncToken) {
+		return internal.NewConditionError(http.StatusForbidden, internal.ValidSyncTokenName)
+	} else if err != nil {
+		return err
+	}
+
+	if err := sync.CheckLimit(len(result.Created) + len(result.Updated) + len(result.Deleted)); err != nil {
+		return err
+	}
+
+	propfind := internal.PropFind{Prop: sync.Prop}
+	var resps []internal.Response
+	for _, l := range [][]CalendarObject{result.Created, result.Updated} {
+		for i := range l {
+			if err := b.authorizeRead(r.Context(), l[i].Path); err != nil {
+				resps = append(resps, *internal.NewErrorResponse(l[i].Path, err))
+				continue
+			}
+
+			co, err := filterCalendarData(&query.CompRequest, &l[i])
+			if err != nil {
+				return err
+			}
+			resp, err := b.propFindCalendarObject(r.Context(), &propfind, co)
+			if err != nil {
+				return err
+			}
+			resps = append(resps, *resp)
+		}
+	}
+	deleted := make([]string, len(result.Deleted))
+	for i, item := range result.Deleted {
+		deleted[i] = item.Path
+	}
+
+	ms := internal.NewSyncCollectionMultiStatus(r.URL.Path, result.SyncToken, resps, deleted, result.Truncated)
+	return internal.ServeMultiStatus(w, ms)
+}
+
 type backend struct {
-	Backend Backend
-	Prefix  string
+	Backend    Backend
+	Prefix     string
+	Authorizer webdav.Authorizer
 }
 
 type resourceType int
@@ -366,96 +593,101 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth) (*internal.MultiStatus, error) {
+func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth, mw *internal.MultiStatusWriter) error {
 	resType := b.resourceTypeAtPath(r.URL.Path)
 
 	var dataReq CalendarCompRequest
-	var resps []internal.Response
 
 	switch resType {
 	case resourceTypeRoot:
 		resp, err := b.propFindRoot(r.Context(), propfind)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 	case resourceTypeUserPrincipal:
 		principalPath, err := b.Backend.CurrentUserPrincipal(r.Context())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if r.URL.Path == principalPath {
 			resp, err := b.propFindUserPrincipal(r.Context(), propfind)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if err := mw.WriteResponse(resp); err != nil {
+				return err
 			}
-			resps = append(resps, *resp)
 			if depth != internal.DepthZero {
 				resp, err := b.propFindHomeSet(r.Context(), propfind)
 				if err != nil {
-					return nil, err
+					return err
+				}
+				if err := mw.WriteResponse(resp); err != nil {
+					return err
 				}
-				resps = append(resps, *resp)
 				if depth == internal.DepthInfinity {
-					resps_, err := b.propFindAllCalendars(r.Context(), propfind, true)
-					if err != nil {
-						return nil, err
+					if err := b.propFindAllCalendars(r.Context(), propfind, true, mw); err != nil {
+						return err
 					}
-					resps = append(resps, resps_...)
 				}
 			}
 		}
 	case resourceTypeCalendarHomeSet:
 		homeSetPath, err := b.Backend.CalendarHomeSetPath(r.Context())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if r.URL.Path == homeSetPath {
 			resp, err := b.propFindHomeSet(r.Context(), propfind)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if err := mw.WriteResponse(resp); err != nil {
+				return err
 			}
-			resps = append(resps, *resp)
 			if depth != internal.DepthZero {
 				recurse := depth == internal.DepthInfinity
-				resps_, err := b.propFindAllCalendars(r.Context(), propfind, recurse)
-				if err != nil {
-					return nil, err
+				if err := b.propFindAllCalendars(r.Context(), propfind, recurse, mw); err != nil {
+					return err
 				}
-				resps = append(resps, resps_...)
 			}
 		}
 	case resourceTypeCalendar:
 		ab, err := b.Backend.GetCalendar(r.Context(), r.URL.Path)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		resp, err := b.propFindCalendar(r.Context(), propfind, ab)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 		if depth != internal.DepthZero {
-			resps_, err := b.propFindAllCalendarObjects(r.Context(), propfind, ab)
-			if err != nil {
-				return nil, err
+			if err := b.propFindAllCalendarObjects(r.Context(), propfind, ab, mw); err != nil {
+				return err
 			}
-			resps = append(resps, resps_...)
 		}
 	case resourceTypeCalendarObject:
 		ao, err := b.Backend.GetCalendarObject(r.Context(), r.URL.Path, &dataReq)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		resp, err := b.propFindCalendarObject(r.Context(), propfind, ao)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 	}
 
-	return internal.NewMultiStatus(resps...), nil
+	return nil
 }
 
 func (b *backend) propFindRoot(ctx context.Context, propfind *internal.PropFind) (*internal.Response, error) {
@@ -515,6 +747,36 @@ func (b *backend) propFindHomeSet(ctx context.Context, propfind *internal.PropFi
 	return internal.NewPropFindResponse(homeSetPath, propfind, props)
 }
 
+// authorizeRead checks whether the current user can read a resource listed
+// in a response, in addition to the request-URI.
+func (b *backend) authorizeRead(ctx context.Context, name string) error {
+	if b.Authorizer == nil {
+		return nil
+	}
+	principal, err := b.Backend.CurrentUserPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	return b.Authorizer.Authorize(ctx, principal, name, internal.PrivilegeRead)
+}
+
+// currentUserPrivilegeSet returns the privileges of the current user on a
+// resource. Without an Authorizer, all users can read and write.
+func (b *backend) currentUserPrivilegeSet(ctx context.Context, name string) (*internal.CurrentUserPrivilegeSet, error) {
+	if b.Authorizer == nil {
+		return internal.NewCurrentUserPrivilegeSet(internal.PrivilegeRead, internal.PrivilegeWrite), nil
+	}
+	principal, err := b.Backend.CurrentUserPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	privs, err := internal.CurrentUserPrivileges(ctx, b.Authorizer, principal, name)
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewCurrentUserPrivilegeSet(privs...), nil
+}
+
 func (b *backend) propFindCalendar(ctx context.Context, propfind *internal.PropFind, cal *Calendar) (*internal.Response, error) {
 	props := map[xml.Name]internal.PropFindFunc{
 		internal.CurrentUserPrincipalName: func(*internal.RawXMLValue) (interface{}, error) {
@@ -533,6 +795,13 @@ func (b *backend) propFindCalendar(ctx context.Context, propfind *internal.PropF
 				{ContentType: ical.MIMEType, Version: "2.0"},
 			},
 		}),
+		supportedCollationSetName: internal.PropFindValue(&supportedCollationSet{
+			Collation: []string{
+				string(CollationOctet),
+				string(CollationASCIICaseMap),
+				string(CollationUnicodeCaseMap),
+			},
+		}),
 		supportedCalendarComponentSetName: func(*internal.RawXMLValue) (interface{}, error) {
 			components := []comp{}
 			if cal.SupportedComponentSet != nil {
@@ -546,15 +815,17 @@ func (b *backend) propFindCalendar(ctx context.Context, propfind *internal.PropF
 				Comp: components,
 			}, nil
 		},
-		// TODO: Gather UserPrivilege from backend to control read-and-write
-		internal.CurrentUserPrivilegeSetName: internal.PropFindValue(&internal.CurrentUserPrivilegeSet{
-			Privilege: []internal.Privilege{{
-				Read:  &struct{}{},
-				Write: &struct{}{},
-			}},
-		}),
+		internal.CurrentUserPrivilegeSetName: func(*internal.RawXMLValue) (interface{}, error) {
+			return b.currentUserPrivilegeSet(ctx, cal.Path)
+		},
 	}
 
+	reports := []xml.Name{calendarQueryName, calendarMultigetName}
+	if _, ok := b.Backend.(SyncBackend); ok {
+		reports = append(reports, internal.SyncCollectionName)
+	}
+	props[internal.SupportedReportSetName] = internal.PropFindValue(internal.NewSupportedReportSet(reports...))
+
 	if cal.Name != "" {
 		props[internal.DisplayNameName] = internal.PropFindValue(&internal.DisplayName{
 			Name: cal.Name,
@@ -571,33 +842,49 @@ func (b *backend) propFindCalendar(ctx context.Context, propfind *internal.PropF
 		})
 	}
 
+	if sb, ok := b.Backend.(SyncBackend); ok && propfind.AllProp == nil {
+		props[internal.SyncTokenName] = func(*internal.RawXMLValue) (interface{}, error) {
+			token, err := sb.CalendarSyncToken(ctx, cal.Path)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.SyncToken{Token: token}, nil
+		}
+	}
+
 	// TODO: CALDAV:calendar-timezone, CALDAV:supported-calendar-component-set, CALDAV:min-date-time, CALDAV:max-date-time, CALDAV:max-instances, CALDAV:max-attendees-per-instance
 
 	return internal.NewPropFindResponse(cal.Path, propfind, props)
 }
 
-func (b *backend) propFindAllCalendars(ctx context.Context, propfind *internal.PropFind, recurse bool) ([]internal.Response, error) {
+func (b *backend) propFindAllCalendars(ctx context.Context, propfind *internal.PropFind, recurse bool, mw *internal.MultiStatusWriter) error {
 	abs, err := b.Backend.ListCalendars(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
 	for _, ab := range abs {
+		if err := b.authorizeRead(ctx, ab.Path); err != nil {
+			if err := mw.WriteResponse(internal.NewErrorResponse(ab.Path, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
 		resp, err := b.propFindCalendar(ctx, propfind, &ab)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 		if recurse {
-			resps_, err := b.propFindAllCalendarObjects(ctx, propfind, &ab)
-			if err != nil {
-				return nil, err
+			if err := b.propFindAllCalendarObjects(ctx, propfind, &ab, mw); err != nil {
+				return err
 			}
-			resps = append(resps, resps_...)
 		}
 	}
-	return resps, nil
+	return nil
 }
 
 func (b *backend) propFindCalendarObject(ctx context.Context, propfind *internal.PropFind, co *CalendarObject) (*internal.Response, error) {
@@ -643,22 +930,30 @@ func (b *backend) propFindCalendarObject(ctx context.Context, propfind *internal
 	return internal.NewPropFindResponse(co.Path, propfind, props)
 }
 
-func (b *backend) propFindAllCalendarObjects(ctx context.Context, propfind *internal.PropFind, cal *Calendar) ([]internal.Response, error) {
+func (b *backend) propFindAllCalendarObjects(ctx context.Context, propfind *internal.PropFind, cal *Calendar, mw *internal.MultiStatusWriter) error {
 	var dataReq CalendarCompRequest
 	aos, err := b.Backend.ListCalendarObjects(ctx, cal.Path, &dataReq)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
 	for _, ao := range aos {
+		if err := b.authorizeRead(ctx, ao.Path); err != nil {
+			if err := mw.WriteResponse(internal.NewErrorResponse(ao.Path, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
 		resp, err := b.propFindCalendarObject(ctx, propfind, &ao)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 	}
-	return resps, nil
+	return nil
 }
 
 func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
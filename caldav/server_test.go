@@ -2,15 +2,20 @@ package caldav
 
 import (
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
 )
 
 var propFindSupportedCalendarComponentRequest = `
@@ -52,6 +57,93 @@ func TestPropFindSupportedCalendarComponent(t *testing.T) {
 	}
 }
 
+func TestClient_SyncCalendar_expired(t *testing.T) {
+	calPath := "/user/calendars/cal"
+	handler := &Handler{Backend: testSyncBackend{testBackend{
+		calendars: []Calendar{{Path: calPath}},
+		objectMap: map[string][]CalendarObject{calPath: nil},
+	}}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	ctx := context.Background()
+	result, err := c.SyncCalendar(ctx, calPath, "", nil)
+	if err != nil {
+		t.Fatalf("SyncCalendar() = %v", err)
+	} else if result.SyncToken != "urn:test:2" {
+		t.Errorf("SyncCalendar() = token %q, want %q", result.SyncToken, "urn:test:2")
+	}
+	if _, err := c.SyncCalendar(ctx, calPath, "urn:test:expired", nil); !errors.Is(err, ErrSyncTokenExpired) {
+		t.Errorf("SyncCalendar() with expired token = %v, want %v", err, ErrSyncTokenExpired)
+	}
+}
+
+func TestHandler_syncCollectionAuthorization(t *testing.T) {
+	calPath := "/user/calendars/cal"
+	var objs []CalendarObject
+	for _, uid := range []string{"public", "secret"} {
+		event := ical.NewEvent()
+		event.Props.SetText(ical.PropUID, uid)
+		event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+		cal := ical.NewCalendar()
+		cal.Props.SetText(ical.PropVersion, "2.0")
+		cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+		cal.Children = []*ical.Component{event.Component}
+		objs = append(objs, CalendarObject{Path: calPath + "/" + uid + ".ics", ETag: uid, Data: cal})
+	}
+
+	ctx := context.Background()
+	az := webdav.NewMemAuthorizer()
+	az.SetACL(ctx, "/", []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Privileges: []xml.Name{webdav.PrivilegeRead}},
+	})
+	az.SetACL(ctx, objs[1].Path, []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Deny: true, Privileges: []xml.Name{webdav.PrivilegeRead}},
+	})
+	handler := &Handler{
+		Backend: testSyncBackend{testBackend{
+			calendars: []Calendar{{Path: calPath}},
+			objectMap: map[string][]CalendarObject{calPath: objs},
+		}},
+		Authorizer: az,
+	}
+
+	req := httptest.NewRequest("REPORT", calPath, strings.NewReader(fmt.Sprintf(reportSyncCollection, "", 10)))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || !strings.Contains(body, "&#34;public&#34;") {
+		t.Errorf("REPORT = %v, readable object missing:\n%v", w.Code, body)
+	}
+	if strings.Contains(body, "&#34;secret&#34;") || !strings.Contains(body, "<href>"+objs[1].Path+"</href><responsedescription>403 Forbidden") {
+		t.Errorf("REPORT = %v, unreadable object not forbidden:\n%v", w.Code, body)
+	}
+}
+
+func TestPropFindSupportedCollationSet(t *testing.T) {
+	calendar := Calendar{Path: "/user/calendars/cal"}
+	req := httptest.NewRequest("PROPFIND", calendar.Path, strings.NewReader(`<propfind xmlns="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><prop><c:supported-collation-set/></prop></propfind>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", "0")
+	w := httptest.NewRecorder()
+	handler := Handler{Backend: testBackend{calendars: []Calendar{calendar}}}
+	handler.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, collation := range []Collation{CollationOctet, CollationASCIICaseMap, CollationUnicodeCaseMap} {
+		if !strings.Contains(body, "<supported-collation>"+string(collation)+"</supported-collation>") {
+			t.Errorf("Expected collation %v not found in response:\n%v", collation, body)
+		}
+	}
+}
+
 var propFindUserPrincipal = `
 <?xml version="1.0" encoding="UTF-8"?>
 <A:propfind xmlns:A="DAV:">
@@ -233,3 +325,271 @@ func (t testBackend) ListCalendarObjects(ctx context.Context, path string, req *
 func (t testBackend) QueryCalendarObjects(ctx context.Context, path string, query *CalendarQuery) ([]CalendarObject, error) {
 	return nil, nil
 }
+
+type testSyncBackend struct {
+	testBackend
+}
+
+func (t testSyncBackend) CalendarSyncToken(ctx context.Context, path string) (string, error) {
+	return "urn:test:2", nil
+}
+
+func (t testSyncBackend) SyncCalendarObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResult, error) {
+	objs := t.objectMap[path]
+	switch query.SyncToken {
+	case "":
+		if query.Limit > 0 && len(objs) > query.Limit {
+			return &SyncResult{Created: objs[:query.Limit], SyncToken: "urn:test:1", Truncated: true}, nil
+		}
+		return &SyncResult{Created: objs, SyncToken: "urn:test:2"}, nil
+	case "urn:test:1":
+		return &SyncResult{
+			Updated:   objs[1:],
+			Deleted:   []SyncDeletedItem{{Path: path + "/deleted.ics"}},
+			SyncToken: "urn:test:2",
+		}, nil
+	case "urn:test:expired":
+		return nil, fmt.Errorf("%w: token expired", webdav.ErrInvalidSyncToken)
+	default:
+		return nil, fmt.Errorf("%w: %q", webdav.ErrInvalidSyncToken, query.SyncToken)
+	}
+}
+
+var reportMultigetTwo = `<?xml version="1.0" encoding="UTF-8"?>
+<B:calendar-multiget xmlns:A="DAV:" xmlns:B="urn:ietf:params:xml:ns:caldav">
+  <A:prop><A:getetag/></A:prop>
+  <A:href>%s</A:href>
+  <A:href>%s</A:href>
+</B:calendar-multiget>
+`
+
+func TestHandler_multigetAuthorization(t *testing.T) {
+	calPath := "/user/calendars/cal"
+	objs := []CalendarObject{
+		{Path: calPath + "/public.ics", ETag: "public"},
+		{Path: calPath + "/secret.ics", ETag: "secret"},
+	}
+
+	ctx := context.Background()
+	az := webdav.NewMemAuthorizer()
+	az.SetACL(ctx, "/", []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Privileges: []xml.Name{webdav.PrivilegeRead}},
+	})
+	az.SetACL(ctx, objs[1].Path, []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Deny: true, Privileges: []xml.Name{webdav.PrivilegeRead}},
+	})
+	handler := &Handler{
+		Backend: testBackend{
+			calendars: []Calendar{{Path: calPath}},
+			objectMap: map[string][]CalendarObject{calPath: objs},
+		},
+		Authorizer: az,
+	}
+
+	req := httptest.NewRequest("REPORT", calPath, strings.NewReader(fmt.Sprintf(reportMultigetTwo, objs[0].Path, objs[1].Path)))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || !strings.Contains(body, "&#34;public&#34;") {
+		t.Errorf("REPORT = %v, readable object missing:\n%v", w.Code, body)
+	}
+	if strings.Contains(body, "&#34;secret&#34;") || !strings.Contains(body, "403 Forbidden") {
+		t.Errorf("REPORT = %v, unreadable object not forbidden:\n%v", w.Code, body)
+	}
+}
+
+var reportSyncCollection = `<?xml version="1.0" encoding="UTF-8"?>
+<A:sync-collection xmlns:A="DAV:" xmlns:B="urn:ietf:params:xml:ns:caldav">
+  <A:sync-token>%s</A:sync-token>
+  <A:sync-level>1</A:sync-level>
+  <A:limit><A:nresults>%d</A:nresults></A:limit>
+  <A:prop><A:getetag/></A:prop>
+</A:sync-collection>
+`
+
+func TestHandler_syncCollection(t *testing.T) {
+	calPath := "/user/calendars/cal"
+	var objs []CalendarObject
+	for _, uid := range []string{"a", "b"} {
+		event := ical.NewEvent()
+		event.Props.SetText(ical.PropUID, uid)
+		event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+		event.Props.SetText(ical.PropSummary, "Event "+uid)
+		cal := ical.NewCalendar()
+		cal.Props.SetText(ical.PropVersion, "2.0")
+		cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+		cal.Children = []*ical.Component{event.Component}
+		objs = append(objs, CalendarObject{Path: calPath + "/" + uid + ".ics", ETag: uid, Data: cal})
+	}
+
+	handler := &Handler{Backend: testSyncBackend{testBackend{
+		calendars: []Calendar{{Path: calPath}},
+		objectMap: map[string][]CalendarObject{calPath: objs},
+	}}}
+	report := func(token string, limit int) (int, string) {
+		req := httptest.NewRequest("REPORT", calPath, strings.NewReader(fmt.Sprintf(reportSyncCollection, token, limit)))
+		req.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, body := report("", 10)
+	if code != http.StatusMultiStatus || !strings.Contains(body, "<sync-token>urn:test:2</sync-token>") || !strings.Contains(body, objs[0].Path) || !strings.Contains(body, objs[1].Path) {
+		t.Errorf("initial REPORT = %v:\n%v", code, body)
+	}
+
+	code, body = report("urn:test:1", 10)
+	if code != http.StatusMultiStatus || strings.Contains(body, objs[0].Path) || !strings.Contains(body, "&#34;b&#34;") {
+		t.Errorf("REPORT with token = %v, updated object missing:\n%v", code, body)
+	}
+	if !strings.Contains(body, "<href>"+calPath+"/deleted.ics</href><status>HTTP/1.1 404 Not Found</status>") {
+		t.Errorf("REPORT with token = %v, deleted object missing:\n%v", code, body)
+	}
+
+	code, body = report("", 1)
+	if code != http.StatusMultiStatus || !strings.Contains(body, "<sync-token>urn:test:1</sync-token>") || !strings.Contains(body, "507 Insufficient Storage") {
+		t.Errorf("REPORT with limit = %v:\n%v", code, body)
+	}
+	if code, body := report("urn:test:1", 1); code != http.StatusInsufficientStorage || !strings.Contains(body, "number-of-matches-within-limits") {
+		t.Errorf("REPORT over limit = %v:\n%v", code, body)
+	}
+	for _, token := range []string{"urn:test:expired", "urn:test:unknown"} {
+		if code, body := report(token, 10); code != http.StatusForbidden || !strings.Contains(body, "valid-sync-token") {
+			t.Errorf("REPORT with token %q = %v:\n%v", token, code, body)
+		}
+	}
+
+	req := httptest.NewRequest("PROPFIND", calPath, strings.NewReader(`<propfind xmlns="DAV:"><prop><sync-token/></prop></propfind>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", "0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if body := w.Body.String(); !strings.Contains(body, "<sync-token xmlns=\"DAV:\">urn:test:2</sync-token>") {
+		t.Errorf("PROPFIND sync-token:\n%v", body)
+	}
+
+	req = httptest.NewRequest("PROPFIND", calPath, strings.NewReader(`<propfind xmlns="DAV:"><prop><supported-report-set/></prop></propfind>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", "0")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if body := w.Body.String(); !strings.Contains(body, "<sync-collection xmlns=\"DAV:\">") || !strings.Contains(body, "<calendar-query xmlns=\"urn:ietf:params:xml:ns:caldav\">") {
+		t.Errorf("PROPFIND supported-report-set:\n%v", body)
+	}
+}
+
+func TestHandler_expand(t *testing.T) {
+	calPath := "/user/calendars/cal"
+	obj := CalendarObject{
+		Path: calPath + "/weekly.ics",
+		ETag: "weekly",
+		Data: decodeTestCalendar(t, testRecurringEvent),
+	}
+	handler := &Handler{Backend: testBackend{
+		calendars: []Calendar{{Path: calPath}},
+		objectMap: map[string][]CalendarObject{calPath: {obj}},
+	}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	objs, err := c.MultiGetCalendar(context.Background(), calPath, &CalendarMultiGet{
+		Paths: []string{obj.Path},
+		CompRequest: CalendarCompRequest{
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
+			Expand: &CalendarExpandRequest{
+				Start: toDate(t, "20240101T000000Z"),
+				End:   toDate(t, "20240130T000000Z"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("MultiGetCalendar() = %v", err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("MultiGetCalendar() = %v objects, want 1", len(objs))
+	}
+	want := []string{
+		"VEVENT RECURRENCE-ID=20240108T090000Z DTSTART=20240108T090000Z DTEND=20240108T100000Z SUMMARY=Weekly",
+		"VEVENT RECURRENCE-ID=20240129T090000Z DTSTART=20240129T130000Z DTEND=20240129T140000Z SUMMARY=Moved",
+	}
+	if l := calendarSummary(objs[0].Data); !reflect.DeepEqual(l, want) {
+		t.Errorf("MultiGetCalendar() = \n%v\nwant:\n%v", strings.Join(l, "\n"), strings.Join(want, "\n"))
+	}
+
+	req := httptest.NewRequest("REPORT", calPath, strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data>
+      <C:expand start="20240101T000000Z"/>
+    </C:calendar-data>
+  </D:prop>
+  <D:href>`+obj.Path+`</D:href>
+</C:calendar-multiget>`))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("REPORT with incomplete expand = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandler_partialCalendarData(t *testing.T) {
+	calPath := "/user/calendars/cal"
+	obj := CalendarObject{
+		Path: calPath + "/weekly.ics",
+		ETag: "weekly",
+		Data: decodeTestCalendar(t, testRecurringEvent),
+	}
+	handler := &Handler{Backend: testBackend{
+		calendars: []Calendar{{Path: calPath}},
+		objectMap: map[string][]CalendarObject{calPath: {obj}},
+	}}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	objs, err := c.MultiGetCalendar(context.Background(), calPath, &CalendarMultiGet{
+		Paths: []string{obj.Path},
+		CompRequest: CalendarCompRequest{
+			Name:  "VCALENDAR",
+			Props: []string{"VERSION", "PRODID"},
+			Comps: []CalendarCompRequest{{
+				Name:  "VEVENT",
+				Props: []string{"SUMMARY", "DTSTART"},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("MultiGetCalendar() = %v", err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("MultiGetCalendar() = %v objects, want 1", len(objs))
+	}
+	want := []string{
+		"VEVENT DTSTART=20240108T100000 SUMMARY=Weekly",
+		"VEVENT RECURRENCE-ID=20240129T100000 DTSTART=20240129T140000 SUMMARY=Moved",
+		"VEVENT RECURRENCE-ID=20240205T100000 DTSTART=20240301T100000 SUMMARY=Moved far",
+	}
+	if l := calendarSummary(objs[0].Data); !reflect.DeepEqual(l, want) {
+		t.Errorf("MultiGetCalendar() = \n%v\nwant:\n%v", strings.Join(l, "\n"), strings.Join(want, "\n"))
+	}
+	// UID, DTSTAMP and RECURRENCE-ID are required, so they're always
+	// returned
+	if len(objs[0].Data.Children[0].Props) != 4 || len(objs[0].Data.Children[1].Props) != 5 {
+		t.Errorf("MultiGetCalendar() returned VEVENT properties %v", objs[0].Data.Children[0].Props)
+	}
+}
@@ -6,6 +6,26 @@ type SyncResult struct {
 	Updated   []CalendarObject  // Modified events
 	Deleted   []SyncDeletedItem // Deleted events
 	SyncToken string            // New sync-token for next request
+	// Truncated is set if only some of the changes have been returned
+	// because of a limit. SyncToken then identifies the state reached so
+	// far, and another request is needed to fetch the remaining changes.
+	Truncated bool
+}
+
+// SyncChangeType is the kind of change reported by SyncCalendarFunc.
+type SyncChangeType int
+
+const (
+	SyncChangeCreated SyncChangeType = iota + 1
+	SyncChangeUpdated
+	SyncChangeDeleted
+)
+
+// SyncChange is a single change reported by SyncCalendarFunc.
+type SyncChange struct {
+	Type SyncChangeType
+	// Object is the changed event. For deleted events, only Path is set.
+	Object CalendarObject
 }
 
 // SyncDeletedItem represents deleted event in sync results
@@ -78,6 +78,7 @@ type TextMatch struct {
 	Text            string
 	NegateCondition bool
 	MatchType       MatchType // defaults to MatchContains
+	Collation       Collation // defaults to CollationUnicodeCaseMap
 }
 
 type FilterTest string
@@ -96,6 +97,16 @@ const (
 	MatchEndsWith   MatchType = "ends-with"
 )
 
+// Collation defines how text is compared in a TextMatch.
+type Collation string
+
+// Collations supported by text matches, defined in RFC 4790 and RFC 5051.
+const (
+	CollationOctet          Collation = internal.CollationOctet
+	CollationASCIICaseMap   Collation = internal.CollationASCIICaseMap
+	CollationUnicodeCaseMap Collation = internal.CollationUnicodeCaseMap
+)
+
 type AddressBookMultiGet struct {
 	Paths       []string
 	DataRequest AddressDataRequest
@@ -121,4 +132,8 @@ type SyncResponse struct {
 	SyncToken string
 	Updated   []AddressObject
 	Deleted   []string
+	// Truncated is set if only some of the changes have been returned
+	// because of a limit. SyncToken then identifies the state reached so
+	// far, and another request is needed to fetch the remaining changes.
+	Truncated bool
 }
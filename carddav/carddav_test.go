@@ -2,7 +2,9 @@ package carddav
 
 import (
 	"context"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -145,7 +147,7 @@ func TestAddressBookDiscovery(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			h := Handler{&testBackend{}, tc.prefix}
+			h := Handler{Backend: &testBackend{}, Prefix: tc.prefix}
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				ctx := r.Context()
 				ctx = context.WithValue(ctx, currentUserPrincipalKey, tc.currentUserPrincipal)
@@ -240,3 +242,137 @@ func TestCreateAddressbookMinimalBody(t *testing.T) {
 		t.Fatalf("Address book sdscription is '%s', expected 'My primary address book.'", c.Description)
 	}
 }
+
+type testSyncBackend struct {
+	*testBackend
+}
+
+func (testSyncBackend) AddressBookSyncToken(ctx context.Context, path string) (string, error) {
+	return "urn:test:2", nil
+}
+
+func (b testSyncBackend) SyncAddressObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	alice, err := b.GetAddressObject(ctx, alicePath, &query.DataRequest)
+	if err != nil {
+		return nil, err
+	}
+	alice.Path = path + "alice.vcf"
+	alice.ETag = "alice"
+
+	switch query.SyncToken {
+	case "":
+		if query.Limit == 1 {
+			return &SyncResponse{Updated: []AddressObject{*alice}, SyncToken: "urn:test:1", Truncated: true}, nil
+		}
+		return &SyncResponse{Updated: []AddressObject{*alice}, Deleted: []string{path + "bob.vcf"}, SyncToken: "urn:test:2"}, nil
+	case "urn:test:1":
+		return &SyncResponse{Deleted: []string{path + "bob.vcf"}, SyncToken: "urn:test:2"}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", webdav.ErrInvalidSyncToken, query.SyncToken)
+	}
+}
+
+func TestHandler_syncCollectionAuthorization(t *testing.T) {
+	const addressBookPath = "/test/contacts/private/"
+	ctx := context.Background()
+	az := webdav.NewMemAuthorizer()
+	az.SetACL(ctx, "/", []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Privileges: []xml.Name{webdav.PrivilegeRead}},
+	})
+	az.SetACL(ctx, addressBookPath+"alice.vcf", []webdav.ACE{
+		{Principal: webdav.PrincipalAll, Deny: true, Privileges: []xml.Name{webdav.PrivilegeRead}},
+	})
+	h := Handler{Backend: testSyncBackend{&testBackend{}}, Authorizer: az}
+
+	req := httptest.NewRequest("REPORT", addressBookPath, strings.NewReader(`<sync-collection xmlns="DAV:"><sync-token/><sync-level>1</sync-level><prop><getetag/></prop></sync-collection>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", "0")
+	ctx = context.WithValue(ctx, currentUserPrincipalKey, "/test/")
+	ctx = context.WithValue(ctx, homeSetPathKey, "/test/contacts/")
+	ctx = context.WithValue(ctx, addressBookPathKey, addressBookPath)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req.WithContext(ctx))
+
+	body := w.Body.String()
+	if w.Code != http.StatusMultiStatus || strings.Contains(body, "&#34;alice&#34;") {
+		t.Errorf("REPORT = %v, unreadable object returned:\n%v", w.Code, body)
+	}
+	if !strings.Contains(body, "<href>"+addressBookPath+"alice.vcf</href><responsedescription>403 Forbidden") {
+		t.Errorf("REPORT = %v, unreadable object not forbidden:\n%v", w.Code, body)
+	}
+}
+
+func TestHandler_syncCollection(t *testing.T) {
+	const addressBookPath = "/test/contacts/private/"
+	h := Handler{Backend: testSyncBackend{&testBackend{}}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, currentUserPrincipalKey, "/test/")
+		ctx = context.WithValue(ctx, homeSetPathKey, "/test/contacts/")
+		ctx = context.WithValue(ctx, addressBookPathKey, addressBookPath)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	do := func(method, body, depth string) (int, string) {
+		req, _ := http.NewRequest(method, ts.URL+addressBookPath, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Depth", depth)
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%v = %v", method, err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+	report := func(token string, limit int) (int, string) {
+		var limitElt string
+		if limit > 0 {
+			limitElt = fmt.Sprintf("<limit><nresults>%d</nresults></limit>", limit)
+		}
+		return do("REPORT", fmt.Sprintf(`<sync-collection xmlns="DAV:"><sync-token>%s</sync-token><sync-level>1</sync-level>%s<prop><getetag/></prop></sync-collection>`, token, limitElt), "0")
+	}
+
+	code, body := report("", 0)
+	if code != http.StatusMultiStatus || !strings.Contains(body, "<sync-token>urn:test:2</sync-token>") {
+		t.Errorf("initial REPORT = %v:\n%v", code, body)
+	}
+	if !strings.Contains(body, "<href>"+addressBookPath+"alice.vcf</href>") || !strings.Contains(body, "&#34;alice&#34;") {
+		t.Errorf("initial REPORT = %v, updated object missing:\n%v", code, body)
+	}
+	if !strings.Contains(body, "<href>"+addressBookPath+"bob.vcf</href><status>HTTP/1.1 404 Not Found</status>") {
+		t.Errorf("initial REPORT = %v, deleted object missing:\n%v", code, body)
+	}
+
+	code, body = report("urn:test:1", 0)
+	if code != http.StatusMultiStatus || strings.Contains(body, "alice.vcf") || !strings.Contains(body, "bob.vcf") {
+		t.Errorf("REPORT with token = %v:\n%v", code, body)
+	}
+	if code, body := report("urn:test:1", 1); code != http.StatusMultiStatus {
+		t.Errorf("REPORT within limit = %v:\n%v", code, body)
+	}
+
+	// The truncated response reports the address book itself with 507
+	code, body = report("", 1)
+	if code != http.StatusMultiStatus || !strings.Contains(body, "<sync-token>urn:test:1</sync-token>") || !strings.Contains(body, "507 Insufficient Storage") {
+		t.Errorf("REPORT with limit = %v:\n%v", code, body)
+	}
+
+	if code, body := report("urn:test:unknown", 0); code != http.StatusForbidden || !strings.Contains(body, "valid-sync-token") {
+		t.Errorf("REPORT with unknown token = %v:\n%v", code, body)
+	}
+	if code, _ := do("REPORT", `<sync-collection xmlns="DAV:"><sync-token/><sync-level>1</sync-level></sync-collection>`, "1"); code != http.StatusBadRequest {
+		t.Errorf("REPORT with Depth: 1 = %v, want %v", code, http.StatusBadRequest)
+	}
+
+	_, body = do("PROPFIND", `<propfind xmlns="DAV:"><prop><sync-token/></prop></propfind>`, "0")
+	if !strings.Contains(body, `<sync-token xmlns="DAV:">urn:test:2</sync-token>`) {
+		t.Errorf("PROPFIND sync-token:\n%s", body)
+	}
+
+	_, body = do("PROPFIND", `<propfind xmlns="DAV:"><prop><supported-report-set/></prop></propfind>`, "0")
+	if !strings.Contains(body, `<sync-collection xmlns="DAV:">`) || !strings.Contains(body, `<addressbook-query xmlns="urn:ietf:params:xml:ns:carddav">`) {
+		t.Errorf("PROPFIND supported-report-set:\n%s", body)
+	}
+}
@@ -42,6 +42,13 @@ func NewClient(c webdav.HTTPClient, endpoint string) (*Client, error) {
 	return &Client{wc, ic}, nil
 }
 
+// SetRetryPolicy sets the policy used to retry failed requests. A nil policy
+// disables retries, which is the default.
+func (c *Client) SetRetryPolicy(policy *webdav.RetryPolicy) {
+	c.Client.SetRetryPolicy(policy)
+	c.ic.SetRetryPolicy(policy)
+}
+
 func (c *Client) HasSupport(ctx context.Context) error {
 	classes, _, err := c.ic.Options(ctx, "")
 	if err != nil {
@@ -195,48 +202,66 @@ func encodeParamFilter(pf *ParamFilter) (*paramFilter, error) {
 func encodeTextMatch(tm *TextMatch) *textMatch {
 	return &textMatch{
 		Text:            tm.Text,
+		Collation:       string(tm.Collation),
 		NegateCondition: negateCondition(tm.NegateCondition),
 		MatchType:       matchType(tm.MatchType),
 	}
 }
 
 func decodeAddressList(ms *internal.MultiStatus) ([]AddressObject, error) {
-	addrs := make([]AddressObject, 0, len(ms.Responses))
-	errs := make([]error, 0, len(ms.Responses))
-	for _, resp := range ms.Responses {
+	l := make([]AddressObject, 0, len(ms.Responses))
+	var errs []error
+	decode := addressObjectDecoder(&errs, func(obj *AddressObject) error {
+		l = append(l, *obj)
+		return nil
+	})
+	for i := range ms.Responses {
+		if err := decode(&ms.Responses[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, errors.Join(errs...)
+}
+
+// addressObjectDecoder returns a multi-status response callback which decodes
+// objects and passes them to fn. Responses with an error status are appended
+// to errs.
+func addressObjectDecoder(errs *[]error, fn func(obj *AddressObject) error) func(resp *internal.Response) error {
+	return func(resp *internal.Response) error {
 		path, err := resp.Path()
 		if err != nil {
-			errs = append(errs, err)
-			continue
+			*errs = append(*errs, err)
+			return nil
 		}
 
 		var addrData addressDataResp
 		if err := resp.DecodeProp(&addrData); err != nil {
-			return nil, err
+			return err
 		}
 
 		var getLastMod internal.GetLastModified
 		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		var getETag internal.GetETag
 		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		var getContentLength internal.GetContentLength
 		if err := resp.DecodeProp(&getContentLength); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		r := bytes.NewReader(addrData.Data)
 		card, err := vcard.NewDecoder(r).Decode()
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		addrs = append(addrs, AddressObject{
+		return fn(&AddressObject{
 			Path:          path,
 			ModTime:       time.Time(getLastMod.LastModified),
 			ContentLength: getContentLength.Length,
@@ -244,11 +269,41 @@ func decodeAddressList(ms *internal.MultiStatus) ([]AddressObject, error) {
 			Card:          card,
 		})
 	}
-
-	return addrs, errors.Join(errs...)
 }
 
 func (c *Client) QueryAddressBook(ctx context.Context, addressBook string, query *AddressBookQuery) ([]AddressObject, error) {
+	req, err := c.newQueryAddressBookRequest(addressBook, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAddressList(ms)
+}
+
+// QueryAddressBookFunc is like QueryAddressBook, but calls fn for each address
+// object as soon as it's received, instead of holding the whole result in
+// memory.
+//
+// If fn returns an error, QueryAddressBookFunc stops and returns that error.
+func (c *Client) QueryAddressBookFunc(ctx context.Context, addressBook string, query *AddressBookQuery, fn func(ao *AddressObject) error) error {
+	req, err := c.newQueryAddressBookRequest(addressBook, query)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if _, err := c.ic.DoMultiStatusFunc(req.WithContext(ctx), addressObjectDecoder(&errs, fn)); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) newQueryAddressBookRequest(addressBook string, query *AddressBookQuery) (*http.Request, error) {
 	propReq, err := encodeAddressPropReq(&query.DataRequest)
 	if err != nil {
 		return nil, err
@@ -274,12 +329,7 @@ func (c *Client) QueryAddressBook(ctx context.Context, addressBook string, query
 
 	req.Header.Add("Depth", "1")
 
-	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
-	if err != nil {
-		return nil, err
-	}
-
-	return decodeAddressList(ms)
+	return req, nil
 }
 
 func (c *Client) MultiGetAddressBook(ctx context.Context, path string, multiGet *AddressBookMultiGet) ([]AddressObject, error) {
@@ -427,6 +477,29 @@ func (c *Client) PutAddressObject(ctx context.Context, path string, card vcard.C
 // SyncCollection performs a collection synchronization operation on the
 // specified resource, as defined in RFC 6578.
 func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	ret := &SyncResponse{}
+	syncToken, err := c.SyncCollectionFunc(ctx, path, query, func(ao *AddressObject, deleted bool) error {
+		if deleted {
+			ret.Deleted = append(ret.Deleted, ao.Path)
+		} else {
+			ret.Updated = append(ret.Updated, *ao)
+		}
+		return nil
+	})
+	if syncToken == "" && err != nil {
+		return nil, err
+	}
+	ret.SyncToken = syncToken
+	return ret, err
+}
+
+// SyncCollectionFunc is like SyncCollection, but calls fn for each change as
+// soon as it's received, instead of holding the whole result in memory. For
+// deleted address objects, only the Path is populated. The new sync-token is
+// returned.
+//
+// If fn returns an error, SyncCollectionFunc stops and returns that error.
+func (c *Client) SyncCollectionFunc(ctx context.Context, path string, query *SyncQuery, fn func(ao *AddressObject, deleted bool) error) (syncToken string, err error) {
 	var limit *internal.Limit
 	if query.Limit > 0 {
 		limit = &internal.Limit{NResults: uint(query.Limit)}
@@ -434,48 +507,43 @@ func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQue
 
 	propReq, err := encodeAddressPropReq(&query.DataRequest)
 	if err != nil {
-		return nil, err
-	}
-
-	ms, err := c.ic.SyncCollection(ctx, path, query.SyncToken, internal.DepthOne, limit, propReq)
-	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	ret := &SyncResponse{SyncToken: ms.SyncToken}
 	var errs []error
-	for _, resp := range ms.Responses {
+	ms, err := c.ic.SyncCollectionFunc(ctx, path, query.SyncToken, internal.DepthOne, limit, propReq, func(resp *internal.Response) error {
 		p, err := resp.Path()
 		if err != nil {
 			if err, ok := err.(*internal.HTTPError); ok && err.Code == http.StatusNotFound {
-				ret.Deleted = append(ret.Deleted, p)
-			} else {
-				errs = append(errs, err)
+				return fn(&AddressObject{Path: p}, true)
 			}
-			continue
+			errs = append(errs, err)
+			return nil
 		}
 
 		if p == path || path == fmt.Sprintf("%s/", p) {
-			continue
+			return nil
 		}
 
 		var getLastMod internal.GetLastModified
 		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
 		var getETag internal.GetETag
 		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
-			return nil, err
+			return err
 		}
 
-		o := AddressObject{
+		return fn(&AddressObject{
 			Path:    p,
 			ModTime: time.Time(getLastMod.LastModified),
 			ETag:    string(getETag.ETag),
-		}
-		ret.Updated = append(ret.Updated, o)
+		}, false)
+	})
+	if err != nil {
+		return "", err
 	}
 
-	return ret, errors.Join(errs...)
+	return ms.SyncToken, errors.Join(errs...)
 }
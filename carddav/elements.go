@@ -21,6 +21,9 @@ var (
 	addressBookMultigetName = xml.Name{namespace, "addressbook-multiget"}
 
 	addressDataName = xml.Name{namespace, "address-data"}
+
+	supportedCollationSetName = xml.Name{namespace, "supported-collation-set"}
+	supportedCollationName    = xml.Name{namespace, "supported-collation"}
 )
 
 // https://tools.ietf.org/html/rfc6352#section-6.2.3
@@ -192,8 +195,9 @@ type addressDataResp struct {
 }
 
 type reportReq struct {
-	Query    *addressbookQuery
-	Multiget *addressbookMultiget
+	Query          *addressbookQuery
+	Multiget       *addressbookMultiget
+	SyncCollection *internal.SyncCollectionQuery
 }
 
 func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -205,6 +209,9 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	case addressBookMultigetName:
 		r.Multiget = &addressbookMultiget{}
 		v = r.Multiget
+	case internal.SyncCollectionName:
+		r.SyncCollection = &internal.SyncCollectionQuery{}
+		v = r.SyncCollection
 	default:
 		return fmt.Errorf("carddav: unsupported REPORT root %q %q", start.Name.Space, start.Name.Local)
 	}
@@ -219,3 +226,9 @@ type mkcolReq struct {
 	Description  addressbookDescription `xml:"set>prop>addressbook-description"`
 	// TODO this could theoretically contain all addressbook properties?
 }
+
+// https://tools.ietf.org/html/rfc6352#section-8.3.1
+type supportedCollationSet struct {
+	XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:carddav supported-collation-set"`
+	Collation []string `xml:"supported-collation"`
+}
@@ -590,6 +590,71 @@ END:VCARD`)
 			addr: alice,
 			want: true,
 		},
+		{
+			name: "match-name-default-collation-ok",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{Text: "alice GOPHER", MatchType: MatchEquals}},
+					},
+				},
+			},
+			addr: alice,
+			want: true,
+		},
+		{
+			name: "match-name-octet-not",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{Text: "alice", Collation: CollationOctet}},
+					},
+				},
+			},
+			addr: alice,
+			want: false,
+		},
+		{
+			name: "match-name-octet-ok",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{Text: "Alice", Collation: CollationOctet}},
+					},
+				},
+			},
+			addr: alice,
+			want: true,
+		},
+		{
+			name: "match-name-ascii-casemap-ok",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{Text: "GOPHER", MatchType: MatchEndsWith, Collation: CollationASCIICaseMap}},
+					},
+				},
+			},
+			addr: alice,
+			want: true,
+		},
+		{
+			name: "invalid-collation",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{Text: "alice", Collation: "i;basic"}},
+					},
+				},
+			},
+			addr: alice,
+			err:  fmt.Errorf("unknown textmatch collation \"i;basic\""),
+		},
 		{
 			name: "invalid-query-filter",
 			query: &AddressBookQuery{
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"mime"
 	"net/http"
@@ -41,11 +42,35 @@ type Backend interface {
 	webdav.UserPrincipalBackend
 }
 
+// SyncBackend is an optional interface which can be implemented by a Backend
+// to support the sync-collection report defined in RFC 6578, which lets
+// clients fetch the changes made to an address book since their last
+// synchronization.
+type SyncBackend interface {
+	// AddressBookSyncToken returns a token identifying the current state of
+	// an address book. It must be an absolute URI.
+	AddressBookSyncToken(ctx context.Context, path string) (string, error)
+	// SyncAddressObjects returns the address objects created or updated
+	// since the state identified by query.SyncToken, the paths of the
+	// deleted ones, and the token of the current state. An empty token lists
+	// all objects.
+	//
+	// If there are more changes than query.Limit, the backend can either
+	// return the first ones with Truncated set, or fail with a 507 error.
+	//
+	// If the token is unknown or has expired, an error wrapping
+	// webdav.ErrInvalidSyncToken is returned.
+	SyncAddressObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error)
+}
+
 // Handler handles CardDAV HTTP requests. It can be used to create a CardDAV
 // server.
 type Handler struct {
 	Backend Backend
 	Prefix  string
+	// Authorizer enables access control when set. The current user's
+	// principal is obtained with Backend.CurrentUserPrincipal.
+	Authorizer webdav.Authorizer
 }
 
 // ServeHTTP implements http.Handler.
@@ -66,14 +91,20 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.authorize(r); err != nil {
+		internal.ServeError(w, err)
+		return
+	}
+
 	var err error
 	switch r.Method {
 	case "REPORT":
 		err = h.handleReport(w, r)
 	default:
 		b := backend{
-			Backend: h.Backend,
-			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+			Backend:    h.Backend,
+			Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+			Authorizer: h.Authorizer,
 		}
 		hh := internal.Handler{Backend: &b}
 		hh.ServeHTTP(w, r)
@@ -84,6 +115,25 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorize checks whether the current user is allowed to perform a request.
+func (h *Handler) authorize(r *http.Request) error {
+	if h.Authorizer == nil {
+		return nil
+	}
+	principal, err := h.Backend.CurrentUserPrincipal(r.Context())
+	if err != nil {
+		return err
+	}
+	exists := func(name string) (bool, error) {
+		_, err := h.Backend.GetAddressObject(r.Context(), name, &AddressDataRequest{})
+		if internal.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return internal.AuthorizeRequest(r, h.Authorizer, principal, exists)
+}
+
 func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 	var report reportReq
 	if err := internal.DecodeXMLRequest(r, &report); err != nil {
@@ -94,8 +144,10 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 		return h.handleQuery(r, w, report.Query)
 	} else if report.Multiget != nil {
 		return h.handleMultiget(r.Context(), w, report.Multiget)
+	} else if report.SyncCollection != nil {
+		return h.handleSyncCollection(r, w, report.SyncCollection)
 	}
-	return internal.HTTPErrorf(http.StatusBadRequest, "carddav: expected addressbook-query or addressbook-multiget element in REPORT request")
+	return internal.HTTPErrorf(http.StatusBadRequest, "carddav: expected addressbook-query, addressbook-multiget or sync-collection element in REPORT request")
 }
 
 func decodePropFilter(el *propFilter) (*PropFilter, error) {
@@ -106,8 +158,12 @@ func decodePropFilter(el *propFilter) (*PropFilter, error) {
 		}
 		pf.IsNotDefined = true
 	}
-	for _, tm := range el.TextMatches {
-		pf.TextMatches = append(pf.TextMatches, *decodeTextMatch(&tm))
+	for _, tmEl := range el.TextMatches {
+		tm, err := decodeTextMatch(&tmEl)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatches = append(pf.TextMatches, *tm)
 	}
 	for _, paramEl := range el.Params {
 		param, err := decodeParamFilter(&paramEl)
@@ -128,17 +184,25 @@ func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
 		pf.IsNotDefined = true
 	}
 	if el.TextMatch != nil {
-		pf.TextMatch = decodeTextMatch(el.TextMatch)
+		tm, err := decodeTextMatch(el.TextMatch)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatch = tm
 	}
 	return pf, nil
 }
 
-func decodeTextMatch(tm *textMatch) *TextMatch {
+func decodeTextMatch(tm *textMatch) (*TextMatch, error) {
+	if tm.Collation != "" && !internal.IsSupportedCollation(tm.Collation) {
+		return nil, internal.NewConditionError(http.StatusForbidden, supportedCollationName)
+	}
 	return &TextMatch{
 		Text:            tm.Text,
 		NegateCondition: bool(tm.NegateCondition),
 		MatchType:       MatchType(tm.MatchType),
-	}
+		Collation:       Collation(tm.Collation),
+	}, nil
 }
 
 func decodeAddressDataReq(addressData *addressDataReq) (*AddressDataRequest, error) {
@@ -186,12 +250,21 @@ func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *add
 		return err
 	}
 
+	b := backend{
+		Backend:    h.Backend,
+		Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+		Authorizer: h.Authorizer,
+	}
+
 	var resps []internal.Response
 	for _, ao := range aos {
-		b := backend{
-			Backend: h.Backend,
-			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+		// Objects the user can't read don't match
+		if err := b.authorizeRead(r.Context(), ao.Path); internal.IsForbidden(err) {
+			continue
+		} else if err != nil {
+			return err
 		}
+
 		propfind := internal.PropFind{
 			Prop:     query.Prop,
 			AllProp:  query.AllProp,
@@ -222,8 +295,20 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 		dataReq = *decoded
 	}
 
+	b := backend{
+		Backend:    h.Backend,
+		Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+		Authorizer: h.Authorizer,
+	}
+
 	var resps []internal.Response
 	for _, href := range multiget.Hrefs {
+		if err := b.authorizeRead(ctx, href.Path); err != nil {
+			resp := internal.NewErrorResponse(href.Path, err)
+			resps = append(resps, *resp)
+			continue
+		}
+
 		ao, err := h.Backend.GetAddressObject(ctx, href.Path, &dataReq)
 		if err != nil {
 			resp := internal.NewErrorResponse(href.Path, err)
@@ -231,10 +316,6 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 			continue
 		}
 
-		b := backend{
-			Backend: h.Backend,
-			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
-		}
 		propfind := internal.PropFind{
 			Prop:     multiget.Prop,
 			AllProp:  multiget.AllProp,
@@ -251,9 +332,77 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleSyncCollection(r *http.Request, w http.ResponseWriter, sync *internal.SyncCollectionQuery) error {
+	b := backend{
+		Backend:    h.Backend,
+		Prefix:     strings.TrimSuffix(h.Prefix, "/"),
+		Authorizer: h.Authorizer,
+	}
+	sb, ok := h.Backend.(SyncBackend)
+	if !ok || b.resourceTypeAtPath(r.URL.Path) != resourceTypeAddressBook {
+		return internal.NewConditionError(http.StatusForbidden, internal.SupportedReportName)
+	}
+
+	if err := internal.CheckSyncCollectionDepth(r.Header); err != nil {
+		return err
+	}
+	// Address books don't contain collections, so both sync levels are
+	// equivalent
+	if _, err := sync.Recursive(); err != nil {
+		return err
+	}
+
+	query := SyncQuery{SyncToken: sync.SyncToken}
+	if sync.Limit != nil {
+		query.Limit = int(sync.Limit.NResults)
+	}
+	if sync.Prop != nil {
+		var addressData addressDataReq
+		if err := sync.Prop.Decode(&addressData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		decoded, err := decodeAddressDataReq(&addressData)
+		if err != nil {
+			return err
+		}
+		query.DataRequest = *decoded
+	}
+
+	result, err := sb.SyncAddressObjects(r.Context(), r.URL.Path, &query)
+	if errors.Is(err, webdav.ErrInvalidSyncToken) {
+		return internal.NewConditionError(http.StatusForbidden, internal.ValidSyncTokenName)
+	} else if err != nil {
+		return err
+	}
+
+	if err := sync.CheckLimit(len(result.Updated) + len(result.Deleted)); err != nil {
+		return err
+	}
+
+	propfind := internal.PropFind{Prop: sync.Prop}
+	var resps []internal.Response
+	for i := range result.Updated {
+		ao := &result.Updated[i]
+		if err := b.authorizeRead(r.Context(), ao.Path); err != nil {
+			resps = append(resps, *internal.NewErrorResponse(ao.Path, err))
+			continue
+		}
+
+		resp, err := b.propFindAddressObject(r.Context(), &propfind, ao)
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+
+	ms := internal.NewSyncCollectionMultiStatus(r.URL.Path, result.SyncToken, resps, result.Deleted, result.Truncated)
+	return internal.ServeMultiStatus(w, ms)
+}
+
 type backend struct {
-	Backend Backend
-	Prefix  string
+	Backend    Backend
+	Prefix     string
+	Authorizer webdav.Authorizer
 }
 
 type resourceType int
@@ -332,96 +481,101 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth) (*internal.MultiStatus, error) {
+func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth, mw *internal.MultiStatusWriter) error {
 	resType := b.resourceTypeAtPath(r.URL.Path)
 
 	var dataReq AddressDataRequest
-	var resps []internal.Response
 
 	switch resType {
 	case resourceTypeRoot:
 		resp, err := b.propFindRoot(r.Context(), propfind)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 	case resourceTypeUserPrincipal:
 		principalPath, err := b.Backend.CurrentUserPrincipal(r.Context())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if r.URL.Path == principalPath {
 			resp, err := b.propFindUserPrincipal(r.Context(), propfind)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if err := mw.WriteResponse(resp); err != nil {
+				return err
 			}
-			resps = append(resps, *resp)
 			if depth != internal.DepthZero {
 				resp, err := b.propFindHomeSet(r.Context(), propfind)
 				if err != nil {
-					return nil, err
+					return err
+				}
+				if err := mw.WriteResponse(resp); err != nil {
+					return err
 				}
-				resps = append(resps, *resp)
 				if depth == internal.DepthInfinity {
-					resps_, err := b.propFindAllAddressBooks(r.Context(), propfind, true)
-					if err != nil {
-						return nil, err
+					if err := b.propFindAllAddressBooks(r.Context(), propfind, true, mw); err != nil {
+						return err
 					}
-					resps = append(resps, resps_...)
 				}
 			}
 		}
 	case resourceTypeAddressBookHomeSet:
 		homeSetPath, err := b.Backend.AddressBookHomeSetPath(r.Context())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if r.URL.Path == homeSetPath {
 			resp, err := b.propFindHomeSet(r.Context(), propfind)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if err := mw.WriteResponse(resp); err != nil {
+				return err
 			}
-			resps = append(resps, *resp)
 			if depth != internal.DepthZero {
 				recurse := depth == internal.DepthInfinity
-				resps_, err := b.propFindAllAddressBooks(r.Context(), propfind, recurse)
-				if err != nil {
-					return nil, err
+				if err := b.propFindAllAddressBooks(r.Context(), propfind, recurse, mw); err != nil {
+					return err
 				}
-				resps = append(resps, resps_...)
 			}
 		}
 	case resourceTypeAddressBook:
 		ab, err := b.Backend.GetAddressBook(r.Context(), r.URL.Path)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		resp, err := b.propFindAddressBook(r.Context(), propfind, ab)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 		if depth != internal.DepthZero {
-			resps_, err := b.propFindAllAddressObjects(r.Context(), propfind, ab)
-			if err != nil {
-				return nil, err
+			if err := b.propFindAllAddressObjects(r.Context(), propfind, ab, mw); err != nil {
+				return err
 			}
-			resps = append(resps, resps_...)
 		}
 	case resourceTypeAddressObject:
 		ao, err := b.Backend.GetAddressObject(r.Context(), r.URL.Path, &dataReq)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		resp, err := b.propFindAddressObject(r.Context(), propfind, ao)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 	}
 
-	return internal.NewMultiStatus(resps...), nil
+	return nil
 }
 
 func (b *backend) propFindRoot(ctx context.Context, propfind *internal.PropFind) (*internal.Response, error) {
@@ -481,6 +635,36 @@ func (b *backend) propFindHomeSet(ctx context.Context, propfind *internal.PropFi
 	return internal.NewPropFindResponse(homeSetPath, propfind, props)
 }
 
+// authorizeRead checks whether the current user can read a resource listed
+// in a response, in addition to the request-URI.
+func (b *backend) authorizeRead(ctx context.Context, name string) error {
+	if b.Authorizer == nil {
+		return nil
+	}
+	principal, err := b.Backend.CurrentUserPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	return b.Authorizer.Authorize(ctx, principal, name, internal.PrivilegeRead)
+}
+
+// currentUserPrivilegeSet returns the privileges of the current user on a
+// resource. Without an Authorizer, all users can read and write.
+func (b *backend) currentUserPrivilegeSet(ctx context.Context, name string) (*internal.CurrentUserPrivilegeSet, error) {
+	if b.Authorizer == nil {
+		return internal.NewCurrentUserPrivilegeSet(internal.PrivilegeRead, internal.PrivilegeWrite), nil
+	}
+	principal, err := b.Backend.CurrentUserPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	privs, err := internal.CurrentUserPrivileges(ctx, b.Authorizer, principal, name)
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewCurrentUserPrivilegeSet(privs...), nil
+}
+
 func (b *backend) propFindAddressBook(ctx context.Context, propfind *internal.PropFind, ab *AddressBook) (*internal.Response, error) {
 	props := map[xml.Name]internal.PropFindFunc{
 		internal.CurrentUserPrincipalName: func(*internal.RawXMLValue) (interface{}, error) {
@@ -497,15 +681,24 @@ func (b *backend) propFindAddressBook(ctx context.Context, propfind *internal.Pr
 				{ContentType: vcard.MIMEType, Version: "4.0"},
 			},
 		}),
-		// TODO: Gather UserPrivilege from backend to control read-and-write
-		internal.CurrentUserPrivilegeSetName: internal.PropFindValue(&internal.CurrentUserPrivilegeSet{
-			Privilege: []internal.Privilege{{
-				Read:  &struct{}{},
-				Write: &struct{}{},
-			}},
+		supportedCollationSetName: internal.PropFindValue(&supportedCollationSet{
+			Collation: []string{
+				string(CollationOctet),
+				string(CollationASCIICaseMap),
+				string(CollationUnicodeCaseMap),
+			},
 		}),
+		internal.CurrentUserPrivilegeSetName: func(*internal.RawXMLValue) (interface{}, error) {
+			return b.currentUserPrivilegeSet(ctx, ab.Path)
+		},
 	}
 
+	reports := []xml.Name{addressBookQueryName, addressBookMultigetName}
+	if _, ok := b.Backend.(SyncBackend); ok {
+		reports = append(reports, internal.SyncCollectionName)
+	}
+	props[internal.SupportedReportSetName] = internal.PropFindValue(internal.NewSupportedReportSet(reports...))
+
 	if ab.Name != "" {
 		props[internal.DisplayNameName] = internal.PropFindValue(&internal.DisplayName{
 			Name: ab.Name,
@@ -522,31 +715,47 @@ func (b *backend) propFindAddressBook(ctx context.Context, propfind *internal.Pr
 		})
 	}
 
+	if sb, ok := b.Backend.(SyncBackend); ok && propfind.AllProp == nil {
+		props[internal.SyncTokenName] = func(*internal.RawXMLValue) (interface{}, error) {
+			token, err := sb.AddressBookSyncToken(ctx, ab.Path)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.SyncToken{Token: token}, nil
+		}
+	}
+
 	return internal.NewPropFindResponse(ab.Path, propfind, props)
 }
 
-func (b *backend) propFindAllAddressBooks(ctx context.Context, propfind *internal.PropFind, recurse bool) ([]internal.Response, error) {
+func (b *backend) propFindAllAddressBooks(ctx context.Context, propfind *internal.PropFind, recurse bool, mw *internal.MultiStatusWriter) error {
 	abs, err := b.Backend.ListAddressBooks(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
 	for _, ab := range abs {
+		if err := b.authorizeRead(ctx, ab.Path); err != nil {
+			if err := mw.WriteResponse(internal.NewErrorResponse(ab.Path, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
 		resp, err := b.propFindAddressBook(ctx, propfind, &ab)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 		if recurse {
-			resps_, err := b.propFindAllAddressObjects(ctx, propfind, &ab)
-			if err != nil {
-				return nil, err
+			if err := b.propFindAllAddressObjects(ctx, propfind, &ab, mw); err != nil {
+				return err
 			}
-			resps = append(resps, resps_...)
 		}
 	}
-	return resps, nil
+	return nil
 }
 
 func (b *backend) propFindAddressObject(ctx context.Context, propfind *internal.PropFind, ao *AddressObject) (*internal.Response, error) {
@@ -592,22 +801,30 @@ func (b *backend) propFindAddressObject(ctx context.Context, propfind *internal.
 	return internal.NewPropFindResponse(ao.Path, propfind, props)
 }
 
-func (b *backend) propFindAllAddressObjects(ctx context.Context, propfind *internal.PropFind, ab *AddressBook) ([]internal.Response, error) {
+func (b *backend) propFindAllAddressObjects(ctx context.Context, propfind *internal.PropFind, ab *AddressBook, mw *internal.MultiStatusWriter) error {
 	var dataReq AddressDataRequest
 	aos, err := b.Backend.ListAddressObjects(ctx, ab.Path, &dataReq)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
 	for _, ao := range aos {
+		if err := b.authorizeRead(ctx, ao.Path); err != nil {
+			if err := mw.WriteResponse(internal.NewErrorResponse(ao.Path, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
 		resp, err := b.propFindAddressObject(ctx, propfind, &ao)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := mw.WriteResponse(resp); err != nil {
+			return err
 		}
-		resps = append(resps, *resp)
 	}
-	return resps, nil
+	return nil
 }
 
 func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
@@ -618,32 +835,15 @@ func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*
 
 	resp := internal.NewOKResponse(r.URL.Path)
 
+	code := http.StatusMethodNotAllowed
 	if r.URL.Path == homeSetPath {
 		// TODO: support PROPPATCH for address books
-		for _, prop := range update.Remove {
-			emptyVal := internal.NewRawXMLElement(prop.Prop.XMLName, nil, nil)
-			if err := resp.EncodeProp(http.StatusNotImplemented, emptyVal); err != nil {
-				return nil, err
-			}
-		}
-		for _, prop := range update.Set {
-			emptyVal := internal.NewRawXMLElement(prop.Prop.XMLName, nil, nil)
-			if err := resp.EncodeProp(http.StatusNotImplemented, emptyVal); err != nil {
-				return nil, err
-			}
-		}
-	} else {
-		for _, prop := range update.Remove {
-			emptyVal := internal.NewRawXMLElement(prop.Prop.XMLName, nil, nil)
-			if err := resp.EncodeProp(http.StatusMethodNotAllowed, emptyVal); err != nil {
-				return nil, err
-			}
-		}
-		for _, prop := range update.Set {
-			emptyVal := internal.NewRawXMLElement(prop.Prop.XMLName, nil, nil)
-			if err := resp.EncodeProp(http.StatusMethodNotAllowed, emptyVal); err != nil {
-				return nil, err
-			}
+		code = http.StatusNotImplemented
+	}
+	for _, inst := range update.Instructions {
+		emptyVal := internal.NewRawXMLElement(inst.Prop.XMLName, nil, nil)
+		if err := resp.EncodeProp(code, emptyVal); err != nil {
+			return nil, err
 		}
 	}
 
@@ -6,11 +6,18 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/emersion/go-webdav/internal"
 )
 
+// ErrPreconditionFailed is wrapped by the errors returned by Client when a
+// conditional request fails with 412 Precondition Failed, for instance
+// because the ETag passed in IfMatch doesn't match the server's version of a
+// file anymore.
+var ErrPreconditionFailed = errors.New("webdav: precondition failed")
+
 // HTTPClient performs HTTP requests. It's implemented by *http.Client.
 type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -45,7 +52,9 @@ type Client struct {
 //
 // If the HTTPClient is nil, http.DefaultClient is used.
 //
-// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used.
+// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used. See
+// also HTTPClientWithDigestAuth, HTTPClientWithBearerToken and
+// HTTPClientWithOAuth2.
 func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 	ic, err := internal.NewClient(c, endpoint)
 	if err != nil {
@@ -54,6 +63,26 @@ func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 	return &Client{ic}, nil
 }
 
+// RetryPolicy describes how failed requests are retried.
+//
+// Requests are retried after a network error, and when the server replies
+// with 429 Too Many Requests, 502 Bad Gateway, 503 Service Unavailable or
+// 504 Gateway Timeout. Only requests whose body can be replayed (see
+// http.Request.GetBody) are retried. Requests whose method isn't idempotent
+// are only retried when the server indicates that it hasn't processed them,
+// with a 429 or 503 status code.
+//
+// The delay between attempts grows exponentially, with some random jitter.
+// The delay indicated by a Retry-After header field takes precedence; the
+// request isn't retried if the server asks to wait longer than MaxBackoff.
+type RetryPolicy = internal.RetryPolicy
+
+// SetRetryPolicy sets the policy used to retry failed requests. A nil policy
+// disables retries, which is the default.
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.ic.SetRetryPolicy(policy)
+}
+
 // FindCurrentUserPrincipal finds the current user's principal path.
 func (c *Client) FindCurrentUserPrincipal(ctx context.Context) (string, error) {
 	propfind := internal.NewPropNamePropFind(internal.CurrentUserPrincipalName)
@@ -155,84 +184,404 @@ func (c *Client) Open(ctx context.Context, name string) (io.ReadCloser, error) {
 
 // ReadDir lists files in a directory.
 func (c *Client) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	var l []FileInfo
+	err := c.ReadDirFunc(ctx, name, recursive, func(fi *FileInfo) error {
+		l = append(l, *fi)
+		return nil
+	})
+	return l, err
+}
+
+// ReadDirFunc lists files in a directory, calling fn for each file as soon as
+// it's received. Unlike ReadDir, the whole listing is never held in memory.
+//
+// If fn returns an error, ReadDirFunc stops and returns that error.
+func (c *Client) ReadDirFunc(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
 	depth := internal.DepthOne
 	if recursive {
 		depth = internal.DepthInfinity
 	}
 
-	ms, err := c.ic.PropFind(ctx, name, depth, fileInfoPropFind)
+	req, err := c.ic.NewPropFindRequest(name, depth, fileInfoPropFind)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	l := make([]FileInfo, 0, len(ms.Responses))
-	errs := make([]error, 0, len(ms.Responses))
-	for _, resp := range ms.Responses {
-		fi, err := fileInfoFromResponse(&resp)
+	var errs []error
+	_, err = c.ic.DoMultiStatusFunc(req.WithContext(ctx), func(resp *internal.Response) error {
+		fi, err := fileInfoFromResponse(resp)
 		if err != nil {
 			errs = append(errs, err)
-		} else {
-			l = append(l, *fi)
+			return nil
 		}
+		return fn(fi)
+	})
+	if err != nil {
+		return err
 	}
 
-	return l, errors.Join(errs...)
+	return errors.Join(errs...)
 }
 
-type fileWriter struct {
+// FileWriter writes a file's contents. It is returned by
+// Client.CreateWithOptions.
+type FileWriter struct {
 	pw   *io.PipeWriter
 	done <-chan error
+
+	fi      *FileInfo
+	created bool
+	// stat fetches the file information, if the server hasn't sent an ETag
+	stat func() (*FileInfo, error)
 }
 
-func (fw *fileWriter) Write(b []byte) (int, error) {
+func (fw *FileWriter) Write(b []byte) (int, error) {
 	return fw.pw.Write(b)
 }
 
-func (fw *fileWriter) Close() error {
+// Close finishes writing the file, and waits for the server's reply.
+func (fw *FileWriter) Close() error {
 	if err := fw.pw.Close(); err != nil {
 		return err
 	}
 	return <-fw.done
 }
 
+// FileInfo returns information about the written file. It must only be
+// called after Close has returned a nil error.
+//
+// If the server hasn't replied with an ETag, which happens when it has altered
+// the contents, FileInfo fetches the file information with a PROPFIND request.
+// The file has been written anyway, so if that fails, the ETag is left empty.
+func (fw *FileWriter) FileInfo() *FileInfo {
+	if fw.stat != nil {
+		if fi, err := fw.stat(); err == nil {
+			fw.fi = fi
+		}
+		fw.stat = nil
+	}
+	return fw.fi
+}
+
+// Created reports whether the file has been created, rather than
+// overwritten. It must only be called after Close has returned a nil error.
+func (fw *FileWriter) Created() bool {
+	return fw.created
+}
+
 // Create writes a file's contents.
 func (c *Client) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return c.CreateWithOptions(ctx, name, nil)
+}
+
+// CreateWithOptions writes a file's contents.
+//
+// If a condition in opts isn't fulfilled, Close returns an error wrapping
+// ErrPreconditionFailed. This can be used for optimistic concurrency control,
+// by setting IfMatch to the ETag of the version of the file which has been
+// read last.
+func (c *Client) CreateWithOptions(ctx context.Context, name string, opts *CreateOptions) (*FileWriter, error) {
 	pr, pw := io.Pipe()
 
-	req, err := c.ic.NewRequest(http.MethodPut, name, pr)
+	req, err := c.newPutRequest(name, pr, opts)
 	if err != nil {
 		pw.Close()
 		return nil, err
 	}
 
 	done := make(chan error, 1)
+	fw := &FileWriter{pw: pw, done: done}
 	go func() {
-		resp, err := c.ic.Do(req.WithContext(ctx))
-		if err != nil {
-			done <- err
-			return
+		fi, created, err := c.doPut(ctx, req)
+		// Unblock writers if the request has failed before reading the
+		// whole body
+		pr.CloseWithError(err)
+		fw.fi, fw.created = fi, created
+		if err == nil && fi.ETag == "" {
+			fw.stat = func() (*FileInfo, error) {
+				return c.Stat(ctx, name)
+			}
 		}
-		resp.Body.Close()
-		done <- nil
+		done <- err
 	}()
 
-	return &fileWriter{pw, done}, nil
+	return fw, nil
+}
+
+// UploadOptions configures Client.Upload.
+type UploadOptions struct {
+	// ChunkSize is the number of bytes sent per request. Defaults to 8MiB.
+	ChunkSize int64
+	// MaxRetries is the number of consecutive failed requests tolerated
+	// before giving up. Defaults to 3.
+	MaxRetries int
+	// Resume continues an upload left pending on the server by a previous
+	// call, instead of starting over.
+	Resume bool
+
+	IfMatch     ConditionalMatch
+	IfNoneMatch ConditionalMatch
+}
+
+// Upload writes a file's contents in chunks, with partial PUT requests. If a
+// request fails, the upload is resumed from the last byte received by the
+// server. The file is only replaced once all chunks are received.
+//
+// The server needs to support partial PUT requests, as Handler does when its
+// FileSystem implements UploadFileSystem.
+func (c *Client) Upload(ctx context.Context, name string, r io.ReaderAt, size int64, opts *UploadOptions) error {
+	if opts == nil {
+		opts = new(UploadOptions)
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 8 << 20
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 3
+	}
+
+	// Empty files can't be described with a byte range
+	if size == 0 {
+		req, err := c.newUploadRequest(name, nil, 0, opts)
+		if err != nil {
+			return err
+		}
+		_, _, err = c.doPut(ctx, req)
+		return err
+	}
+
+	var offset int64
+	if opts.Resume {
+		var done bool
+		var err error
+		offset, done, err = c.uploadOffset(ctx, name, size, opts)
+		if err != nil || done {
+			return err
+		}
+	}
+
+	failures := 0
+	for {
+		end := offset + chunkSize
+		if end > size {
+			end = size
+		}
+
+		received, done, err := c.uploadChunk(ctx, name, r, offset, end, size, opts)
+		if err == nil && done {
+			return nil
+		} else if err == nil && received > offset {
+			offset = received
+			failures = 0
+			continue
+		} else if err == nil {
+			err = fmt.Errorf("webdav: server didn't accept upload chunk")
+		} else if !isRetriableUploadError(ctx, err) {
+			return err
+		}
+
+		failures++
+		if failures > maxRetries {
+			return err
+		}
+
+		offset, done, err = c.uploadOffset(ctx, name, size, opts)
+		if err != nil {
+			return err
+		} else if done {
+			return nil
+		}
+	}
+}
+
+func isRetriableUploadError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var httpErr *internal.HTTPError
+	if !errors.As(err, &httpErr) {
+		return true // network error
+	}
+	return httpErr.Code/100 == 5 || httpErr.Code == http.StatusRequestedRangeNotSatisfiable
+}
+
+func (c *Client) newUploadRequest(name string, body io.Reader, length int64, opts *UploadOptions) (*http.Request, error) {
+	req, err := c.newPutRequest(name, body, &CreateOptions{
+		IfMatch:     opts.IfMatch,
+		IfNoneMatch: opts.IfNoneMatch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	return req, nil
+}
+
+// uploadChunk sends the bytes between offset and end. It returns the number
+// of bytes received by the server so far, and whether the file has been
+// created.
+func (c *Client) uploadChunk(ctx context.Context, name string, r io.ReaderAt, offset, end, size int64, opts *UploadOptions) (received int64, done bool, err error) {
+	req, err := c.newUploadRequest(name, io.NewSectionReader(r, offset, end-offset), end-offset, opts)
+	if err != nil {
+		return 0, false, err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(r, offset, end-offset)), nil
+	}
+	cr := internal.ContentRange{First: offset, Last: end - 1, Length: size}
+	req.Header.Set("Content-Range", cr.String())
+	return c.doUploadRequest(ctx, req, end == size)
+}
+
+// uploadOffset queries the number of bytes of a pending upload received by
+// the server. If all bytes have been received, the server creates the file.
+func (c *Client) uploadOffset(ctx context.Context, name string, size int64, opts *UploadOptions) (received int64, done bool, err error) {
+	req, err := c.newUploadRequest(name, nil, 0, opts)
+	if err != nil {
+		return 0, false, err
+	}
+	cr := internal.ContentRange{First: -1, Last: -1, Length: size}
+	req.Header.Set("Content-Range", cr.String())
+	return c.doUploadRequest(ctx, req, true)
+}
+
+func (c *Client) doUploadRequest(ctx context.Context, req *http.Request, final bool) (received int64, done bool, err error) {
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, false, err
+	}
+	resp.Body.Close()
+
+	// The server replies with 202 Accepted until the upload is complete
+	if resp.StatusCode != http.StatusAccepted {
+		if !final {
+			// The server has ignored the Content-Range header and
+			// stored the chunk as the whole file
+			return 0, false, fmt.Errorf("webdav: server doesn't support partial uploads")
+		}
+		return 0, true, nil
+	}
+
+	s := resp.Header.Get("Range")
+	if s == "" {
+		return 0, false, nil
+	}
+	var last int64
+	if _, err := fmt.Sscanf(s, "bytes=0-%d", &last); err != nil {
+		return 0, false, fmt.Errorf("webdav: malformed Range header in upload response: %v", err)
+	}
+	return last + 1, false, nil
+}
+
+func (c *Client) newPutRequest(name string, body io.Reader, opts *CreateOptions) (*http.Request, error) {
+	req, err := c.ic.NewRequest(http.MethodPut, name, body)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil {
+		setConditionalHeaders(req.Header, opts.IfMatch, opts.IfNoneMatch)
+		if opts.ContentType != "" {
+			req.Header.Set("Content-Type", opts.ContentType)
+		}
+		if opts.ContentLength > 0 {
+			req.ContentLength = opts.ContentLength
+		}
+	}
+	return req, nil
+}
+
+// countingReadCloser counts the bytes read from a request body.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64 // accessed atomically, the body is read by the transport
+}
+
+func (cr *countingReadCloser) Read(b []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(b)
+	atomic.AddInt64(&cr.n, int64(n))
+	return n, err
+}
+
+// doPut sends a PUT request. The returned FileInfo is populated from the
+// request and the response header, and lacks the ETag if the server hasn't
+// sent one.
+func (c *Client) doPut(ctx context.Context, req *http.Request) (fi *FileInfo, created bool, err error) {
+	// The size of streamed bodies is only known once they've been sent
+	var body *countingReadCloser
+	if req.ContentLength <= 0 && req.Body != nil && req.Body != http.NoBody {
+		body = &countingReadCloser{ReadCloser: req.Body}
+		req.Body = body
+	}
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, false, wrapPreconditionError(err)
+	}
+	resp.Body.Close()
+
+	fi = &FileInfo{
+		Path:     req.URL.Path,
+		MIMEType: req.Header.Get("Content-Type"),
+	}
+	if body != nil {
+		fi.Size = atomic.LoadInt64(&body.n)
+	} else if req.ContentLength > 0 {
+		fi.Size = req.ContentLength
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		fi.ModTime = t
+	}
+	if s := resp.Header.Get("ETag"); s != "" {
+		var etag internal.ETag
+		if err := etag.UnmarshalText([]byte(s)); err == nil {
+			fi.ETag = string(etag)
+		}
+	}
+	return fi, resp.StatusCode == http.StatusCreated, nil
+}
+
+// wrapPreconditionError wraps HTTP 412 errors with ErrPreconditionFailed.
+func wrapPreconditionError(err error) error {
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %w", ErrPreconditionFailed, err)
+	}
+	return err
+}
+
+func setConditionalHeaders(h http.Header, ifMatch, ifNoneMatch ConditionalMatch) {
+	if ifMatch.IsSet() {
+		h.Set("If-Match", string(ifMatch))
+	}
+	if ifNoneMatch.IsSet() {
+		h.Set("If-None-Match", string(ifNoneMatch))
+	}
 }
 
 // RemoveAll deletes a file. If the file is a directory, all of its descendants
-// are recursively deleted as well.
+// are recursively deleted as well. If the server fails to delete some of
+// them, their errors are joined with errors.Join.
 func (c *Client) RemoveAll(ctx context.Context, name string) error {
+	return c.removeAll(ctx, name, nil)
+}
+
+func (c *Client) removeAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
 	req, err := c.ic.NewRequest(http.MethodDelete, name, nil)
 	if err != nil {
 		return err
 	}
+	if opts != nil {
+		setConditionalHeaders(req.Header, opts.IfMatch, opts.IfNoneMatch)
+	}
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return wrapPreconditionError(err)
 	}
-	resp.Body.Close()
-	return nil
+	defer resp.Body.Close()
+	return internal.MultiStatusError(resp)
 }
 
 // Mkdir creates a new directory.
@@ -253,15 +602,21 @@ func (c *Client) Mkdir(ctx context.Context, name string) error {
 // Copy copies a file.
 //
 // By default, if the file is a directory, all descendants are recursively
-// copied as well.
+// copied as well. If the server fails to copy some of them, their errors are
+// joined with errors.Join.
 func (c *Client) Copy(ctx context.Context, name, dest string, options *CopyOptions) error {
+	_, err := c.copy(ctx, name, dest, options)
+	return err
+}
+
+func (c *Client) copy(ctx context.Context, name, dest string, options *CopyOptions) (created bool, err error) {
 	if options == nil {
 		options = new(CopyOptions)
 	}
 
 	req, err := c.ic.NewRequest("COPY", name, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	depth := internal.DepthInfinity
@@ -275,21 +630,30 @@ func (c *Client) Copy(ctx context.Context, name, dest string, options *CopyOptio
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return false, wrapPreconditionError(err)
 	}
-	resp.Body.Close()
-	return nil
+	defer resp.Body.Close()
+	if err := internal.MultiStatusError(resp); err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusCreated, nil
 }
 
-// Move moves a file.
+// Move moves a file. If the server fails to move some descendants of a
+// directory, their errors are joined with errors.Join.
 func (c *Client) Move(ctx context.Context, name, dest string, options *MoveOptions) error {
+	_, err := c.move(ctx, name, dest, options)
+	return err
+}
+
+func (c *Client) move(ctx context.Context, name, dest string, options *MoveOptions) (created bool, err error) {
 	if options == nil {
 		options = new(MoveOptions)
 	}
 
 	req, err := c.ic.NewRequest("MOVE", name, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	req.Header.Set("Destination", c.ic.ResolveHref(dest).String())
@@ -297,8 +661,11 @@ func (c *Client) Move(ctx context.Context, name, dest string, options *MoveOptio
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return false, wrapPreconditionError(err)
 	}
-	resp.Body.Close()
-	return nil
+	defer resp.Body.Close()
+	if err := internal.MultiStatusError(resp); err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusCreated, nil
 }
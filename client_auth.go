@@ -0,0 +1,460 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type bearerTokenHTTPClient struct {
+	c     HTTPClient
+	token string
+}
+
+func (c *bearerTokenHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return c.c.Do(req)
+}
+
+// HTTPClientWithBearerToken returns an HTTP client that adds a static bearer
+// token (RFC 6750) to all outgoing requests. If c is nil, http.DefaultClient
+// is used.
+func HTTPClientWithBearerToken(c HTTPClient, token string) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &bearerTokenHTTPClient{c, token}
+}
+
+// rewindRequest returns a copy of req which can be sent again, or nil if the
+// request body can't be replayed.
+func rewindRequest(req *http.Request) *http.Request {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone
+	}
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	clone.Body = body
+	return clone
+}
+
+func discardResponse(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+}
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm string
+	qop                             bool
+	stale                           bool
+}
+
+func (c *digestChallenge) hashFunc() func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	default:
+		return nil
+	}
+}
+
+// parseDigestChallenge picks the strongest supported Digest challenge from
+// WWW-Authenticate header fields.
+func parseDigestChallenge(h http.Header) *digestChallenge {
+	var best *digestChallenge
+	for _, v := range h.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		if qop, ok := params["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					c.qop = true
+				}
+			}
+			if !c.qop {
+				// Only auth-int is offered, which isn't supported
+				continue
+			}
+		}
+		if c.nonce == "" || c.hashFunc() == nil {
+			continue
+		}
+		if best == nil || strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") {
+			best = c
+		}
+	}
+	return best
+}
+
+// parseAuthParams parses a comma-separated list of authentication parameters,
+// as defined in RFC 7235 section 2.1.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		k := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var v string
+		if strings.HasPrefix(s, `"`) {
+			var sb strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			v = sb.String()
+			if i < len(s) {
+				i++ // closing quote
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			v = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[k] = v
+	}
+}
+
+type digestAuthHTTPClient struct {
+	c                  HTTPClient
+	username, password string
+
+	mutex     sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+// authorization computes the Authorization header field for a request, as
+// defined in RFC 7616 section 3.4.
+func (c *digestAuthHTTPClient) authorization(req *http.Request) (string, error) {
+	c.mutex.Lock()
+	ch := c.challenge
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	c.mutex.Unlock()
+
+	h := ch.hashFunc()
+	sum := func(parts ...string) string {
+		hh := h()
+		io.WriteString(hh, strings.Join(parts, ":"))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(b[:])
+
+	uri := req.URL.RequestURI()
+	ha1 := sum(c.username, ch.realm, c.password)
+	if strings.HasSuffix(strings.ToLower(ch.algorithm), "-sess") {
+		ha1 = sum(ha1, ch.nonce, cnonce)
+	}
+	ha2 := sum(req.Method, uri)
+
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, quote(c.username)),
+		fmt.Sprintf(`realm="%s"`, quote(ch.realm)),
+		fmt.Sprintf(`nonce="%s"`, quote(ch.nonce)),
+		fmt.Sprintf(`uri="%s"`, quote(uri)),
+	}
+	if ch.qop {
+		fields = append(fields,
+			fmt.Sprintf(`response="%s"`, sum(ha1, ch.nonce, nc, cnonce, "auth", ha2)),
+			"qop=auth",
+			"nc="+nc,
+			fmt.Sprintf(`cnonce="%s"`, cnonce),
+		)
+	} else {
+		fields = append(fields, fmt.Sprintf(`response="%s"`, sum(ha1, ch.nonce, ha2)))
+	}
+	if ch.algorithm != "" {
+		fields = append(fields, "algorithm="+ch.algorithm)
+	}
+	if ch.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, quote(ch.opaque)))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+func (c *digestAuthHTTPClient) do(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	hasChallenge := c.challenge != nil
+	c.mutex.Unlock()
+	if hasChallenge {
+		auth, err := c.authorization(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+	}
+	return c.c.Do(req)
+}
+
+func (c *digestAuthHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	retry := rewindRequest(req)
+
+	resp, err := c.do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || retry == nil {
+		return resp, err
+	}
+
+	ch := parseDigestChallenge(resp.Header)
+	if ch == nil {
+		return resp, nil
+	}
+
+	c.mutex.Lock()
+	// Credentials sent along a fresh nonce have been rejected
+	rejected := c.challenge != nil && c.challenge.nonce == ch.nonce && !ch.stale
+	if !rejected {
+		c.challenge = ch
+		c.nc = 0
+	}
+	c.mutex.Unlock()
+	if rejected {
+		return resp, nil
+	}
+
+	discardResponse(resp)
+	return c.do(retry)
+}
+
+// HTTPClientWithDigestAuth returns an HTTP client that authenticates
+// outgoing requests with HTTP Digest access authentication, as defined in
+// RFC 7616. The MD5 and SHA-256 algorithms are supported, with the "auth"
+// quality of protection.
+//
+// The first request is sent without credentials, and is sent again once the
+// server has replied with a challenge. Subsequent requests reuse the
+// challenge. Requests whose body can't be replayed (see
+// http.Request.GetBody) aren't sent again. If c is nil, http.DefaultClient is
+// used.
+func HTTPClientWithDigestAuth(c HTTPClient, username, password string) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &digestAuthHTTPClient{c: c, username: username, password: password}
+}
+
+// OAuth2Token is an OAuth 2.0 token, as defined in RFC 6749.
+type OAuth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	// Expiry is the time when the access token expires, or the zero time if
+	// unknown.
+	Expiry time.Time
+}
+
+// OAuth2Config describes how to renew OAuth 2.0 access tokens with the
+// refresh token grant, defined in RFC 6749 section 6.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// OnToken, if non-nil, is called each time a new token is obtained, for
+	// instance to persist a rotated refresh token.
+	OnToken func(*OAuth2Token)
+}
+
+type oauth2HTTPClient struct {
+	c      HTTPClient
+	config *OAuth2Config
+
+	mutex sync.Mutex
+	token OAuth2Token
+}
+
+// oauth2ExpiryDelta is how long before its expiry an access token is renewed.
+const oauth2ExpiryDelta = 10 * time.Second
+
+type oauth2Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (err *oauth2Error) Error() string {
+	if err.Code == "" {
+		return fmt.Sprintf("webdav: OAuth 2.0 token request failed with HTTP status %v", err.StatusCode)
+	}
+	if err.Description == "" {
+		return fmt.Sprintf("webdav: OAuth 2.0 token request failed: %v", err.Code)
+	}
+	return fmt.Sprintf("webdav: OAuth 2.0 token request failed: %v: %v", err.Code, err.Description)
+}
+
+// refresh obtains a new access token, unless the access token has changed
+// since old has been sent.
+func (c *oauth2HTTPClient) refresh(req *http.Request, old string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.token.AccessToken != old {
+		return c.token.AccessToken, nil
+	}
+	if c.token.RefreshToken == "" {
+		return "", fmt.Errorf("webdav: OAuth 2.0 access token expired and no refresh token is available")
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.token.RefreshToken},
+	}
+	if len(c.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+	if c.config.ClientSecret == "" {
+		form.Set("client_id", c.config.ClientID)
+	}
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, c.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		tokenReq.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	resp, err := c.c.Do(tokenReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		oerr := &oauth2Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(body, oerr)
+		return "", oerr
+	}
+
+	var data struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", fmt.Errorf("webdav: malformed OAuth 2.0 token response: %v", err)
+	}
+	if data.AccessToken == "" {
+		return "", fmt.Errorf("webdav: OAuth 2.0 token response is missing an access token")
+	}
+	if data.TokenType != "" && !strings.EqualFold(data.TokenType, "Bearer") {
+		return "", fmt.Errorf("webdav: unsupported OAuth 2.0 token type %q", data.TokenType)
+	}
+
+	c.token.AccessToken = data.AccessToken
+	if data.RefreshToken != "" {
+		c.token.RefreshToken = data.RefreshToken
+	}
+	c.token.Expiry = time.Time{}
+	if data.ExpiresIn > 0 {
+		c.token.Expiry = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	if c.config.OnToken != nil {
+		token := c.token
+		c.config.OnToken(&token)
+	}
+	return c.token.AccessToken, nil
+}
+
+func (c *oauth2HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	accessToken := c.token.AccessToken
+	expired := !c.token.Expiry.IsZero() && time.Now().Add(oauth2ExpiryDelta).After(c.token.Expiry)
+	c.mutex.Unlock()
+
+	refreshed := false
+	if accessToken == "" || expired {
+		var err error
+		if accessToken, err = c.refresh(req, accessToken); err != nil {
+			return nil, err
+		}
+		refreshed = true
+	}
+
+	retry := rewindRequest(req)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := c.c.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || refreshed || retry == nil {
+		return resp, err
+	}
+
+	// The access token may have been revoked before its expiry
+	newToken, err := c.refresh(req, accessToken)
+	if err != nil {
+		discardResponse(resp)
+		return nil, err
+	}
+	discardResponse(resp)
+	retry.Header.Set("Authorization", "Bearer "+newToken)
+	return c.c.Do(retry)
+}
+
+// HTTPClientWithOAuth2 returns an HTTP client that adds an OAuth 2.0 bearer
+// token to all outgoing requests. The access token is renewed with the
+// refresh token when it expires, and when the server replies with
+// 401 Unauthorized, in which case the request is sent once more. Requests
+// whose body can't be replayed (see http.Request.GetBody) aren't sent again.
+//
+// token.AccessToken may be empty, in which case an access token is obtained
+// before the first request. The same HTTP client is used to send token
+// requests. If c is nil, http.DefaultClient is used.
+func HTTPClientWithOAuth2(c HTTPClient, config *OAuth2Config, token *OAuth2Token) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &oauth2HTTPClient{c: c, config: config, token: *token}
+}
@@ -0,0 +1,162 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthParams(t *testing.T) {
+	got := parseAuthParams(`realm="a \"b\", c", qop="auth,auth-int", algorithm=MD5, stale=TRUE`)
+	want := map[string]string{
+		"realm":     `a "b", c`,
+		"qop":       "auth,auth-int",
+		"algorithm": "MD5",
+		"stale":     "TRUE",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseAuthParams() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseAuthParams()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestHTTPClientWithDigestAuth(t *testing.T) {
+	const realm, nonce = "test@example.org", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var challenges int
+	var ncs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+		ha1 := md5Hex("alice:" + realm + ":secret")
+		ha2 := md5Hex(r.Method + ":" + r.URL.RequestURI())
+		want := md5Hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+		if params["response"] != want || params["opaque"] != "xyz" || params["uri"] != r.URL.RequestURI() {
+			challenges++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="xyz"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ncs = append(ncs, params["nc"])
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	c := HTTPClientWithDigestAuth(ts.Client(), "alice", "secret")
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/file.txt?x=1", strings.NewReader("hello"))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do() = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+			t.Errorf("request #%v: status = %v, body = %q", i, resp.StatusCode, body)
+		}
+	}
+	if challenges != 1 {
+		t.Errorf("got %v challenges, want 1", challenges)
+	}
+	if len(ncs) != 2 || ncs[0] != "00000001" || ncs[1] != "00000002" {
+		t.Errorf("nonce counts = %v", ncs)
+	}
+
+	c = HTTPClientWithDigestAuth(ts.Client(), "alice", "wrong")
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestHTTPClientWithOAuth2(t *testing.T) {
+	var tokenRequests int
+	validToken := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			user, pass, _ := r.BasicAuth()
+			if user != "client" || pass != "s3cret" || r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != fmt.Sprintf("refresh%v", tokenRequests) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid_grant","error_description":"bad refresh token"}`)
+				return
+			}
+			validToken = fmt.Sprintf("access%v", tokenRequests)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":3600,"refresh_token":"refresh%v"}`, validToken, tokenRequests+1)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	var tokens []OAuth2Token
+	config := &OAuth2Config{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		OnToken:      func(token *OAuth2Token) { tokens = append(tokens, *token) },
+	}
+	c := HTTPClientWithOAuth2(ts.Client(), config, &OAuth2Token{AccessToken: "revoked", RefreshToken: "refresh1"})
+
+	do := func() *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/file.txt", strings.NewReader("hello"))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do() = %v", err)
+		}
+		return resp
+	}
+
+	resp := do()
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("status = %v, body = %q", resp.StatusCode, body)
+	}
+	if tokenRequests != 1 || len(tokens) != 1 || tokens[0].AccessToken != "access1" || tokens[0].RefreshToken != "refresh2" {
+		t.Errorf("token requests = %v, tokens = %+v", tokenRequests, tokens)
+	}
+
+	// Revoking the access token triggers another refresh with the rotated
+	// refresh token
+	validToken = "other"
+	resp = do()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || tokenRequests != 2 {
+		t.Errorf("after revocation: status = %v, token requests = %v", resp.StatusCode, tokenRequests)
+	}
+
+	// A failed refresh is reported
+	config.ClientSecret = "wrong"
+	validToken = "other"
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/file.txt", nil)
+	if _, err := c.Do(req); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Do() with failed refresh = %v, want invalid_grant error", err)
+	}
+}
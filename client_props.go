@@ -0,0 +1,294 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// Depth indicates whether a request applies to the members of a collection.
+type Depth int
+
+const (
+	// DepthZero indicates that the request applies only to the resource.
+	DepthZero = Depth(internal.DepthZero)
+	// DepthOne indicates that the request applies to the resource and its
+	// internal members only.
+	DepthOne = Depth(internal.DepthOne)
+	// DepthInfinity indicates that the request applies to the resource and
+	// all of its members.
+	DepthInfinity = Depth(internal.DepthInfinity)
+)
+
+// Names of common properties defined in RFC 4918 and RFC 4331.
+var (
+	PropertyDisplayName         = internal.DisplayNameName
+	PropertyResourceType        = internal.ResourceTypeName
+	PropertyGetContentLength    = internal.GetContentLengthName
+	PropertyGetContentType      = internal.GetContentTypeName
+	PropertyGetETag             = internal.GetETagName
+	PropertyGetLastModified     = internal.GetLastModifiedName
+	PropertyQuotaAvailableBytes = internal.QuotaAvailableBytesName
+	PropertyQuotaUsedBytes      = internal.QuotaUsedBytesName
+)
+
+// NewProperty encodes a value into a property. The value must be an
+// XML-encodable struct, whose XMLName field or struct tag names the property.
+func NewProperty(v interface{}) (*Property, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var prop Property
+	if err := xml.Unmarshal(b, &prop); err != nil {
+		return nil, err
+	}
+	return &prop, nil
+}
+
+// NewDisplayNameProperty returns a DAV:displayname property.
+func NewDisplayNameProperty(name string) *Property {
+	prop, _ := NewProperty(&internal.DisplayName{Name: name})
+	return prop
+}
+
+// Decode decodes the property into a value, with the rules of xml.Unmarshal.
+func (prop *Property) Decode(v interface{}) error {
+	b, err := xml.Marshal(prop)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(b, v)
+}
+
+// PropertyStatus is a property returned by the server, along with its
+// status.
+type PropertyStatus struct {
+	Property
+	// StatusCode is the HTTP status code of the property, for instance
+	// 200 OK if the property has been found or updated, or 404 Not Found if
+	// the property doesn't exist.
+	StatusCode int
+}
+
+// Err returns an error if the status code isn't 2xx.
+func (ps *PropertyStatus) Err() error {
+	if ps.StatusCode/100 == 2 {
+		return nil
+	}
+	return &internal.HTTPError{
+		Code: ps.StatusCode,
+		Err:  fmt.Errorf("webdav: property %v %v", ps.XMLName.Space, ps.XMLName.Local),
+	}
+}
+
+// PropFindResult holds the properties of a resource returned by
+// Client.PropFind.
+type PropFindResult struct {
+	Path  string
+	Props []PropertyStatus
+}
+
+// Get returns a property. If the server didn't return the property
+// successfully, an HTTP error with the property's status code is returned.
+func (r *PropFindResult) Get(name xml.Name) (*Property, error) {
+	for i := range r.Props {
+		ps := &r.Props[i]
+		if ps.XMLName != name {
+			continue
+		}
+		if err := ps.Err(); err != nil {
+			return nil, err
+		}
+		return &ps.Property, nil
+	}
+	return nil, internal.HTTPErrorf(http.StatusNotFound, "webdav: missing property %v %v", name.Space, name.Local)
+}
+
+// Decode decodes a property into a value, with the rules of xml.Unmarshal.
+func (r *PropFindResult) Decode(name xml.Name, v interface{}) error {
+	prop, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	return prop.Decode(v)
+}
+
+// DisplayName returns the DAV:displayname property.
+func (r *PropFindResult) DisplayName() (string, error) {
+	var v internal.DisplayName
+	if err := r.Decode(PropertyDisplayName, &v); err != nil {
+		return "", err
+	}
+	return v.Name, nil
+}
+
+// ResourceType returns the names of the elements of the DAV:resourcetype
+// property. Collections contain {DAV:}collection.
+func (r *PropFindResult) ResourceType() ([]xml.Name, error) {
+	var v internal.ResourceType
+	if err := r.Decode(PropertyResourceType, &v); err != nil {
+		return nil, err
+	}
+	var l []xml.Name
+	for _, raw := range v.Raw {
+		if name, ok := raw.XMLName(); ok {
+			l = append(l, name)
+		}
+	}
+	return l, nil
+}
+
+// Quota returns the DAV:quota-available-bytes and DAV:quota-used-bytes
+// properties. The available space is negative if the server doesn't know
+// it.
+func (r *PropFindResult) Quota() (*Quota, error) {
+	var used internal.QuotaUsedBytes
+	if err := r.Decode(PropertyQuotaUsedBytes, &used); err != nil {
+		return nil, err
+	}
+
+	quota := &Quota{Used: used.Bytes, Available: -1}
+	var available internal.QuotaAvailableBytes
+	if err := r.Decode(PropertyQuotaAvailableBytes, &available); err == nil {
+		quota.Available = available.Bytes
+	} else if !internal.IsNotFound(err) {
+		return nil, err
+	}
+	return quota, nil
+}
+
+func rawToPropertyStatus(raw *internal.RawXMLValue, code int) (*PropertyStatus, error) {
+	name, ok := raw.XMLName()
+	if !ok {
+		return nil, fmt.Errorf("webdav: malformed property in multi-status response")
+	}
+	inner, err := raw.InnerXML()
+	if err != nil {
+		return nil, err
+	}
+	return &PropertyStatus{
+		Property:   Property{XMLName: name, InnerXML: inner},
+		StatusCode: code,
+	}, nil
+}
+
+func propFindResultFromResponse(resp *internal.Response) (*PropFindResult, error) {
+	path, err := resp.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PropFindResult{Path: path}
+	for _, propstat := range resp.PropStats {
+		for i := range propstat.Prop.Raw {
+			ps, err := rawToPropertyStatus(&propstat.Prop.Raw[i], propstat.Status.Code)
+			if err != nil {
+				return nil, err
+			}
+			result.Props = append(result.Props, *ps)
+		}
+	}
+	return result, nil
+}
+
+// PropFind fetches properties of a resource, and of its members depending on
+// depth. If no property name is specified, all properties are requested,
+// except the ones the server deems too expensive to compute.
+func (c *Client) PropFind(ctx context.Context, name string, depth Depth, props ...xml.Name) ([]PropFindResult, error) {
+	propfind := internal.NewPropNamePropFind(props...)
+	if len(props) == 0 {
+		propfind = &internal.PropFind{AllProp: &struct{}{}}
+	}
+
+	req, err := c.ic.NewPropFindRequest(name, internal.Depth(depth), propfind)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		l    []PropFindResult
+		errs []error
+	)
+	_, err = c.ic.DoMultiStatusFunc(req.WithContext(ctx), func(resp *internal.Response) error {
+		result, err := propFindResultFromResponse(resp)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		l = append(l, *result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, errors.Join(errs...)
+}
+
+// PropPatch sets and removes properties of a resource. The update is atomic:
+// either all properties are updated, or none is.
+//
+// The status of each property is returned. If the update has failed, the
+// returned error joins the errors of the properties which have caused the
+// failure.
+func (c *Client) PropPatch(ctx context.Context, name string, set []Property, remove []xml.Name) ([]PropertyStatus, error) {
+	// Properties are removed before new values are set
+	var update internal.PropertyUpdate
+	if len(remove) > 0 {
+		prop := internal.Prop{Raw: internal.NewPropNamePropFind(remove...).Prop.Raw}
+		update.Instructions = append(update.Instructions, internal.PropertyUpdateInstruction{XMLName: internal.RemoveName, Prop: prop})
+	}
+	if len(set) > 0 {
+		var prop internal.Prop
+		for i := range set {
+			raw, err := internal.EncodeRawXMLElement(&set[i])
+			if err != nil {
+				return nil, err
+			}
+			prop.Raw = append(prop.Raw, *raw)
+		}
+		update.Instructions = append(update.Instructions, internal.PropertyUpdateInstruction{XMLName: internal.SetName, Prop: prop})
+	}
+
+	req, err := c.ic.NewXMLRequest("PROPPATCH", name, &update)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(ms.Responses) != 1 {
+		return nil, fmt.Errorf("webdav: PROPPATCH returned %v responses", len(ms.Responses))
+	}
+	resp := &ms.Responses[0]
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		l    []PropertyStatus
+		errs []error
+	)
+	for _, propstat := range resp.PropStats {
+		for i := range propstat.Prop.Raw {
+			ps, err := rawToPropertyStatus(&propstat.Prop.Raw[i], propstat.Status.Code)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, *ps)
+			// 424 Failed Dependency is a consequence of another failure
+			if ps.StatusCode != http.StatusFailedDependency {
+				if err := ps.Err(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return l, errors.Join(errs...)
+}
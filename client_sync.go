@@ -0,0 +1,112 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// SyncOptions holds options for Client.SyncCollection.
+type SyncOptions struct {
+	// Recursive requests the changes of all members of the collection,
+	// instead of its internal members only.
+	Recursive bool
+	// Limit is the maximum number of changes the server may return. Zero
+	// means no limit. Servers which can't satisfy the limit reply with an
+	// error.
+	Limit int
+}
+
+// SyncResult holds the changes returned by Client.SyncCollection. For
+// deleted members, only the Path is populated.
+type SyncResult struct {
+	Created   []FileInfo
+	Updated   []FileInfo
+	Deleted   []FileInfo
+	SyncToken string
+}
+
+// SyncCollection fetches the changes made to the members of a collection
+// since the state identified by syncToken, with the sync-collection report
+// defined in RFC 6578. An empty token requests the initial state, in which
+// case all members are reported as created. Otherwise, the protocol doesn't
+// distinguish new members from modified ones: both are reported as updated.
+//
+// The returned SyncToken can be used for the next synchronization. If the
+// server doesn't accept syncToken anymore, an error wrapping
+// ErrInvalidSyncToken is returned, and the client needs to start over with
+// an empty token. If only some changes couldn't be decoded, the other ones
+// are returned along with an error.
+func (c *Client) SyncCollection(ctx context.Context, name, syncToken string, opts *SyncOptions) (*SyncResult, error) {
+	var result SyncResult
+	newToken, err := c.SyncCollectionFunc(ctx, name, syncToken, opts, func(change *Change) error {
+		switch {
+		case change.FileInfo == nil:
+			result.Deleted = append(result.Deleted, FileInfo{Path: change.Path})
+		case syncToken == "":
+			result.Created = append(result.Created, *change.FileInfo)
+		default:
+			result.Updated = append(result.Updated, *change.FileInfo)
+		}
+		return nil
+	})
+	if newToken == "" && err != nil {
+		return nil, err
+	}
+	result.SyncToken = newToken
+	return &result, err
+}
+
+// SyncCollectionFunc is like SyncCollection, but calls fn for each change as
+// soon as it's received, instead of holding the whole result in memory. The
+// FileInfo of deleted members is nil. The new sync token is returned.
+//
+// If fn returns an error, SyncCollectionFunc stops and returns that error.
+func (c *Client) SyncCollectionFunc(ctx context.Context, name, syncToken string, opts *SyncOptions, fn func(change *Change) error) (newSyncToken string, err error) {
+	if opts == nil {
+		opts = new(SyncOptions)
+	}
+
+	level := internal.DepthOne
+	if opts.Recursive {
+		level = internal.DepthInfinity
+	}
+	var limit *internal.Limit
+	if opts.Limit > 0 {
+		limit = &internal.Limit{NResults: uint(opts.Limit)}
+	}
+
+	var errs []error
+	ms, err := c.ic.SyncCollectionFunc(ctx, name, syncToken, level, limit, fileInfoPropFind.Prop, func(resp *internal.Response) error {
+		p, err := resp.Path()
+		if err != nil {
+			if internal.IsNotFound(err) {
+				return fn(&Change{Path: p})
+			}
+			errs = append(errs, err)
+			return nil
+		}
+
+		// Some servers include the collection itself
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(name, "/") {
+			return nil
+		}
+
+		fi, err := fileInfoFromResponse(resp)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webdav: failed to decode change of %q: %w", p, err))
+			return nil
+		}
+		return fn(&Change{Path: p, FileInfo: fi})
+	})
+	if internal.IsSyncTokenError(err) {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSyncToken, err)
+	} else if err != nil {
+		return "", err
+	}
+
+	return ms.SyncToken, errors.Join(errs...)
+}
@@ -0,0 +1,391 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func TestClient_Upload(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handler{FileSystem: LocalFileSystem(dir)}
+
+	// Interrupt the second chunk halfway through
+	chunks := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.ContentLength > 0 {
+			chunks++
+			if chunks == 2 {
+				r.Body = io.NopCloser(io.MultiReader(io.LimitReader(r.Body, r.ContentLength/2), errReader{}))
+			}
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	err = c.Upload(context.Background(), "/file.bin", bytes.NewReader(data), int64(len(data)), &UploadOptions{ChunkSize: 300})
+	if err != nil {
+		t.Fatalf("Upload() = %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "file.bin")); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("ReadFile() = %v bytes, %v", len(got), err)
+	}
+	if chunks != 4 {
+		t.Errorf("Upload() sent %v chunks, want 4", chunks)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ReadDir() = %v, want a single file", entries)
+	}
+
+	err = c.Upload(context.Background(), "/empty.bin", bytes.NewReader(nil), 0, nil)
+	if err != nil {
+		t.Fatalf("Upload() empty file = %v", err)
+	}
+}
+
+func TestPut_contentRange(t *testing.T) {
+	mem := NewMemFileSystem()
+	h := &Handler{FileSystem: mem}
+
+	res := doTestRequest(h, http.MethodPut, "/file.txt", "hello", map[string]string{"Content-Range": "bytes 0-4/11"})
+	if res.StatusCode != http.StatusAccepted || res.Header.Get("Range") != "bytes=0-4" {
+		t.Fatalf("PUT first chunk: status = %v, Range = %q", res.StatusCode, res.Header.Get("Range"))
+	}
+	if _, err := mem.Stat(context.Background(), "/file.txt"); err == nil {
+		t.Errorf("Stat() = nil, want the file to be created only once complete")
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/file.txt", " world", map[string]string{"Content-Range": "bytes 4-9/11"})
+	if res.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("PUT with wrong offset: status = %v, want %v", res.StatusCode, http.StatusRequestedRangeNotSatisfiable)
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/file.txt", " world", map[string]string{"Content-Range": "bytes 5-10/11"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT last chunk: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+
+	res = doTestRequest(h, http.MethodGet, "/file.txt", "", nil)
+	if body := readTestBody(t, res); body != "hello world" {
+		t.Errorf("GET: body = %q", body)
+	}
+
+	h = &Handler{FileSystem: struct{ FileSystem }{mem}}
+	res = doTestRequest(h, http.MethodPut, "/file.txt", "hello", map[string]string{"Content-Range": "bytes 0-4/11"})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT without UploadFileSystem: status = %v, want %v", res.StatusCode, http.StatusBadRequest)
+	}
+	if body := readTestBody(t, doTestRequest(h, http.MethodGet, "/file.txt", "", nil)); !strings.HasPrefix(body, "hello world") {
+		t.Errorf("GET: body = %q", body)
+	}
+}
+
+func TestClient_CreateWithOptions(t *testing.T) {
+	var contentType string
+	var contentLength int64
+	h := &Handler{FileSystem: NewMemFileSystem()}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			contentType, contentLength = r.Header.Get("Content-Type"), r.ContentLength
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	ctx := context.Background()
+
+	create := func(data string, opts *CreateOptions) (*FileWriter, error) {
+		fw, err := c.CreateWithOptions(ctx, "/file.txt", opts)
+		if err != nil {
+			return nil, err
+		}
+		io.WriteString(fw, data)
+		return fw, fw.Close()
+	}
+
+	fw, err := create("hello", &CreateOptions{
+		IfNoneMatch:   "*",
+		ContentType:   "text/plain",
+		ContentLength: 5,
+	})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() = %v", err)
+	}
+	if contentType != "text/plain" || contentLength != 5 {
+		t.Errorf("server got Content-Type = %q, Content-Length = %v", contentType, contentLength)
+	}
+	fi := fw.FileInfo()
+	if !fw.Created() || fi.ETag == "" || fi.Size != 5 || fi.Path != "/file.txt" {
+		t.Errorf("CreateWithOptions(): created = %v, FileInfo() = %+v", fw.Created(), fi)
+	}
+
+	if _, err := create("again", &CreateOptions{IfNoneMatch: "*"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("CreateWithOptions() with If-None-Match on existing file = %v, want ErrPreconditionFailed", err)
+	}
+
+	etag := ConditionalMatch(internal.ETag(fi.ETag).String())
+	fw, err = create("world", &CreateOptions{IfMatch: etag})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() with matching If-Match = %v", err)
+	}
+	if fw.Created() || fw.FileInfo().ETag == fi.ETag {
+		t.Errorf("CreateWithOptions() with matching If-Match: created = %v, ETag unchanged", fw.Created())
+	}
+	if size := fw.FileInfo().Size; size != 5 {
+		t.Errorf("CreateWithOptions() without ContentLength: FileInfo().Size = %v, want 5", size)
+	}
+
+	// The previous ETag is now stale
+	_, err = create("lost update", &CreateOptions{IfMatch: etag})
+	var httpErr *internal.HTTPError
+	if !errors.Is(err, ErrPreconditionFailed) || !errors.As(err, &httpErr) || httpErr.Code != http.StatusPreconditionFailed {
+		t.Errorf("CreateWithOptions() with stale If-Match = %v, want ErrPreconditionFailed", err)
+	}
+	if err := c.RemoveAll(ctx, "/file.txt"); err != nil {
+		t.Errorf("RemoveAll() = %v", err)
+	}
+}
+
+func TestClient_CreateWithOptions_noETag(t *testing.T) {
+	var propfinds int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case "PROPFIND":
+			propfinds++
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	fw, err := c.CreateWithOptions(context.Background(), "/file.txt", nil)
+	if err != nil {
+		t.Fatalf("CreateWithOptions() = %v", err)
+	}
+	io.WriteString(fw, "hello")
+	if err := fw.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if propfinds != 0 {
+		t.Errorf("Close() sent %v PROPFIND requests, want 0", propfinds)
+	}
+	if fi := fw.FileInfo(); fi.Path != "/file.txt" || fi.Size != 5 || fi.ETag != "" {
+		t.Errorf("FileInfo() = %+v", fi)
+	}
+	if propfinds != 1 {
+		t.Errorf("FileInfo() sent %v PROPFIND requests, want 1", propfinds)
+	}
+}
+
+func TestClient_PropFind(t *testing.T) {
+	fs := NewMemFileSystem()
+	fs.MaxSize = 1000
+	ts := httptest.NewServer(&Handler{FileSystem: fs})
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	ctx := context.Background()
+
+	if err := c.Mkdir(ctx, "/dir"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+
+	colorName := xml.Name{Space: "urn:example", Local: "color"}
+	type color struct {
+		XMLName xml.Name `xml:"urn:example color"`
+		Value   string   `xml:",chardata"`
+	}
+	colorProp, err := NewProperty(&color{Value: "blue"})
+	if err != nil {
+		t.Fatalf("NewProperty() = %v", err)
+	}
+	statuses, err := c.PropPatch(ctx, "/dir", []Property{*NewDisplayNameProperty("My <dir>"), *colorProp}, nil)
+	if err != nil || len(statuses) != 2 || statuses[0].StatusCode != http.StatusOK {
+		t.Fatalf("PropPatch() = %+v, %v", statuses, err)
+	}
+
+	results, err := c.PropFind(ctx, "/dir", DepthZero, PropertyDisplayName, PropertyResourceType, PropertyQuotaUsedBytes, PropertyQuotaAvailableBytes, colorName, PropertyGetETag)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("PropFind() = %+v, %v", results, err)
+	}
+	result := &results[0]
+	if name, err := result.DisplayName(); err != nil || name != "My <dir>" {
+		t.Errorf("DisplayName() = %q, %v", name, err)
+	}
+	if types, err := result.ResourceType(); err != nil || len(types) != 1 || types[0] != internal.CollectionName {
+		t.Errorf("ResourceType() = %v, %v", types, err)
+	}
+	if quota, err := result.Quota(); err != nil || quota.Available != 1000 || quota.Used != 0 {
+		t.Errorf("Quota() = %+v, %v", quota, err)
+	}
+	var v color
+	if err := result.Decode(colorName, &v); err != nil || v.Value != "blue" {
+		t.Errorf("Decode() = %v, value = %q", err, v.Value)
+	}
+	if _, err := result.Get(PropertyGetETag); !internal.IsNotFound(err) {
+		t.Errorf("Get(getetag) = %v, want 404", err)
+	}
+
+	statuses, err = c.PropPatch(ctx, "/dir", []Property{*NewDisplayNameProperty("Other")}, []xml.Name{PropertyGetETag})
+	if err == nil || len(statuses) != 2 {
+		t.Fatalf("PropPatch() with protected property = %+v, %v", statuses, err)
+	}
+	for _, ps := range statuses {
+		want := http.StatusFailedDependency
+		if ps.XMLName == PropertyGetETag {
+			want = http.StatusForbidden
+		}
+		if ps.StatusCode != want {
+			t.Errorf("PropPatch() with protected property: %v status = %v, want %v", ps.XMLName.Local, ps.StatusCode, want)
+		}
+	}
+
+	results, err = c.PropFind(ctx, "/", DepthOne)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("PropFind() with allprop = %+v, %v", results, err)
+	}
+	if name, err := results[1].DisplayName(); err != nil || name != "My <dir>" {
+		t.Errorf("DisplayName() after failed PropPatch = %q, %v", name, err)
+	}
+}
+
+func TestClient_SyncCollection(t *testing.T) {
+	fs := NewMemFileSystem()
+	ts := httptest.NewServer(&Handler{FileSystem: fs})
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	ctx := context.Background()
+
+	create := func(name, data string) {
+		t.Helper()
+		if _, _, err := fs.Create(ctx, name, io.NopCloser(strings.NewReader(data)), &CreateOptions{}); err != nil {
+			t.Fatalf("Create(%q) = %v", name, err)
+		}
+	}
+	paths := func(l []FileInfo) []string {
+		var paths []string
+		for _, fi := range l {
+			paths = append(paths, fi.Path)
+		}
+		return paths
+	}
+
+	if err := fs.Mkdir(ctx, "/dir"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	if err := fs.Mkdir(ctx, "/dir/sub"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	create("/dir/a.txt", "a")
+	create("/dir/b.txt", "b")
+	create("/dir/sub/c.txt", "c")
+
+	result, err := c.SyncCollection(ctx, "/dir", "", nil)
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+	if got := strings.Join(paths(result.Created), " "); got != "/dir/a.txt /dir/b.txt /dir/sub" {
+		t.Errorf("SyncCollection(): created = %v", got)
+	}
+	if len(result.Updated) != 0 || len(result.Deleted) != 0 || result.SyncToken == "" {
+		t.Errorf("SyncCollection() = %+v", result)
+	}
+	if fi := result.Created[0]; fi.Size != 1 || fi.ETag == "" || fi.IsDir {
+		t.Errorf("SyncCollection(): created file = %+v", fi)
+	}
+	token := result.SyncToken
+
+	create("/dir/a.txt", "aa")
+	create("/dir/d.txt", "d")
+	create("/dir/sub/c.txt", "cc")
+	if err := fs.RemoveAll(ctx, "/dir/b.txt", &RemoveAllOptions{}); err != nil {
+		t.Fatalf("RemoveAll() = %v", err)
+	}
+	create("/other.txt", "other")
+
+	result, err = c.SyncCollection(ctx, "/dir", token, nil)
+	if err != nil {
+		t.Fatalf("SyncCollection() with token = %v", err)
+	}
+	if got := strings.Join(paths(result.Updated), " "); got != "/dir/a.txt /dir/d.txt" {
+		t.Errorf("SyncCollection() with token: updated = %v", got)
+	}
+	if got := strings.Join(paths(result.Deleted), " "); got != "/dir/b.txt" {
+		t.Errorf("SyncCollection() with token: deleted = %v", got)
+	}
+	if len(result.Created) != 0 || result.SyncToken == token {
+		t.Errorf("SyncCollection() with token = %+v", result)
+	}
+	if result.Updated[0].Size != 2 {
+		t.Errorf("SyncCollection() with token: updated file = %+v", result.Updated[0])
+	}
+
+	result, err = c.SyncCollection(ctx, "/dir", token, &SyncOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("SyncCollection() recursive = %v", err)
+	}
+	if got := strings.Join(paths(result.Updated), " "); got != "/dir/a.txt /dir/d.txt /dir/sub/c.txt" {
+		t.Errorf("SyncCollection() recursive: updated = %v", got)
+	}
+
+	result, err = c.SyncCollection(ctx, "/dir", result.SyncToken, nil)
+	if err != nil || len(result.Updated) != 0 || len(result.Deleted) != 0 {
+		t.Errorf("SyncCollection() without changes = %+v, %v", result, err)
+	}
+
+	if _, err := c.SyncCollection(ctx, "/dir", "urn:example:invalid", nil); !errors.Is(err, ErrInvalidSyncToken) {
+		t.Errorf("SyncCollection() with invalid token = %v, want %v", err, ErrInvalidSyncToken)
+	}
+	if _, err := c.SyncCollection(ctx, "/dir", "", &SyncOptions{Limit: 1}); !isHTTPStatus(err, http.StatusInsufficientStorage) {
+		t.Errorf("SyncCollection() over limit = %v, want 507", err)
+	}
+
+	results, err := c.PropFind(ctx, "/dir", DepthZero, internal.SyncTokenName)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("PropFind(sync-token) = %+v, %v", results, err)
+	}
+	var syncToken internal.SyncToken
+	if err := results[0].Decode(internal.SyncTokenName, &syncToken); err != nil || syncToken.Token == "" {
+		t.Errorf("PropFind(sync-token) = %q, %v", syncToken.Token, err)
+	}
+}
@@ -54,16 +54,6 @@ func logResult(provider, operation string, success bool, err error, duration tim
 	}
 }
 
-type oauthClient struct {
-	base  *http.Client
-	token string
-}
-
-func (c *oauthClient) Do(req *http.Request) (*http.Response, error) {
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	return c.base.Do(req)
-}
-
 func getHTTPClient() *http.Client {
 	return &http.Client{
 		Timeout: 30 * time.Second,
@@ -329,10 +319,7 @@ func main() {
 		token := os.Getenv("GOOGLE_ACCESS_TOKEN")
 		email := os.Getenv("GOOGLE_EMAIL")
 		if token != "" && email != "" {
-			httpClient := &oauthClient{
-				base:  getHTTPClient(),
-				token: token,
-			}
+			httpClient := webdav.HTTPClientWithBearerToken(getHTTPClient(), token)
 			providers = append(providers, ProviderConfig{
 				Name:          "Google",
 				BaseURL:       "https://apidata.googleusercontent.com",
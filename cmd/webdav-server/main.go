@@ -8,11 +8,16 @@ import (
 	"os"
 
 	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/auth"
 )
 
 func main() {
 	var addr string
+	var sync bool
+	var htpasswd string
 	flag.StringVar(&addr, "addr", ":8080", "listening address")
+	flag.BoolVar(&sync, "sync", false, "flush uploaded files to stable storage")
+	flag.StringVar(&htpasswd, "htpasswd", "", "require Basic authentication with users from an htpasswd file")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [options...] [directory]\n", os.Args[0])
 		flag.PrintDefaults()
@@ -24,9 +29,26 @@ func main() {
 		path = "."
 	}
 
-	handler := webdav.Handler{
-		FileSystem: webdav.LocalFileSystem(path),
+	var fs webdav.FileSystem = webdav.LocalFileSystem(path)
+	if sync {
+		fs = webdav.SyncedLocalFileSystem{webdav.LocalFileSystem(path)}
 	}
+
+	var handler http.Handler = &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLockSystem(),
+	}
+	if htpasswd != "" {
+		passwords, err := auth.OpenHtpasswd(htpasswd)
+		if err != nil {
+			log.Fatalf("failed to load htpasswd file: %v", err)
+		}
+		mw := auth.Middleware{
+			Authenticators: []auth.Authenticator{&auth.Basic{Realm: "WebDAV", Passwords: passwords}},
+		}
+		handler = mw.Handler(handler)
+	}
+
 	log.Printf("WebDAV server listening on %v", addr)
-	log.Fatal(http.ListenAndServe(addr, &handler))
+	log.Fatal(http.ListenAndServe(addr, handler))
 }
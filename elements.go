@@ -29,3 +29,18 @@ type groupMembership struct {
 	XMLName xml.Name        `xml:"DAV: group-membership"`
 	Hrefs   []internal.Href `xml:"href"`
 }
+
+type reportReq struct {
+	SyncCollection *internal.SyncCollectionQuery
+}
+
+func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name {
+	case internal.SyncCollectionName:
+		r.SyncCollection = &internal.SyncCollectionQuery{}
+		return d.DecodeElement(r.SyncCollection, &start)
+	default:
+		// Unsupported reports are rejected by the handler
+		return d.Skip()
+	}
+}
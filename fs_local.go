@@ -2,6 +2,8 @@ package webdav
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +14,8 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/emersion/go-webdav/internal"
 )
@@ -19,7 +23,11 @@ import (
 // LocalFileSystem implements FileSystem for a local directory.
 type LocalFileSystem string
 
-var _ FileSystem = LocalFileSystem("")
+var (
+	_ FileSystem      = LocalFileSystem("")
+	_ DirWalker       = LocalFileSystem("")
+	_ QuotaFileSystem = LocalFileSystem("")
+)
 
 func (fs LocalFileSystem) localPath(name string) (string, error) {
 	if (filepath.Separator != '/' && strings.IndexRune(name, filepath.Separator) >= 0) || strings.Contains(name, "\x00") {
@@ -29,6 +37,11 @@ func (fs LocalFileSystem) localPath(name string) (string, error) {
 	if !path.IsAbs(name) {
 		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: expected absolute path, got %q", name)
 	}
+	for _, elem := range strings.Split(name, "/") {
+		if isLocalReservedName(elem) {
+			return "", internal.HTTPErrorf(http.StatusForbidden, "webdav: reserved file name %q", elem)
+		}
+	}
 	return filepath.Join(string(fs), filepath.FromSlash(name)), nil
 }
 
@@ -78,6 +91,8 @@ func errFromOS(err error) error {
 		return NewHTTPError(http.StatusForbidden, err)
 	} else if errors.Is(err, os.ErrDeadlineExceeded) {
 		return NewHTTPError(http.StatusServiceUnavailable, err)
+	} else if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return NewHTTPError(http.StatusInsufficientStorage, err)
 	} else {
 		return err
 	}
@@ -96,33 +111,133 @@ func (fs LocalFileSystem) Stat(ctx context.Context, name string) (*FileInfo, err
 }
 
 func (fs LocalFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
-	path, err := fs.localPath(name)
+	var l []FileInfo
+	err := fs.WalkDir(ctx, name, recursive, func(fi *FileInfo) error {
+		l = append(l, *fi)
+		return nil
+	})
+	return l, err
+}
+
+func (fs LocalFileSystem) WalkDir(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	p, err := fs.localPath(name)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return errFromOS(err)
+	}
+	href, err := fs.externalPath(p)
+	if err != nil {
+		return err
 	}
 
-	var l []FileInfo
-	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
-		if err != nil && !errors.Is(err, os.ErrPermission) {
-			return err
+	// Errors about the directory itself are fatal, errors about its
+	// children are reported per resource
+	var errs []error
+	if err := fs.walkDir(p, href, fi, true, recursive, fn, &errs); err != nil {
+		return err
+	}
+	if len(errs) == 1 {
+		if hrefErr := errs[0].(*internal.HrefError); hrefErr.Href.Path == href {
+			return hrefErr.Err
 		}
-		if fi == nil {
+	}
+	return errors.Join(errs...)
+}
+
+func (fs LocalFileSystem) walkDir(p, href string, fi os.FileInfo, root, recursive bool, fn func(fi *FileInfo) error, errs *[]error) error {
+	var entries []os.DirEntry
+	if fi.IsDir() && (root || recursive) {
+		var err error
+		entries, err = os.ReadDir(p)
+		if err != nil {
+			*errs = append(*errs, NewResourceError(href, errFromOS(err)))
 			return nil
 		}
+	}
 
-		href, err := fs.externalPath(p)
+	if err := fn(fileInfoFromOS(href, fi)); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		childPath := filepath.Join(p, entry.Name())
+		if isLocalReservedName(entry.Name()) {
+			removeStaleLocalFile(childPath, entry)
+			continue
+		}
+
+		childHref := path.Join(href, entry.Name())
+		childInfo, err := entry.Info()
 		if err != nil {
+			*errs = append(*errs, NewResourceError(childHref, errFromOS(err)))
+			continue
+		}
+		if err := fs.walkDir(childPath, childHref, childInfo, false, recursive, fn, errs); err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
 
-		l = append(l, *fileInfoFromOS(href, fi))
+// Quota returns the space available on the filesystem containing the
+// directory, and the space used by the files it contains. Quotas enforced by
+// the operating system aren't taken into account.
+func (fs LocalFileSystem) Quota(ctx context.Context, name string) (*Quota, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return nil, err
+	}
+	available, err := fs.AvailableBytes(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	used, err := localDirSize(p)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+	return &Quota{Available: available, Used: used}, nil
+}
+
+// AvailableBytes returns the space available on the filesystem containing the
+// directory.
+func (fs LocalFileSystem) AvailableBytes(ctx context.Context, name string) (int64, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return 0, err
+	}
+	available, err := statfsAvailable(p)
+	if err != nil {
+		return 0, errFromOS(err)
+	}
+	return available, nil
+}
 
-		if !recursive && fi.IsDir() && path != p {
-			return filepath.SkipDir
+// localDirSize returns the total size of the regular files contained in a
+// directory and its subdirectories.
+func localDirSize(p string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(p, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || removeStaleLocalFile(p, d) {
+			return nil
+		}
+		fi, err := d.Info()
+		if os.IsNotExist(err) {
+			// Removed concurrently
+			return nil
+		} else if err != nil {
+			return err
 		}
+		size += fi.Size()
 		return nil
 	})
-	return l, errFromOS(err)
+	return size, err
 }
 
 func checkConditionalMatches(fi *FileInfo, ifMatch, ifNoneMatch ConditionalMatch) error {
@@ -151,38 +266,163 @@ func checkConditionalMatches(fi *FileInfo, ifMatch, ifNoneMatch ConditionalMatch
 }
 
 func (fs LocalFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	return fs.create(ctx, name, body, opts, false)
+}
+
+// create writes the file to a temporary location first, then atomically
+// renames it into place. Readers never observe a partially written file, and
+// the previous version is kept if the upload fails.
+func (fs LocalFileSystem) create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions, sync bool) (fi *FileInfo, created bool, err error) {
 	p, err := fs.localPath(name)
 	if err != nil {
 		return nil, false, err
 	}
-	fi, _ = fs.Stat(ctx, name)
-	created = fi == nil
 
-	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
-		return nil, false, err
+	// Fail early instead of uploading the body for nothing
+	if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+		return nil, false, NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("webdav: %q is a directory", name))
 	}
 
-	wc, err := os.Create(p)
-	if err != nil {
+	f, err := createLocalTemp(filepath.Dir(p))
+	if os.IsNotExist(err) {
+		return nil, false, NewHTTPError(http.StatusConflict, err)
+	} else if err != nil {
 		return nil, false, errFromOS(err)
 	}
-	defer wc.Close()
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	defer f.Close()
 
-	if _, err := io.Copy(wc, body); err != nil {
-		os.Remove(p)
-		return nil, false, err
+	if _, err := io.Copy(f, body); err != nil {
+		return nil, false, errFromOS(err)
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			return nil, false, errFromOS(err)
+		}
 	}
-	if err := wc.Close(); err != nil {
-		os.Remove(p)
+	if err := f.Close(); err != nil {
+		return nil, false, errFromOS(err)
+	}
+
+	return fs.replaceFile(ctx, name, p, tmp, opts, sync)
+}
+
+// replaceFile atomically renames tmp to p, the local path of name. The
+// permissions and properties of the file being replaced, if any, are kept.
+func (fs LocalFileSystem) replaceFile(ctx context.Context, name, p, tmp string, opts *CreateOptions, sync bool) (fi *FileInfo, created bool, err error) {
+	// The conditional checks need to be atomic with the rename, otherwise
+	// two concurrent conditional requests could both succeed
+	mu := fs.mutex()
+	mu.Lock()
+	defer mu.Unlock()
+
+	osInfo, err := os.Stat(p)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, errFromOS(err)
+	}
+	created = osInfo == nil
+	if !created {
+		fi = fileInfoFromOS(name, osInfo)
+	}
+	if err := checkConditionalMatches(fi, opts.IfMatch, opts.IfNoneMatch); err != nil {
 		return nil, false, err
 	}
 
+	if created {
+		// Drop properties left behind by a file removed behind our back
+		if err := removeLocalFileSidecar(p); err != nil {
+			return nil, false, err
+		}
+	} else if osInfo.IsDir() {
+		return nil, false, NewHTTPError(http.StatusMethodNotAllowed, fmt.Errorf("webdav: %q is a directory", name))
+	} else {
+		// The temporary file replaces the old inode, carry over what's
+		// attached to it. Changing the owner clears the setuid and setgid
+		// bits, so the mode is set last.
+		if err := chownLocalFile(tmp, osInfo); err != nil {
+			return nil, false, errFromOS(err)
+		}
+		if err := os.Chmod(tmp, osInfo.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return nil, false, errFromOS(err)
+		}
+		if err := copyLocalFileXattr(p, tmp); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := os.Rename(tmp, p); err != nil {
+		return nil, false, errFromOS(err)
+	}
+	if sync {
+		syncDir(filepath.Dir(p))
+	}
+
 	fi, err = fs.Stat(ctx, name)
 	if err != nil {
 		return nil, false, err
 	}
+	return fi, created, nil
+}
+
+// localTempMaxAge is how long a temporary file or a pending upload can go
+// unmodified before it's considered abandoned.
+const localTempMaxAge = 24 * time.Hour
 
-	return fi, created, err
+// removeStaleLocalFile removes the file at p if it's a temporary file left
+// behind by an interrupted write, or an abandoned pending upload. It reports
+// whether the file has been removed. Errors are ignored, removal is attempted
+// again the next time the directory is listed.
+func removeStaleLocalFile(p string, entry fs.DirEntry) bool {
+	name := entry.Name()
+	if !strings.HasPrefix(name, localTempPrefix) && !strings.HasPrefix(name, localUploadPrefix) {
+		return false
+	}
+	fi, err := entry.Info()
+	if err != nil || !fi.Mode().IsRegular() || time.Since(fi.ModTime()) <= localTempMaxAge {
+		return false
+	}
+	return os.Remove(p) == nil
+}
+
+// createLocalTemp creates a new temporary file in dir. Unlike os.CreateTemp,
+// the file permissions are subject to the umask, like files created with
+// os.Create.
+func createLocalTemp(dir string) (*os.File, error) {
+	for {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		p := filepath.Join(dir, localTempPrefix+hex.EncodeToString(b[:]))
+		f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
+
+// syncDir flushes a directory entry to stable storage. Errors are ignored,
+// since not all platforms support syncing directories.
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	f.Sync()
+	f.Close()
+}
+
+// SyncedLocalFileSystem is a LocalFileSystem which flushes uploaded files to
+// stable storage before replying, so that successful uploads survive a crash.
+type SyncedLocalFileSystem struct {
+	LocalFileSystem
+}
+
+var _ FileSystem = SyncedLocalFileSystem{}
+
+func (fs SyncedLocalFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	return fs.LocalFileSystem.create(ctx, name, body, opts, true)
 }
 
 func (fs LocalFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
@@ -202,7 +442,52 @@ func (fs LocalFileSystem) RemoveAll(ctx context.Context, name string, opts *Remo
 		return err
 	}
 
-	return errFromOS(os.RemoveAll(p))
+	return fs.removeAll(p, path.Clean(name))
+}
+
+// removeAll removes a file and its children. Files which can't be removed are
+// reported with per-resource errors. Their ancestors are left in place, but
+// aren't reported as failures.
+func (fs LocalFileSystem) removeAll(p, href string) error {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return NewResourceError(href, errFromOS(err))
+	}
+
+	if fi.IsDir() {
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return NewResourceError(href, errFromOS(err))
+		}
+
+		var errs []error
+		for _, entry := range entries {
+			childPath := filepath.Join(p, entry.Name())
+			if isLocalReservedName(entry.Name()) {
+				if err := os.Remove(childPath); err != nil {
+					errs = append(errs, NewResourceError(href, errFromOS(err)))
+				}
+				continue
+			}
+			if err := fs.removeAll(childPath, path.Join(href, entry.Name())); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
+
+	if err := os.Remove(p); err != nil {
+		return NewResourceError(href, errFromOS(err))
+	}
+	if href == "/" {
+		return nil
+	}
+	if err := removeLocalUpload(p); err != nil {
+		return err
+	}
+	return removeLocalFileSidecar(p)
 }
 
 func (fs LocalFileSystem) Mkdir(ctx context.Context, name string) error {
@@ -233,12 +518,48 @@ func copyRegularFile(src, dst string, perm os.FileMode) error {
 	defer dstFile.Close()
 
 	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return err
+		return errFromOS(err)
 	}
 
 	return dstFile.Close()
 }
 
+// checkNotNested returns an error if src and dst are the same resource, or if
+// one contains the other. Copying or moving a collection into itself would
+// recurse forever, and overwriting an ancestor would destroy the source.
+func checkNotNested(src, dst string) error {
+	src, dst = path.Clean(src), path.Clean(dst)
+	if src == dst || strings.HasPrefix(dst, strings.TrimSuffix(src, "/")+"/") || strings.HasPrefix(src, strings.TrimSuffix(dst, "/")+"/") {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot copy or move %q to %q", src, dst)
+	}
+	return nil
+}
+
+// prepareLocalDest checks whether dstPath can be the destination of a COPY or MOVE,
+// and removes any existing resource there.
+func prepareLocalDest(dstPath string, noOverwrite bool) (created bool, err error) {
+	if _, err := os.Stat(dstPath); err != nil {
+		if !os.IsNotExist(err) {
+			return false, errFromOS(err)
+		}
+		created = true
+	} else {
+		if noOverwrite {
+			return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
+		}
+		if err := os.RemoveAll(dstPath); err != nil {
+			return false, errFromOS(err)
+		}
+		if err := removeLocalUpload(dstPath); err != nil {
+			return false, err
+		}
+	}
+	if err := removeLocalFileSidecar(dstPath); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
 func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
 	srcPath, err := fs.localPath(src)
 	if err != nil {
@@ -248,55 +569,83 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 	if err != nil {
 		return false, err
 	}
-
-	// TODO: "Note that an infinite-depth COPY of /A/ into /A/B/ could lead to
-	// infinite recursion if not handled correctly"
+	if err := checkNotNested(src, dst); err != nil {
+		return false, err
+	}
 
 	srcInfo, err := os.Stat(srcPath)
 	if err != nil {
 		return false, errFromOS(err)
 	}
-	srcPerm := srcInfo.Mode() & os.ModePerm
 
-	if _, err := os.Stat(dstPath); err != nil {
-		if !os.IsNotExist(err) {
-			return false, errFromOS(err)
-		}
-		created = true
-	} else {
-		if options.NoOverwrite {
-			return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
-		}
-		if err := os.RemoveAll(dstPath); err != nil {
-			return false, errFromOS(err)
+	created, err = prepareLocalDest(dstPath, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	// Errors about the destination itself are fatal, errors about its
+	// children are reported per resource
+	var errs []error
+	if err := fs.copyLocalTree(srcPath, dstPath, path.Clean(dst), srcInfo, !options.NoRecursive, &errs); err != nil {
+		return false, err
+	}
+	return created, errors.Join(errs...)
+}
+
+// copyLocalTree copies the file at srcPath to dstPath, along with its
+// properties, permissions and modification time. If recursive is set,
+// directory children are copied as well, and failures to do so are appended
+// to errs.
+func (fs LocalFileSystem) copyLocalTree(srcPath, dstPath, href string, fi os.FileInfo, recursive bool, errs *[]error) error {
+	perm := fi.Mode() & os.ModePerm
+
+	if !fi.IsDir() {
+		if err := copyRegularFile(srcPath, dstPath, perm); err != nil {
+			return err
 		}
+	} else if err := os.Mkdir(dstPath, 0700); os.IsNotExist(err) {
+		return NewHTTPError(http.StatusConflict, err)
+	} else if err != nil {
+		return errFromOS(err)
 	}
 
-	err = filepath.Walk(srcPath, func(p string, fi os.FileInfo, err error) error {
+	if err := fs.copyLocalProps(srcPath, dstPath, fi.IsDir()); err != nil {
+		return err
+	}
+
+	if fi.IsDir() && recursive {
+		entries, err := os.ReadDir(srcPath)
 		if err != nil {
-			return err
+			*errs = append(*errs, NewResourceError(href, errFromOS(err)))
 		}
+		for _, entry := range entries {
+			if isLocalReservedName(entry.Name()) {
+				continue
+			}
 
-		if fi.IsDir() {
-			if err := os.Mkdir(dstPath, srcPerm); err != nil {
-				return errFromOS(err)
+			childHref := path.Join(href, entry.Name())
+			childInfo, err := entry.Info()
+			if err != nil {
+				err = errFromOS(err)
+			} else {
+				err = fs.copyLocalTree(filepath.Join(srcPath, entry.Name()), filepath.Join(dstPath, entry.Name()), childHref, childInfo, true, errs)
 			}
-		} else {
-			if err := copyRegularFile(srcPath, dstPath, srcPerm); err != nil {
-				return err
+			if err != nil {
+				*errs = append(*errs, NewResourceError(childHref, err))
 			}
 		}
-
-		if fi.IsDir() && options.NoRecursive {
-			return filepath.SkipDir
-		}
-		return nil
-	})
-	if err != nil {
-		return false, errFromOS(err)
 	}
 
-	return created, nil
+	// Permissions and times are restored last, since writing children
+	// would otherwise fail for read-only directories and update the
+	// modification time
+	if err := os.Chmod(dstPath, perm); err != nil {
+		return errFromOS(err)
+	}
+	if err := os.Chtimes(dstPath, fi.ModTime(), fi.ModTime()); err != nil {
+		return errFromOS(err)
+	}
+	return nil
 }
 
 func (fs LocalFileSystem) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
@@ -309,23 +658,24 @@ func (fs LocalFileSystem) Move(ctx context.Context, src, dst string, options *Mo
 		return false, err
 	}
 
-	if _, err := os.Stat(dstPath); err != nil {
-		if !os.IsNotExist(err) {
-			return false, errFromOS(err)
-		}
-		created = true
-	} else {
-		if options.NoOverwrite {
-			return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
-		}
-		if err := os.RemoveAll(dstPath); err != nil {
-			return false, errFromOS(err)
-		}
+	if err := checkNotNested(src, dst); err != nil {
+		return false, err
+	}
+
+	created, err = prepareLocalDest(dstPath, options.NoOverwrite)
+	if err != nil {
+		return false, err
 	}
 
 	if err := os.Rename(srcPath, dstPath); err != nil {
 		return false, errFromOS(err)
 	}
+	if err := moveLocalFileSidecar(srcPath, dstPath); err != nil {
+		return false, err
+	}
+	if err := moveLocalUpload(srcPath, dstPath); err != nil {
+		return false, err
+	}
 
 	return created, nil
 }
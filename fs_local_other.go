@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package webdav
+
+import (
+	"os"
+)
+
+func chownLocalFile(p string, fi os.FileInfo) error {
+	return nil
+}
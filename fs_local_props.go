@@ -0,0 +1,236 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LocalFileSystem stores dead properties in an extended attribute. When the
+// underlying filesystem doesn't support extended attributes, properties are
+// stored in sidecar files instead: ".webdav.props" inside a directory for the
+// directory itself, ".webdav.props.<name>" next to a file for the file. Files
+// whose name starts with ".webdav." are reserved and hidden from clients.
+var _ PropertyStore = LocalFileSystem("")
+
+const (
+	localReservedPrefix = ".webdav."
+	localTempPrefix     = localReservedPrefix + "tmp."
+	localPropsXattr     = "user.webdav.properties"
+	localPropsSidecar   = localReservedPrefix + "props"
+)
+
+var (
+	errXattrNotExist    = errors.New("webdav: extended attribute doesn't exist")
+	errXattrUnsupported = errors.New("webdav: extended attributes unsupported")
+)
+
+// localMutexes holds the mutex of each LocalFileSystem, keyed by root
+// directory.
+var localMutexes sync.Map
+
+// mutex returns the mutex serializing read-modify-write cycles on the stored
+// properties of fs, and conditional file replacements.
+func (fs LocalFileSystem) mutex() *sync.Mutex {
+	mu, _ := localMutexes.LoadOrStore(filepath.Clean(string(fs)), new(sync.Mutex))
+	return mu.(*sync.Mutex)
+}
+
+type localProps struct {
+	XMLName xml.Name   `xml:"https://github.com/emersion/go-webdav properties"`
+	Props   []Property `xml:",any"`
+}
+
+func isLocalReservedName(name string) bool {
+	return strings.HasPrefix(name, localReservedPrefix)
+}
+
+func localSidecarPath(p string, isDir bool) string {
+	if isDir {
+		return filepath.Join(p, localPropsSidecar)
+	}
+	dir, base := filepath.Split(p)
+	return filepath.Join(dir, localPropsSidecar+"."+base)
+}
+
+func readLocalProps(p string, isDir bool) ([]Property, error) {
+	data, err := getXattr(p, localPropsXattr)
+	if errors.Is(err, errXattrNotExist) || errors.Is(err, errXattrUnsupported) {
+		data, err = os.ReadFile(localSidecarPath(p, isDir))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+
+	var props localProps
+	if err := xml.Unmarshal(data, &props); err != nil {
+		return nil, err
+	}
+	return props.Props, nil
+}
+
+func writeLocalProps(p string, isDir bool, props []Property) error {
+	sidecar := localSidecarPath(p, isDir)
+	if len(props) == 0 {
+		if err := removeXattr(p, localPropsXattr); err != nil && !errors.Is(err, errXattrNotExist) && !errors.Is(err, errXattrUnsupported) {
+			return errFromOS(err)
+		}
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return errFromOS(err)
+		}
+		return nil
+	}
+
+	data, err := xml.Marshal(&localProps{Props: props})
+	if err != nil {
+		return err
+	}
+
+	if err := setXattr(p, localPropsXattr, data); err == nil {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return errFromOS(err)
+		}
+		return nil
+	} else if !errors.Is(err, errXattrUnsupported) {
+		return errFromOS(err)
+	}
+
+	// Write to a temporary file first, so that a crash can't leave a
+	// truncated sidecar file behind
+	f, err := os.CreateTemp(filepath.Dir(sidecar), localTempPrefix)
+	if err != nil {
+		return errFromOS(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), sidecar); err != nil {
+		return errFromOS(err)
+	}
+
+	// A value written before the filesystem ran out of room for xattrs
+	// would shadow the sidecar file
+	if err := removeXattr(p, localPropsXattr); err != nil && !errors.Is(err, errXattrNotExist) && !errors.Is(err, errXattrUnsupported) {
+		return errFromOS(err)
+	}
+	return nil
+}
+
+// copyLocalProps copies the properties of src to dst, replacing any existing
+// properties of dst.
+func (fs LocalFileSystem) copyLocalProps(src, dst string, isDir bool) error {
+	mu := fs.mutex()
+	mu.Lock()
+	defer mu.Unlock()
+
+	props, err := readLocalProps(src, isDir)
+	if err != nil {
+		return err
+	}
+	return writeLocalProps(dst, isDir, props)
+}
+
+// copyLocalFileXattr copies the properties stored in an extended attribute of
+// the file at src to the file at dst. Properties stored in a sidecar file are
+// left alone, since they are attached to the file name. The caller must hold
+// the mutex of the filesystem.
+func copyLocalFileXattr(src, dst string) error {
+	data, err := getXattr(src, localPropsXattr)
+	if errors.Is(err, errXattrNotExist) || errors.Is(err, errXattrUnsupported) {
+		return nil
+	} else if err != nil {
+		return errFromOS(err)
+	}
+	if err := setXattr(dst, localPropsXattr, data); err != nil {
+		return errFromOS(err)
+	}
+	return nil
+}
+
+// removeLocalFileSidecar removes the sidecar file holding the properties of
+// the file at p, if any. Extended attributes and directory sidecar files are
+// removed along with the resource itself.
+func removeLocalFileSidecar(p string) error {
+	err := os.Remove(localSidecarPath(p, false))
+	if err != nil && !os.IsNotExist(err) {
+		return errFromOS(err)
+	}
+	return nil
+}
+
+// moveLocalFileSidecar moves the sidecar file holding the properties of the
+// file at src to dst, if any.
+func moveLocalFileSidecar(src, dst string) error {
+	err := os.Rename(localSidecarPath(src, false), localSidecarPath(dst, false))
+	if err != nil && !os.IsNotExist(err) {
+		return errFromOS(err)
+	}
+	return nil
+}
+
+func (fs LocalFileSystem) Properties(ctx context.Context, name string) ([]Property, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+	return readLocalProps(p, fi.IsDir())
+}
+
+func (fs LocalFileSystem) PatchProperties(ctx context.Context, name string, patch []PropertyPatch) error {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return errFromOS(err)
+	}
+
+	mu := fs.mutex()
+	mu.Lock()
+	defer mu.Unlock()
+
+	props, err := readLocalProps(p, fi.IsDir())
+	if err != nil {
+		return err
+	}
+	return writeLocalProps(p, fi.IsDir(), patchProperties(props, patch))
+}
+
+// patchProperties applies a PROPPATCH to a copy of a list of properties.
+func patchProperties(props []Property, patch []PropertyPatch) []Property {
+	l := append([]Property(nil), props...)
+	for _, p := range patch {
+		i := 0
+		for i < len(l) && l[i].XMLName != p.Property.XMLName {
+			i++
+		}
+
+		if p.Remove {
+			if i < len(l) {
+				l = append(l[:i], l[i+1:]...)
+			}
+		} else if i < len(l) {
+			l[i] = p.Property
+		} else {
+			l = append(l, p.Property)
+		}
+	}
+	return l
+}
@@ -0,0 +1,279 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func TestLocalFileSystem_Copy(t *testing.T) {
+	dir := t.TempDir()
+	fs := LocalFileSystem(dir)
+	ctx := context.Background()
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.MkdirAll(filepath.Join(dir, "src", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"src/a.txt", "src/sub/b.txt"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.WriteFile(p, []byte(name), 0640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "src", "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{"/src", "/src/sub/dst", "/"} {
+		if _, err := fs.Copy(ctx, "/src", dst, &CopyOptions{}); !isHTTPStatus(err, http.StatusForbidden) {
+			t.Errorf("Copy(%q) = %v, want 403", dst, err)
+		}
+	}
+	if _, err := fs.Copy(ctx, "/src", "/missing/dst", &CopyOptions{}); !isHTTPStatus(err, http.StatusConflict) {
+		t.Errorf("Copy() without parent = %v, want 409", err)
+	}
+
+	if created, err := fs.Copy(ctx, "/src", "/dst", &CopyOptions{}); err != nil || !created {
+		t.Fatalf("Copy() = %v, %v", created, err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		p := filepath.Join(dir, "dst", filepath.FromSlash(name))
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("ReadFile(%q) = %v", name, err)
+		}
+		if want := "src/" + name; string(data) != want {
+			t.Errorf("ReadFile(%q) = %q, want %q", name, data, want)
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := fi.Mode().Perm(); perm != 0640 {
+			t.Errorf("%q: perm = %v, want %v", name, perm, os.FileMode(0640))
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%q: mtime = %v, want %v", name, fi.ModTime(), mtime)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "dst", "sub")); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm != 0750 {
+		t.Errorf("sub: perm = %v, want %v", perm, os.FileMode(0750))
+	}
+
+	if _, err := fs.Copy(ctx, "/src", "/dst", &CopyOptions{NoOverwrite: true}); !isHTTPStatus(err, http.StatusPreconditionFailed) {
+		t.Errorf("Copy() with NoOverwrite = %v, want 412", err)
+	}
+
+	if _, err := fs.Copy(ctx, "/src", "/shallow", &CopyOptions{NoRecursive: true}); err != nil {
+		t.Fatalf("Copy() with NoRecursive = %v", err)
+	}
+	if entries, err := os.ReadDir(filepath.Join(dir, "shallow")); err != nil || len(entries) != 0 {
+		t.Errorf("ReadDir() after Copy() with NoRecursive = %v, %v", entries, err)
+	}
+
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "src", "sub", "dangling")); err != nil {
+		t.Skip(err)
+	}
+	_, err := fs.Copy(ctx, "/src", "/partial", &CopyOptions{})
+	hrefErrs, ok := internal.HrefErrors(err)
+	if !ok || len(hrefErrs) != 1 || hrefErrs[0].Href.Path != "/partial/sub/dangling" {
+		t.Fatalf("Copy() with dangling symlink = %v, want a per-resource error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partial", "sub", "b.txt")); err != nil {
+		t.Errorf("Stat() after partial Copy() = %v", err)
+	}
+}
+
+func TestLocalFileSystem_Quota(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("world!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := LocalFileSystem(dir)
+	quota, err := fs.Quota(context.Background(), "/")
+	if err != nil {
+		t.Fatalf("Quota() = %v", err)
+	}
+	if quota.Used != 11 {
+		t.Errorf("Quota(/).Used = %v, want 11", quota.Used)
+	}
+
+	quota, err = fs.Quota(context.Background(), "/sub")
+	if err != nil {
+		t.Fatalf("Quota() = %v", err)
+	}
+	if quota.Used != 5 {
+		t.Errorf("Quota(/sub).Used = %v, want 5", quota.Used)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestLocalFileSystem_Create(t *testing.T) {
+	dir := t.TempDir()
+	fs := SyncedLocalFileSystem{LocalFileSystem(dir)}
+	ctx := context.Background()
+
+	if _, created, err := fs.Create(ctx, "/a.txt", io.NopCloser(strings.NewReader("hello")), &CreateOptions{}); err != nil || !created {
+		t.Fatalf("Create() = %v, %v", created, err)
+	}
+	p := filepath.Join(dir, "a.txt")
+	if err := os.Chmod(p, 0600); err != nil {
+		t.Fatal(err)
+	}
+	prop := Property{XMLName: xml.Name{"urn:test", "color"}, InnerXML: "red"}
+	if err := fs.PatchProperties(ctx, "/a.txt", []PropertyPatch{{Property: prop}}); err != nil {
+		t.Fatalf("PatchProperties() = %v", err)
+	}
+
+	if _, _, err := fs.Create(ctx, "/a.txt", io.NopCloser(io.MultiReader(strings.NewReader("partial"), errReader{})), &CreateOptions{}); err == nil {
+		t.Errorf("Create() with failing body = nil, want an error")
+	}
+	if data, err := os.ReadFile(p); err != nil || string(data) != "hello" {
+		t.Errorf("ReadFile() after failed Create() = %q, %v", data, err)
+	}
+
+	if _, created, err := fs.Create(ctx, "/a.txt", io.NopCloser(strings.NewReader("world")), &CreateOptions{}); err != nil || created {
+		t.Fatalf("Create() overwrite = %v, %v", created, err)
+	}
+	if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Stat() after overwrite = %v, %v", fi.Mode(), err)
+	}
+	if props, err := fs.Properties(ctx, "/a.txt"); err != nil || len(props) != 1 || props[0] != prop {
+		t.Errorf("Properties() after overwrite = %v, %v", props, err)
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else {
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".webdav.tmp.") {
+				t.Errorf("temporary file %q left behind", entry.Name())
+			}
+		}
+	}
+}
+
+func TestLocalFileSystem_ReadDir_staleTemp(t *testing.T) {
+	dir := t.TempDir()
+	fs := LocalFileSystem(dir)
+
+	stale := filepath.Join(dir, ".webdav.tmp.stale")
+	staleUpload := filepath.Join(dir, ".webdav.upload.b.txt")
+	fresh := filepath.Join(dir, ".webdav.tmp.fresh")
+	for _, p := range []string{stale, staleUpload, fresh, filepath.Join(dir, "a.txt")} {
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * localTempMaxAge)
+	for _, p := range []string{stale, staleUpload} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := fs.ReadDir(context.Background(), "/", false)
+	if err != nil {
+		t.Fatalf("ReadDir() = %v", err)
+	}
+	if len(l) != 2 || l[1].Path != "/a.txt" {
+		t.Errorf("ReadDir() = %v, want / and /a.txt", l)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Stat() on stale temporary file = %v, want not exist", err)
+	}
+	if _, err := os.Stat(staleUpload); !os.IsNotExist(err) {
+		t.Errorf("Stat() on stale pending upload = %v, want not exist", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("Stat() on fresh temporary file = %v", err)
+	}
+}
+
+func TestLocalFileSystem_uploadFollowsTarget(t *testing.T) {
+	fs := LocalFileSystem(t.TempDir())
+	ctx := context.Background()
+
+	if _, _, err := fs.Create(ctx, "/a.txt", io.NopCloser(strings.NewReader("hello")), &CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.WriteUpload(ctx, "/a.txt", 0, strings.NewReader("world")); err != nil {
+		t.Fatalf("WriteUpload() = %v", err)
+	}
+
+	if _, err := fs.Move(ctx, "/a.txt", "/b.txt", &MoveOptions{}); err != nil {
+		t.Fatalf("Move() = %v", err)
+	}
+	if _, err := fs.UploadSize(ctx, "/a.txt"); !internal.IsNotFound(err) {
+		t.Errorf("UploadSize() on move source = %v, want not found", err)
+	}
+	if size, err := fs.UploadSize(ctx, "/b.txt"); err != nil || size != 5 {
+		t.Errorf("UploadSize() on move destination = %v, %v, want 5", size, err)
+	}
+
+	if err := fs.RemoveAll(ctx, "/b.txt", &RemoveAllOptions{}); err != nil {
+		t.Fatalf("RemoveAll() = %v", err)
+	}
+	if _, err := fs.UploadSize(ctx, "/b.txt"); !internal.IsNotFound(err) {
+		t.Errorf("UploadSize() after RemoveAll() = %v, want not found", err)
+	}
+}
+
+func TestLocalFileSystem_Create_concurrent(t *testing.T) {
+	fs := LocalFileSystem(t.TempDir())
+	ctx := context.Background()
+
+	const n = 8
+	var wg sync.WaitGroup
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := fs.Create(ctx, "/a.txt", io.NopCloser(strings.NewReader("hello")), &CreateOptions{IfNoneMatch: "*"})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		} else if !isHTTPStatus(err, http.StatusPreconditionFailed) {
+			t.Errorf("Create() = %v, want 412", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%v conditional Create() calls succeeded, want 1", succeeded)
+	}
+}
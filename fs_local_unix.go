@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package webdav
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// chownLocalFile gives the file at p the owner and group of fi. Only root can
+// give files away, so failing to keep the owner isn't an error.
+func chownLocalFile(p string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	err := os.Lchown(p, int(st.Uid), int(st.Gid))
+	if !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	// The group can still be kept if the process is a member
+	if err := os.Lchown(p, -1, int(st.Gid)); err != nil && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// LocalFileSystem stores pending uploads in ".webdav.upload.<name>" files next
+// to their destination, hidden from clients like other reserved files. They
+// follow their destination when it's moved or removed, and are dropped after a
+// day without writes.
+var (
+	_ UploadFileSystem = LocalFileSystem("")
+	_ UploadFileSystem = SyncedLocalFileSystem{}
+)
+
+const localUploadPrefix = localReservedPrefix + "upload."
+
+var (
+	localUploadsMutex sync.Mutex
+	localUploads      = make(map[string]bool)
+)
+
+func localUploadPath(p string) string {
+	dir, base := filepath.Split(p)
+	return filepath.Join(dir, localUploadPrefix+base)
+}
+
+// removeLocalUpload removes the pending upload of the file at p, if any.
+func removeLocalUpload(p string) error {
+	err := os.Remove(localUploadPath(p))
+	if err != nil && !os.IsNotExist(err) {
+		return errFromOS(err)
+	}
+	return nil
+}
+
+// moveLocalUpload moves the pending upload of the file at src to dst, if any.
+func moveLocalUpload(src, dst string) error {
+	err := os.Rename(localUploadPath(src), localUploadPath(dst))
+	if err != nil && !os.IsNotExist(err) {
+		return errFromOS(err)
+	}
+	return nil
+}
+
+// lockLocalUpload prevents concurrent writes to the pending upload of the file
+// at p. Requests are expected to be sent one after the other, so conflicting
+// requests are rejected rather than queued.
+func lockLocalUpload(p string) error {
+	localUploadsMutex.Lock()
+	defer localUploadsMutex.Unlock()
+
+	if localUploads[p] {
+		return internal.HTTPErrorf(http.StatusConflict, "webdav: upload already in progress")
+	}
+	localUploads[p] = true
+	return nil
+}
+
+func unlockLocalUpload(p string) {
+	localUploadsMutex.Lock()
+	delete(localUploads, p)
+	localUploadsMutex.Unlock()
+}
+
+func (fs LocalFileSystem) UploadSize(ctx context.Context, name string) (int64, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(localUploadPath(p))
+	if err != nil {
+		return 0, errFromOS(err)
+	}
+	return fi.Size(), nil
+}
+
+func (fs LocalFileSystem) WriteUpload(ctx context.Context, name string, offset int64, body io.Reader) (size int64, err error) {
+	return fs.writeUpload(ctx, name, offset, body, false)
+}
+
+func (fs LocalFileSystem) writeUpload(ctx context.Context, name string, offset int64, body io.Reader, sync bool) (size int64, err error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return 0, err
+	}
+	if err := lockLocalUpload(p); err != nil {
+		return 0, err
+	}
+	defer unlockLocalUpload(p)
+
+	flags := os.O_WRONLY
+	if offset == 0 {
+		flags |= os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(localUploadPath(p), flags, 0666)
+	if os.IsNotExist(err) && offset == 0 {
+		return 0, NewHTTPError(http.StatusConflict, err)
+	} else if os.IsNotExist(err) {
+		return 0, internal.HTTPErrorf(http.StatusRequestedRangeNotSatisfiable, "webdav: no pending upload")
+	} else if err != nil {
+		return 0, errFromOS(err)
+	}
+	defer f.Close()
+
+	size, err = f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errFromOS(err)
+	} else if size != offset {
+		return 0, internal.HTTPErrorf(http.StatusRequestedRangeNotSatisfiable, "webdav: upload offset %v doesn't match received size %v", offset, size)
+	}
+
+	n, copyErr := io.Copy(f, body)
+	size += n
+	if sync {
+		if err := f.Sync(); err != nil {
+			return 0, errFromOS(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return 0, errFromOS(err)
+	}
+	if copyErr != nil {
+		return size, errFromOS(copyErr)
+	}
+	return size, nil
+}
+
+func (fs LocalFileSystem) CommitUpload(ctx context.Context, name string, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	return fs.commitUpload(ctx, name, opts, false)
+}
+
+func (fs LocalFileSystem) commitUpload(ctx context.Context, name string, opts *CreateOptions, sync bool) (fi *FileInfo, created bool, err error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := lockLocalUpload(p); err != nil {
+		return nil, false, err
+	}
+	defer unlockLocalUpload(p)
+
+	up := localUploadPath(p)
+	if _, err := os.Stat(up); err != nil {
+		return nil, false, errFromOS(err)
+	}
+	return fs.replaceFile(ctx, name, p, up, opts, sync)
+}
+
+func (fs SyncedLocalFileSystem) WriteUpload(ctx context.Context, name string, offset int64, body io.Reader) (size int64, err error) {
+	return fs.LocalFileSystem.writeUpload(ctx, name, offset, body, true)
+}
+
+func (fs SyncedLocalFileSystem) CommitUpload(ctx context.Context, name string, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	return fs.LocalFileSystem.commitUpload(ctx, name, opts, true)
+}
@@ -0,0 +1,688 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// MemFileSystem implements FileSystem in memory. It is safe for concurrent
+// use.
+type MemFileSystem struct {
+	// MaxSize is the maximum total size of the files and pending uploads in
+	// bytes. Zero means no limit. It must not be changed once the filesystem is in use.
+	MaxSize int64
+
+	mutex   sync.RWMutex
+	root    *memNode
+	version uint64
+	uploads map[string][]byte
+	// used is the total size of the files and pending uploads
+	used int64
+
+	// changeLog lists the paths modified after changeLogStart, in order
+	changeLog      []memChange
+	changeLogStart uint64
+	syncTokenBase  string
+}
+
+// memMaxChanges is the maximum number of entries in the change log. Older
+// sync tokens are invalidated when it's exceeded.
+const memMaxChanges = 10000
+
+type memChange struct {
+	version uint64
+	path    string
+}
+
+var (
+	_ FileSystem       = (*MemFileSystem)(nil)
+	_ PropertyStore    = (*MemFileSystem)(nil)
+	_ QuotaFileSystem  = (*MemFileSystem)(nil)
+	_ UploadFileSystem = (*MemFileSystem)(nil)
+	_ ChangeTracker    = (*MemFileSystem)(nil)
+)
+
+type memNode struct {
+	isDir    bool
+	data     []byte
+	modTime  time.Time
+	etag     string
+	props    []Property
+	children map[string]*memNode
+}
+
+// NewMemFileSystem creates a new empty in-memory filesystem.
+func NewMemFileSystem() *MemFileSystem {
+	// Sync tokens contain a random identifier, so that tokens from another
+	// filesystem are rejected
+	var id [8]byte
+	rand.Read(id[:])
+
+	fs := &MemFileSystem{
+		uploads:       make(map[string][]byte),
+		syncTokenBase: fmt.Sprintf("urn:x-go-webdav-mem:%x:", id),
+	}
+	fs.root = fs.newNode(true)
+	fs.changeLogStart = fs.version
+	return fs
+}
+
+// newNode creates a new node. The caller must hold the write lock.
+func (fs *MemFileSystem) newNode(isDir bool) *memNode {
+	node := &memNode{isDir: isDir}
+	if isDir {
+		node.children = make(map[string]*memNode)
+	}
+	fs.touch(node)
+	return node
+}
+
+// touch updates the modification time and ETag of a node. The caller must
+// hold the write lock.
+func (fs *MemFileSystem) touch(node *memNode) {
+	// The ETag is a sequence number, so that it changes on every write
+	fs.version++
+	node.modTime = time.Now()
+	node.etag = fmt.Sprintf("%x", fs.version)
+}
+
+// recordChange adds a node and its children to the change log. If the node
+// is nil, only the path is added. The caller must hold the write lock.
+func (fs *MemFileSystem) recordChange(p string, node *memNode) {
+	fs.changeLog = append(fs.changeLog, memChange{version: fs.version, path: path.Clean(p)})
+	if node != nil {
+		for name, child := range node.children {
+			fs.recordChange(path.Join(p, name), child)
+		}
+	}
+
+	if len(fs.changeLog) > memMaxChanges {
+		n := len(fs.changeLog) - memMaxChanges/2
+		fs.changeLogStart = fs.changeLog[n-1].version
+		fs.changeLog = append([]memChange(nil), fs.changeLog[n:]...)
+	}
+}
+
+// clone performs a copy of a node. The caller must hold the write lock.
+func (fs *MemFileSystem) clone(node *memNode, recursive bool) *memNode {
+	dup := fs.newNode(node.isDir)
+	dup.data = node.data // never modified in place
+	dup.modTime = node.modTime
+	dup.props = append([]Property(nil), node.props...)
+	if recursive {
+		for name, child := range node.children {
+			dup.children[name] = fs.clone(child, true)
+		}
+	}
+	return dup
+}
+
+// size returns the total size of the files under a node.
+func (node *memNode) size() int64 {
+	n := int64(len(node.data))
+	for _, child := range node.children {
+		n += child.size()
+	}
+	return n
+}
+
+// checkQuota returns an error if replacing data of size old with data of
+// size new would exceed MaxSize. The caller must hold the lock.
+func (fs *MemFileSystem) checkQuota(old, new int64) error {
+	if fs.MaxSize > 0 && fs.used-old+new > fs.MaxSize {
+		return internal.NewConditionError(http.StatusInsufficientStorage, xml.Name{internal.Namespace, "quota-not-exceeded"})
+	}
+	return nil
+}
+
+func memSplitPath(name string) ([]string, error) {
+	name = path.Clean(name)
+	if !path.IsAbs(name) {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "webdav: expected absolute path, got %q", name)
+	}
+	if name == "/" {
+		return nil, nil
+	}
+	return strings.Split(name[1:], "/"), nil
+}
+
+// lookup returns the node at the specified path. The caller must hold the
+// lock.
+func (fs *MemFileSystem) lookup(name string) (*memNode, error) {
+	elems, err := memSplitPath(name)
+	if err != nil {
+		return nil, err
+	}
+	node := fs.root
+	for _, elem := range elems {
+		if !node.isDir {
+			return nil, internal.HTTPErrorf(http.StatusNotFound, "webdav: %q not found", name)
+		}
+		node = node.children[elem]
+		if node == nil {
+			return nil, internal.HTTPErrorf(http.StatusNotFound, "webdav: %q not found", name)
+		}
+	}
+	return node, nil
+}
+
+// lookupParent returns the parent directory node and the base name of the
+// specified path. The caller must hold the lock.
+func (fs *MemFileSystem) lookupParent(name string) (parent *memNode, base string, err error) {
+	elems, err := memSplitPath(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(elems) == 0 {
+		return nil, "", internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot modify root directory")
+	}
+	parent, err = fs.lookup("/" + strings.Join(elems[:len(elems)-1], "/"))
+	if internal.IsNotFound(err) || (err == nil && !parent.isDir) {
+		return nil, "", internal.HTTPErrorf(http.StatusConflict, "webdav: parent of %q not found", name)
+	} else if err != nil {
+		return nil, "", err
+	}
+	return parent, elems[len(elems)-1], nil
+}
+
+func (node *memNode) fileInfo(p string) *FileInfo {
+	fi := &FileInfo{
+		Path:    p,
+		ModTime: node.modTime,
+		IsDir:   node.isDir,
+		ETag:    node.etag,
+	}
+	if !node.isDir {
+		fi.Size = int64(len(node.data))
+		fi.MIMEType = mime.TypeByExtension(path.Ext(p))
+	}
+	return fi
+}
+
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error {
+	return nil
+}
+
+func (fs *MemFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if node.isDir {
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q is a directory", name)
+	}
+	return memFile{bytes.NewReader(node.data)}, nil
+}
+
+func (fs *MemFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return node.fileInfo(path.Clean(name)), nil
+}
+
+func (fs *MemFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []FileInfo
+	var walk func(p string, node *memNode, depth int)
+	walk = func(p string, node *memNode, depth int) {
+		l = append(l, *node.fileInfo(p))
+		if !node.isDir || (!recursive && depth > 0) {
+			return
+		}
+
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			walk(path.Join(p, name), node.children[name], depth+1)
+		}
+	}
+	walk(path.Clean(name), node, 0)
+
+	return l, nil
+}
+
+func (fs *MemFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	return fs.create(name, data, opts)
+}
+
+// create replaces the contents of a file. The caller must hold the write
+// lock.
+func (fs *MemFileSystem) create(name string, data []byte, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	parent, base, err := fs.lookupParent(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	node := parent.children[base]
+	if node != nil && node.isDir {
+		return nil, false, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q is a directory", name)
+	}
+
+	var curFileInfo *FileInfo
+	if node != nil {
+		curFileInfo = node.fileInfo(name)
+	}
+	if err := checkConditionalMatches(curFileInfo, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return nil, false, err
+	}
+
+	var oldSize int64
+	if node != nil {
+		oldSize = node.size()
+	}
+	if err := fs.checkQuota(oldSize, int64(len(data))); err != nil {
+		return nil, false, err
+	}
+
+	created = node == nil
+	if created {
+		node = fs.newNode(false)
+		parent.children[base] = node
+	} else {
+		fs.touch(node)
+	}
+	fs.used += int64(len(data)) - oldSize
+	node.data = data
+	fs.recordChange(name, nil)
+
+	return node.fileInfo(path.Clean(name)), created, nil
+}
+
+func (fs *MemFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return err
+	}
+	parent, base, err := fs.lookupParent(name)
+	if err != nil {
+		return err
+	}
+
+	if err := checkConditionalMatches(node.fileInfo(name), opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return err
+	}
+
+	delete(parent.children, base)
+	fs.used -= node.size()
+	fs.touch(parent)
+	fs.recordChange(path.Dir(path.Clean(name)), nil)
+	fs.recordChange(name, node)
+	return nil
+}
+
+func (fs *MemFileSystem) Mkdir(ctx context.Context, name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	parent, base, err := fs.lookupParent(name)
+	if err != nil {
+		return err
+	}
+	if parent.children[base] != nil {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q already exists", name)
+	}
+
+	parent.children[base] = fs.newNode(true)
+	fs.touch(parent)
+	fs.recordChange(path.Dir(path.Clean(name)), nil)
+	fs.recordChange(name, nil)
+	return nil
+}
+
+// prepareDest checks whether a COPY or MOVE can be performed, and returns the
+// destination parent node and base name. The caller must hold the write
+// lock.
+func (fs *MemFileSystem) prepareDest(src, dst string, noOverwrite bool) (parent *memNode, base string, created bool, err error) {
+	src, dst = path.Clean(src), path.Clean(dst)
+	if src == dst || strings.HasPrefix(dst, strings.TrimSuffix(src, "/")+"/") {
+		return nil, "", false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot copy or move %q into itself", src)
+	}
+
+	parent, base, err = fs.lookupParent(dst)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	created = parent.children[base] == nil
+	if !created && noOverwrite {
+		return nil, "", false, internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: %q already exists", dst)
+	}
+	return parent, base, created, nil
+}
+
+func (fs *MemFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	node, err := fs.lookup(src)
+	if err != nil {
+		return false, err
+	}
+
+	parent, base, created, err := fs.prepareDest(src, dst, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	dup := fs.clone(node, !options.NoRecursive)
+	old := parent.children[base]
+	var oldSize int64
+	if old != nil {
+		oldSize = old.size()
+	}
+	dupSize := dup.size()
+	if err := fs.checkQuota(oldSize, dupSize); err != nil {
+		return false, err
+	}
+
+	parent.children[base] = dup
+	fs.used += dupSize - oldSize
+	fs.touch(parent)
+	fs.recordChange(path.Dir(path.Clean(dst)), nil)
+	fs.recordChange(dst, old)
+	fs.recordChange(dst, dup)
+	return created, nil
+}
+
+func (fs *MemFileSystem) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	node, err := fs.lookup(src)
+	if err != nil {
+		return false, err
+	}
+	srcParent, srcBase, err := fs.lookupParent(src)
+	if err != nil {
+		return false, err
+	}
+
+	dstParent, dstBase, created, err := fs.prepareDest(src, dst, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	old := dstParent.children[dstBase]
+	if old != nil {
+		fs.used -= old.size()
+	}
+	delete(srcParent.children, srcBase)
+	dstParent.children[dstBase] = node
+	fs.touch(srcParent)
+	fs.touch(dstParent)
+	fs.recordChange(path.Dir(path.Clean(src)), nil)
+	fs.recordChange(src, node)
+	fs.recordChange(path.Dir(path.Clean(dst)), nil)
+	fs.recordChange(dst, old)
+	fs.recordChange(dst, node)
+	return created, nil
+}
+
+func (fs *MemFileSystem) UploadSize(ctx context.Context, name string) (int64, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	data, ok := fs.uploads[path.Clean(name)]
+	if !ok {
+		return 0, internal.HTTPErrorf(http.StatusNotFound, "webdav: no pending upload for %q", name)
+	}
+	return int64(len(data)), nil
+}
+
+func (fs *MemFileSystem) WriteUpload(ctx context.Context, name string, offset int64, body io.Reader) (size int64, err error) {
+	data, readErr := io.ReadAll(body)
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, _, err := fs.lookupParent(name); err != nil {
+		return 0, err
+	}
+
+	name = path.Clean(name)
+	pending, ok := fs.uploads[name]
+	var discarded int64
+	if offset == 0 {
+		discarded = int64(len(pending))
+		pending = nil
+	} else if !ok || int64(len(pending)) != offset {
+		return 0, internal.HTTPErrorf(http.StatusRequestedRangeNotSatisfiable, "webdav: upload offset %v doesn't match received size %v", offset, len(pending))
+	}
+	if err := fs.checkQuota(discarded, int64(len(data))); err != nil {
+		return 0, err
+	}
+
+	pending = append(pending, data...)
+	fs.uploads[name] = pending
+	fs.used += int64(len(data)) - discarded
+	return int64(len(pending)), readErr
+}
+
+func (fs *MemFileSystem) CommitUpload(ctx context.Context, name string, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	name = path.Clean(name)
+	data, ok := fs.uploads[name]
+	if !ok {
+		return nil, false, internal.HTTPErrorf(http.StatusNotFound, "webdav: no pending upload for %q", name)
+	}
+
+	// The pending upload becomes the file, it mustn't be counted twice in
+	// the quota
+	delete(fs.uploads, name)
+	fs.used -= int64(len(data))
+	fi, created, err = fs.create(name, data, opts)
+	if err != nil {
+		fs.uploads[name] = data
+		fs.used += int64(len(data))
+		return nil, false, err
+	}
+	return fi, created, nil
+}
+
+// Quota reports the space used by the whole filesystem, and the space left
+// if MaxSize is set.
+func (fs *MemFileSystem) Quota(ctx context.Context, name string) (*Quota, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if _, err := fs.lookup(name); err != nil {
+		return nil, err
+	}
+
+	return &Quota{Used: fs.used, Available: fs.available()}, nil
+}
+
+// AvailableBytes reports the space left if MaxSize is set.
+func (fs *MemFileSystem) AvailableBytes(ctx context.Context, name string) (int64, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if _, err := fs.lookup(name); err != nil {
+		return 0, err
+	}
+	return fs.available(), nil
+}
+
+// available returns the space left, or -1 if MaxSize isn't set. The caller
+// must hold the mutex.
+func (fs *MemFileSystem) available() int64 {
+	if fs.MaxSize <= 0 {
+		return -1
+	} else if fs.used > fs.MaxSize {
+		return 0
+	}
+	return fs.MaxSize - fs.used
+}
+
+func (fs *MemFileSystem) Properties(ctx context.Context, name string) ([]Property, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Property(nil), node.props...), nil
+}
+
+func (fs *MemFileSystem) PatchProperties(ctx context.Context, name string, patch []PropertyPatch) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return err
+	}
+	node.props = patchProperties(node.props, patch)
+	fs.version++
+	fs.recordChange(name, nil)
+	return nil
+}
+
+func (fs *MemFileSystem) syncToken() string {
+	return fs.syncTokenBase + strconv.FormatUint(fs.version, 10)
+}
+
+// parseSyncToken returns the version identified by a sync token. The caller
+// must hold the lock.
+func (fs *MemFileSystem) parseSyncToken(token string) (uint64, error) {
+	if !strings.HasPrefix(token, fs.syncTokenBase) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSyncToken, token)
+	}
+	version, err := strconv.ParseUint(strings.TrimPrefix(token, fs.syncTokenBase), 10, 64)
+	if err != nil || version > fs.version {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSyncToken, token)
+	} else if version < fs.changeLogStart {
+		return 0, fmt.Errorf("%w: %q has expired", ErrInvalidSyncToken, token)
+	}
+	return version, nil
+}
+
+// lookupCollection returns the node of a collection. The caller must hold
+// the lock.
+func (fs *MemFileSystem) lookupCollection(name string) (*memNode, error) {
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.isDir {
+		return nil, internal.HTTPErrorf(http.StatusForbidden, "webdav: %q is not a collection", name)
+	}
+	return node, nil
+}
+
+func (fs *MemFileSystem) SyncToken(ctx context.Context, name string) (string, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	if _, err := fs.lookupCollection(name); err != nil {
+		return "", err
+	}
+	return fs.syncToken(), nil
+}
+
+// Changes reports the changes recorded since a sync token. Only the most
+// recent changes are kept, older tokens are rejected.
+func (fs *MemFileSystem) Changes(ctx context.Context, name, token string, recursive bool) (changes []Change, newToken string, err error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	node, err := fs.lookupCollection(name)
+	if err != nil {
+		return nil, "", err
+	}
+	name = path.Clean(name)
+
+	var paths []string
+	if token == "" {
+		var walk func(p string, node *memNode)
+		walk = func(p string, node *memNode) {
+			for childName, child := range node.children {
+				childPath := path.Join(p, childName)
+				paths = append(paths, childPath)
+				if recursive {
+					walk(childPath, child)
+				}
+			}
+		}
+		walk(name, node)
+	} else {
+		version, err := fs.parseSyncToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+
+		prefix := strings.TrimSuffix(name, "/") + "/"
+		seen := make(map[string]bool)
+		for i := len(fs.changeLog) - 1; i >= 0 && fs.changeLog[i].version > version; i-- {
+			p := fs.changeLog[i].path
+			if seen[p] || !strings.HasPrefix(p, prefix) {
+				continue
+			}
+			if !recursive && strings.Contains(p[len(prefix):], "/") {
+				continue
+			}
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	changes = make([]Change, len(paths))
+	for i, p := range paths {
+		changes[i].Path = p
+		if node, err := fs.lookup(p); err == nil {
+			changes[i].FileInfo = node.fileInfo(p)
+		}
+	}
+	return changes, fs.syncToken(), nil
+}
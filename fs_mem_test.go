@@ -0,0 +1,191 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMemFileSystem(t *testing.T) {
+	ctx := context.Background()
+	fs := NewMemFileSystem()
+
+	if err := fs.Mkdir(ctx, "/dir"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	if err := fs.Mkdir(ctx, "/missing/dir"); !isHTTPStatus(err, http.StatusConflict) {
+		t.Errorf("Mkdir() with missing parent = %v, want 409", err)
+	}
+
+	fi, created, err := fs.Create(ctx, "/dir/a.txt", io.NopCloser(strings.NewReader("hello")), &CreateOptions{})
+	if err != nil || !created {
+		t.Fatalf("Create() = %v, %v", created, err)
+	}
+	if fi.Size != 5 || fi.MIMEType != "text/plain; charset=utf-8" || fi.ETag == "" {
+		t.Errorf("Create() = %+v", fi)
+	}
+
+	_, _, err = fs.Create(ctx, "/dir/a.txt", io.NopCloser(strings.NewReader("world")), &CreateOptions{IfNoneMatch: "*"})
+	if !isHTTPStatus(err, http.StatusPreconditionFailed) {
+		t.Errorf("Create() with If-None-Match = %v, want 412", err)
+	}
+	fi2, created, err := fs.Create(ctx, "/dir/a.txt", io.NopCloser(strings.NewReader("world")), &CreateOptions{IfMatch: ConditionalMatch(`"` + fi.ETag + `"`)})
+	if err != nil || created {
+		t.Fatalf("Create() with If-Match = %v, %v", created, err)
+	}
+	if fi2.ETag == fi.ETag {
+		t.Errorf("Create() didn't change ETag")
+	}
+
+	if _, err := fs.Copy(ctx, "/dir", "/dir/sub", &CopyOptions{}); !isHTTPStatus(err, http.StatusForbidden) {
+		t.Errorf("Copy() into itself = %v, want 403", err)
+	}
+	if created, err := fs.Copy(ctx, "/dir", "/copy", &CopyOptions{}); err != nil || !created {
+		t.Fatalf("Copy() = %v, %v", created, err)
+	}
+	if _, err := fs.Move(ctx, "/copy", "/dir", &MoveOptions{NoOverwrite: true}); !isHTTPStatus(err, http.StatusPreconditionFailed) {
+		t.Errorf("Move() without overwrite = %v, want 412", err)
+	}
+	if created, err := fs.Move(ctx, "/copy", "/moved", &MoveOptions{}); err != nil || !created {
+		t.Fatalf("Move() = %v, %v", created, err)
+	}
+
+	l, err := fs.ReadDir(ctx, "/", true)
+	if err != nil {
+		t.Fatalf("ReadDir() = %v", err)
+	}
+	var paths []string
+	for _, fi := range l {
+		paths = append(paths, fi.Path)
+	}
+	if got, want := strings.Join(paths, " "), "/ /dir /dir/a.txt /moved /moved/a.txt"; got != want {
+		t.Errorf("ReadDir() = %v, want %v", got, want)
+	}
+
+	if err := fs.RemoveAll(ctx, "/dir", &RemoveAllOptions{}); err != nil {
+		t.Fatalf("RemoveAll() = %v", err)
+	}
+	if _, err := fs.Stat(ctx, "/dir/a.txt"); !isHTTPStatus(err, http.StatusNotFound) {
+		t.Errorf("Stat() after RemoveAll() = %v, want 404", err)
+	}
+}
+
+func TestMemFileSystem_range(t *testing.T) {
+	h := &Handler{FileSystem: NewMemFileSystem()}
+	doTestRequest(h, http.MethodPut, "/file.txt", "hello world", nil)
+
+	res := doTestRequest(h, http.MethodGet, "/file.txt", "", map[string]string{"Range": "bytes=6-"})
+	if res.StatusCode != http.StatusPartialContent {
+		t.Fatalf("GET: status = %v, want %v", res.StatusCode, http.StatusPartialContent)
+	}
+	if body := readTestBody(t, res); body != "world" {
+		t.Errorf("GET: body = %q, want %q", body, "world")
+	}
+}
+
+func TestMemFileSystem_Changes(t *testing.T) {
+	ctx := context.Background()
+	fs := NewMemFileSystem()
+
+	if err := fs.Mkdir(ctx, "/src"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	if err := fs.Mkdir(ctx, "/dst"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	if _, _, err := fs.Create(ctx, "/src/a.txt", io.NopCloser(strings.NewReader("a")), &CreateOptions{}); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+
+	token, err := fs.SyncToken(ctx, "/")
+	if err != nil {
+		t.Fatalf("SyncToken() = %v", err)
+	}
+	if _, err := fs.SyncToken(ctx, "/src/a.txt"); !isHTTPStatus(err, http.StatusForbidden) {
+		t.Errorf("SyncToken() on file = %v, want 403", err)
+	}
+
+	if _, err := fs.Move(ctx, "/src/a.txt", "/dst/b.txt", &MoveOptions{}); err != nil {
+		t.Fatalf("Move() = %v", err)
+	}
+	if err := fs.PatchProperties(ctx, "/dst", []PropertyPatch{{Property: *NewDisplayNameProperty("Destination")}}); err != nil {
+		t.Fatalf("PatchProperties() = %v", err)
+	}
+
+	changes, newToken, err := fs.Changes(ctx, "/", token, true)
+	if err != nil {
+		t.Fatalf("Changes() = %v", err)
+	}
+	var l []string
+	for _, change := range changes {
+		s := change.Path
+		if change.FileInfo == nil {
+			s += " (deleted)"
+		}
+		l = append(l, s)
+	}
+	if got, want := strings.Join(l, ", "), "/dst, /dst/b.txt, /src, /src/a.txt (deleted)"; got != want {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+
+	changes, _, err = fs.Changes(ctx, "/", newToken, true)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Changes() with new token = %+v, %v", changes, err)
+	}
+
+	other := NewMemFileSystem()
+	if _, _, err := other.Changes(ctx, "/", token, true); !errors.Is(err, ErrInvalidSyncToken) {
+		t.Errorf("Changes() with token of another filesystem = %v, want %v", err, ErrInvalidSyncToken)
+	}
+}
+
+func TestMemFileSystem_Quota(t *testing.T) {
+	ctx := context.Background()
+	fs := NewMemFileSystem()
+
+	checkUsed := func(op string, want int64) {
+		t.Helper()
+		quota, err := fs.Quota(ctx, "/")
+		if err != nil {
+			t.Fatalf("Quota() = %v", err)
+		}
+		if quota.Used != want {
+			t.Errorf("after %v: Quota().Used = %v, want %v", op, quota.Used, want)
+		}
+	}
+
+	if err := fs.Mkdir(ctx, "/dir"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	if _, _, err := fs.Create(ctx, "/dir/a.txt", io.NopCloser(strings.NewReader("hello")), &CreateOptions{}); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	checkUsed("Create", 5)
+	if _, _, err := fs.Create(ctx, "/dir/a.txt", io.NopCloser(strings.NewReader("hi")), &CreateOptions{}); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	checkUsed("overwriting Create", 2)
+	if _, err := fs.Copy(ctx, "/dir", "/copy", &CopyOptions{}); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+	checkUsed("Copy", 4)
+	if _, err := fs.Move(ctx, "/copy/a.txt", "/dir/a.txt", &MoveOptions{}); err != nil {
+		t.Fatalf("Move() = %v", err)
+	}
+	checkUsed("overwriting Move", 2)
+	if _, err := fs.WriteUpload(ctx, "/b.txt", 0, strings.NewReader("abc")); err != nil {
+		t.Fatalf("WriteUpload() = %v", err)
+	}
+	checkUsed("WriteUpload", 5)
+	if _, _, err := fs.CommitUpload(ctx, "/b.txt", &CreateOptions{}); err != nil {
+		t.Fatalf("CommitUpload() = %v", err)
+	}
+	checkUsed("CommitUpload", 5)
+	if err := fs.RemoveAll(ctx, "/dir", &RemoveAllOptions{}); err != nil {
+		t.Fatalf("RemoveAll() = %v", err)
+	}
+	checkUsed("RemoveAll", 3)
+}
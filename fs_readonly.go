@@ -0,0 +1,135 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// ReadOnlyFileSystem implements a read-only FileSystem for an fs.FS, such as
+// an embed.FS or a zip.Reader. Methods which modify the filesystem return a
+// "403 Forbidden" error.
+type ReadOnlyFileSystem struct {
+	FS fs.FS
+}
+
+var (
+	_ FileSystem = ReadOnlyFileSystem{}
+	_ DirWalker  = ReadOnlyFileSystem{}
+)
+
+var errReadOnly = errors.New("webdav: read-only filesystem")
+
+func (fsys ReadOnlyFileSystem) fsPath(name string) (string, error) {
+	name = path.Clean(name)
+	if !path.IsAbs(name) {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: expected absolute path, got %q", name)
+	}
+	if name == "/" {
+		return ".", nil
+	}
+	return strings.TrimPrefix(name, "/"), nil
+}
+
+func (fsys ReadOnlyFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := fsys.fsPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.FS.Open(p)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errFromOS(err)
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q is a directory", name)
+	}
+	return f, nil
+}
+
+func (fsys ReadOnlyFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	p, err := fsys.fsPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.Stat(fsys.FS, p)
+	if err != nil {
+		return nil, errFromOS(err)
+	}
+	return fileInfoFromOS(name, fi), nil
+}
+
+func (fsys ReadOnlyFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	var l []FileInfo
+	err := fsys.WalkDir(ctx, name, recursive, func(fi *FileInfo) error {
+		l = append(l, *fi)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (fsys ReadOnlyFileSystem) WalkDir(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	root, err := fsys.fsPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = fs.WalkDir(fsys.FS, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		href := "/"
+		if p != "." {
+			href += p
+		}
+		if err := fn(fileInfoFromOS(href, fi)); err != nil {
+			return err
+		}
+
+		if !recursive && d.IsDir() && p != root {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return errFromOS(err)
+}
+
+func (fsys ReadOnlyFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	return nil, false, NewHTTPError(http.StatusForbidden, errReadOnly)
+}
+
+func (fsys ReadOnlyFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	return NewHTTPError(http.StatusForbidden, errReadOnly)
+}
+
+func (fsys ReadOnlyFileSystem) Mkdir(ctx context.Context, name string) error {
+	return NewHTTPError(http.StatusForbidden, errReadOnly)
+}
+
+func (fsys ReadOnlyFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	return false, NewHTTPError(http.StatusForbidden, errReadOnly)
+}
+
+func (fsys ReadOnlyFileSystem) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	return false, NewHTTPError(http.StatusForbidden, errReadOnly)
+}
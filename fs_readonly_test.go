@@ -0,0 +1,60 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadOnlyFileSystem(t *testing.T) {
+	ctx := context.Background()
+	fs := ReadOnlyFileSystem{FS: fstest.MapFS{
+		"index.html":     {Data: []byte("<h1>Hello</h1>")},
+		"dir/a.txt":      {Data: []byte("a")},
+		"dir/sub/b.json": {Data: []byte("{}")},
+	}}
+
+	fi, err := fs.Stat(ctx, "/index.html")
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	if fi.Size != 14 || fi.MIMEType != "text/html; charset=utf-8" || fi.ETag == "" {
+		t.Errorf("Stat() = %+v", fi)
+	}
+
+	for _, tc := range []struct {
+		recursive bool
+		want      string
+	}{
+		{false, "/dir /dir/a.txt /dir/sub"},
+		{true, "/dir /dir/a.txt /dir/sub /dir/sub/b.json"},
+	} {
+		l, err := fs.ReadDir(ctx, "/dir", tc.recursive)
+		if err != nil {
+			t.Fatalf("ReadDir() = %v", err)
+		}
+		var paths []string
+		for _, fi := range l {
+			paths = append(paths, fi.Path)
+		}
+		if got := strings.Join(paths, " "); got != tc.want {
+			t.Errorf("ReadDir(recursive = %v) = %v, want %v", tc.recursive, got, tc.want)
+		}
+	}
+
+	h := &Handler{FileSystem: fs}
+	res := doTestRequest(h, http.MethodGet, "/dir/a.txt", "", nil)
+	if body := readTestBody(t, res); res.StatusCode != http.StatusOK || body != "a" {
+		t.Errorf("GET: status = %v, body = %q", res.StatusCode, body)
+	}
+	res = doTestRequest(h, http.MethodGet, "/missing", "", nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("GET missing file: status = %v, want %v", res.StatusCode, http.StatusNotFound)
+	}
+	res = doTestRequest(h, http.MethodPut, "/dir/a.txt", "b", nil)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("PUT: status = %v, want %v", res.StatusCode, http.StatusForbidden)
+	}
+}
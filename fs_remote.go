@@ -0,0 +1,127 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// RemoteFileSystem implements FileSystem for a remote WebDAV server. It can
+// be used as a Handler backend to build a WebDAV gateway.
+//
+// Names are resolved relative to the client endpoint. Conditional requests
+// and error status codes are forwarded to the remote server as-is.
+type RemoteFileSystem struct {
+	Client *Client
+}
+
+var (
+	_ FileSystem = RemoteFileSystem{}
+	_ DirWalker  = RemoteFileSystem{}
+)
+
+// remotePath converts a FileSystem name into a path relative to the client
+// endpoint.
+func (fs RemoteFileSystem) remotePath(name string) string {
+	return strings.TrimPrefix(path.Clean(name), "/")
+}
+
+// localPath converts a path returned by the remote server into a FileSystem
+// name.
+func (fs RemoteFileSystem) localPath(p string) string {
+	base := strings.TrimSuffix(fs.Client.ic.ResolveHref("").Path, "/")
+	if p == base {
+		return "/"
+	} else if strings.HasPrefix(p, base+"/") {
+		return strings.TrimPrefix(p, base)
+	}
+	return p
+}
+
+func (fs RemoteFileSystem) fileInfo(fi *FileInfo) *FileInfo {
+	fi.Path = fs.localPath(fi.Path)
+	return fi
+}
+
+// localError converts the paths of the per-resource errors reported by the
+// remote server into FileSystem names.
+func (fs RemoteFileSystem) localError(err error) error {
+	hrefErrs, ok := internal.HrefErrors(err)
+	if !ok {
+		return err
+	}
+	errs := make([]error, len(hrefErrs))
+	for i, hrefErr := range hrefErrs {
+		errs[i] = NewResourceError(fs.localPath(hrefErr.Href.Path), hrefErr.Err)
+	}
+	return errors.Join(errs...)
+}
+
+func (fs RemoteFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return fs.Client.Open(ctx, fs.remotePath(name))
+}
+
+func (fs RemoteFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	fi, err := fs.Client.Stat(ctx, fs.remotePath(name))
+	if err != nil {
+		return nil, err
+	}
+	return fs.fileInfo(fi), nil
+}
+
+func (fs RemoteFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	l, err := fs.Client.ReadDir(ctx, fs.remotePath(name), recursive)
+	for i := range l {
+		fs.fileInfo(&l[i])
+	}
+	return l, err
+}
+
+func (fs RemoteFileSystem) WalkDir(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	return fs.Client.ReadDirFunc(ctx, fs.remotePath(name), recursive, func(fi *FileInfo) error {
+		return fn(fs.fileInfo(fi))
+	})
+}
+
+func (fs RemoteFileSystem) Create(ctx context.Context, name string, body io.ReadCloser, opts *CreateOptions) (fi *FileInfo, created bool, err error) {
+	req, err := fs.Client.newPutRequest(fs.remotePath(name), body, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	fi, created, err = fs.Client.doPut(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Without an ETag, clients can't make conditional requests on the new
+	// file: try to fetch it from the remote server. The file has been
+	// written anyway, so failures are ignored.
+	if fi.ETag == "" {
+		if statFi, err := fs.Client.Stat(ctx, fs.remotePath(name)); err == nil {
+			fi = statFi
+		}
+	}
+	return fs.fileInfo(fi), created, nil
+}
+
+func (fs RemoteFileSystem) RemoveAll(ctx context.Context, name string, opts *RemoveAllOptions) error {
+	return fs.localError(fs.Client.removeAll(ctx, fs.remotePath(name), opts))
+}
+
+func (fs RemoteFileSystem) Mkdir(ctx context.Context, name string) error {
+	return fs.Client.Mkdir(ctx, fs.remotePath(name))
+}
+
+func (fs RemoteFileSystem) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	created, err = fs.Client.copy(ctx, fs.remotePath(src), fs.remotePath(dst), options)
+	return created, fs.localError(err)
+}
+
+func (fs RemoteFileSystem) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	created, err = fs.Client.move(ctx, fs.remotePath(src), fs.remotePath(dst), options)
+	return created, fs.localError(err)
+}
@@ -0,0 +1,93 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRemoteFileSystem(t *testing.T) {
+	mem := NewMemFileSystem()
+	if err := mem.Mkdir(context.Background(), "/dav"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	ts := httptest.NewServer(&Handler{FileSystem: mem})
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL+"/dav/")
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	h := &Handler{FileSystem: RemoteFileSystem{Client: c}}
+
+	res := doTestRequest(h, http.MethodPut, "/file.txt", "hello", nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	etag := res.Header.Get("ETag")
+
+	res = doTestRequest(h, http.MethodPut, "/file.txt", "world", map[string]string{"If-None-Match": "*"})
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with If-None-Match: status = %v, want %v", res.StatusCode, http.StatusPreconditionFailed)
+	}
+	res = doTestRequest(h, http.MethodPut, "/file.txt", "world", map[string]string{"If-Match": etag})
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("PUT with If-Match: status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+
+	if fi, err := mem.Stat(context.Background(), "/dav/file.txt"); err != nil || fi.Size != 5 {
+		t.Errorf("remote Stat() = %+v, %v", fi, err)
+	}
+
+	res = doTestRequest(h, "COPY", "/file.txt", "", map[string]string{"Destination": "/copy.txt"})
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("COPY: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	res = doTestRequest(h, "COPY", "/file.txt", "", map[string]string{"Destination": "/copy.txt"})
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("COPY overwrite: status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/", propFindAllProp, map[string]string{"Depth": "1"})
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "<href>/copy.txt</href>") || strings.Contains(body, "/dav/") {
+		t.Errorf("PROPFIND: body = %v", body)
+	}
+
+	res = doTestRequest(h, http.MethodGet, "/copy.txt", "", nil)
+	if body := readTestBody(t, res); body != "world" {
+		t.Errorf("GET: body = %q, want %q", body, "world")
+	}
+}
+
+func TestRemoteFileSystem_partialError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>/dav/dir/locked.txt</D:href>
+    <D:status>HTTP/1.1 423 Locked</D:status>
+  </D:response>
+</D:multistatus>`)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL+"/dav/")
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	h := &Handler{FileSystem: RemoteFileSystem{Client: c}}
+
+	for _, method := range []string{http.MethodDelete, "COPY", "MOVE"} {
+		res := doTestRequest(h, method, "/dir", "", map[string]string{"Destination": "/dest"})
+		body := readTestBody(t, res)
+		if res.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "<href>/dir/locked.txt</href>") || !strings.Contains(body, "423 Locked") {
+			t.Errorf("%v: status = %v, body = %v", method, res.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"path"
+)
+
+// Privileges defined in RFC 3744 section 3.
+var (
+	PrivilegeAll                         = xml.Name{Namespace, "all"}
+	PrivilegeRead                        = xml.Name{Namespace, "read"}
+	PrivilegeWrite                       = xml.Name{Namespace, "write"}
+	PrivilegeWriteProperties             = xml.Name{Namespace, "write-properties"}
+	PrivilegeWriteContent                = xml.Name{Namespace, "write-content"}
+	PrivilegeUnlock                      = xml.Name{Namespace, "unlock"}
+	PrivilegeReadACL                     = xml.Name{Namespace, "read-acl"}
+	PrivilegeReadCurrentUserPrivilegeSet = xml.Name{Namespace, "read-current-user-privilege-set"}
+	PrivilegeWriteACL                    = xml.Name{Namespace, "write-acl"}
+	PrivilegeBind                        = xml.Name{Namespace, "bind"}
+	PrivilegeUnbind                      = xml.Name{Namespace, "unbind"}
+)
+
+// privilegeAggregates describes the privileges contained by aggregate
+// privileges, as in the example of RFC 3744 section 3.12.
+var privilegeAggregates = map[xml.Name][]xml.Name{
+	PrivilegeAll: {
+		PrivilegeRead,
+		PrivilegeWrite,
+		PrivilegeUnlock,
+		PrivilegeReadACL,
+		PrivilegeReadCurrentUserPrivilegeSet,
+		PrivilegeWriteACL,
+	},
+	PrivilegeWrite: {
+		PrivilegeWriteProperties,
+		PrivilegeWriteContent,
+		PrivilegeBind,
+		PrivilegeUnbind,
+	},
+}
+
+// IsSupportedPrivilege checks whether a privilege is one of the privileges
+// defined in RFC 3744.
+func IsSupportedPrivilege(priv xml.Name) bool {
+	return PrivilegeContains(PrivilegeAll, priv)
+}
+
+// PrivilegeContains checks whether the privilege agg is priv or contains it.
+func PrivilegeContains(agg, priv xml.Name) bool {
+	if agg == priv {
+		return true
+	}
+	for _, child := range privilegeAggregates[agg] {
+		if PrivilegeContains(child, priv) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer checks whether a principal holds a privilege on a resource. An
+// empty principal designates an unauthenticated user.
+type Authorizer interface {
+	Authorize(ctx context.Context, principal, name string, priv xml.Name) error
+}
+
+// AuthorizeRequest checks whether a principal holds the privileges required
+// to perform a request, as listed in RFC 3744 appendix B. Creating a resource
+// requires other privileges than replacing it, so exists is called to check
+// whether the target of PUT, LOCK, COPY and MOVE requests is mapped.
+func AuthorizeRequest(r *http.Request, az Authorizer, principal string, exists func(name string) (bool, error)) error {
+	name := path.Clean(r.URL.Path)
+	parent := path.Dir(name)
+
+	type check struct {
+		name string
+		priv xml.Name
+	}
+	var checks []check
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, "PROPFIND", "REPORT":
+		checks = []check{{name, PrivilegeRead}}
+	case http.MethodPut, "LOCK":
+		ok, err := exists(name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			checks = []check{{name, PrivilegeWriteContent}}
+		} else {
+			checks = []check{{parent, PrivilegeBind}}
+		}
+	case "PROPPATCH":
+		checks = []check{{name, PrivilegeWriteProperties}}
+	case http.MethodDelete:
+		checks = []check{{parent, PrivilegeUnbind}}
+	case "MKCOL", "MKCALENDAR":
+		checks = []check{{parent, PrivilegeBind}}
+	case "COPY", "MOVE":
+		dest, err := parseDestination(r.Header)
+		if err != nil {
+			return err
+		}
+		destName := path.Clean(dest.Path)
+		destParent := path.Dir(destName)
+		if r.Method == "COPY" {
+			checks = []check{{name, PrivilegeRead}, {destParent, PrivilegeBind}}
+		} else {
+			checks = []check{{parent, PrivilegeUnbind}, {destParent, PrivilegeBind}}
+		}
+
+		// An existing destination is deleted before being replaced
+		overwrite := true
+		if s := r.Header.Get("Overwrite"); s != "" {
+			if overwrite, err = ParseOverwrite(s); err != nil {
+				return &HTTPError{Code: http.StatusBadRequest, Err: err}
+			}
+		}
+		if overwrite {
+			ok, err := exists(destName)
+			if err != nil {
+				return err
+			} else if ok {
+				checks = append(checks, check{destParent, PrivilegeUnbind})
+			}
+		}
+	case "UNLOCK":
+		checks = []check{{name, PrivilegeUnlock}}
+	case "ACL":
+		checks = []check{{name, PrivilegeWriteACL}}
+	}
+
+	for _, c := range checks {
+		if err := az.Authorize(r.Context(), principal, c.name, c.priv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentUserPrivileges returns the privileges held by a principal on a
+// resource.
+func CurrentUserPrivileges(ctx context.Context, az Authorizer, principal, name string) ([]xml.Name, error) {
+	var privs []xml.Name
+	var walk func(priv xml.Name) error
+	walk = func(priv xml.Name) error {
+		if err := az.Authorize(ctx, principal, name, priv); err == nil {
+			privs = append(privs, priv)
+		} else if !IsForbidden(err) {
+			return err
+		}
+		for _, child := range privilegeAggregates[priv] {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(PrivilegeAll); err != nil {
+		return nil, err
+	}
+	return privs, nil
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5
+type ACL struct {
+	XMLName xml.Name `xml:"DAV: acl"`
+	ACEs    []ACE    `xml:"ace"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5
+type ACE struct {
+	XMLName   xml.Name       `xml:"DAV: ace"`
+	Principal *ACEPrincipal  `xml:"principal,omitempty"`
+	Invert    *ACEInvert     `xml:"invert,omitempty"`
+	Grant     *ACEPrivileges `xml:"grant,omitempty"`
+	Deny      *ACEPrivileges `xml:"deny,omitempty"`
+	Protected *struct{}      `xml:"protected,omitempty"`
+	Inherited *ACEInherited  `xml:"inherited,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.1
+type ACEPrincipal struct {
+	XMLName         xml.Name     `xml:"DAV: principal"`
+	Href            *Href        `xml:"href,omitempty"`
+	All             *struct{}    `xml:"all,omitempty"`
+	Authenticated   *struct{}    `xml:"authenticated,omitempty"`
+	Unauthenticated *struct{}    `xml:"unauthenticated,omitempty"`
+	Property        *ACEProperty `xml:"property,omitempty"`
+	Self            *struct{}    `xml:"self,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.1
+type ACEProperty struct {
+	XMLName xml.Name      `xml:"DAV: property"`
+	Raw     []RawXMLValue `xml:",any"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.1
+type ACEInvert struct {
+	XMLName   xml.Name     `xml:"DAV: invert"`
+	Principal ACEPrincipal `xml:"principal"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.2
+type ACEPrivileges struct {
+	Privileges []Privilege `xml:"privilege"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.4
+type ACEInherited struct {
+	XMLName xml.Name `xml:"DAV: inherited"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.1
+type ACLOwner struct {
+	XMLName xml.Name `xml:"DAV: owner"`
+	Href    *Href    `xml:"href,omitempty"`
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type recordingAuthorizer []string
+
+func (az *recordingAuthorizer) Authorize(ctx context.Context, principal, name string, priv xml.Name) error {
+	*az = append(*az, priv.Local+" "+name)
+	return nil
+}
+
+func TestAuthorizeRequest(t *testing.T) {
+	mapped := map[string]bool{
+		"/dir/file.txt":  true,
+		"/dest/file.txt": true,
+	}
+	exists := func(name string) (bool, error) {
+		return mapped[name], nil
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		header       map[string]string
+		want         []string
+	}{
+		{"GET", "/dir/file.txt", nil, []string{"read /dir/file.txt"}},
+		{"PUT", "/dir/file.txt", nil, []string{"write-content /dir/file.txt"}},
+		{"PUT", "/dir/new.txt", nil, []string{"bind /dir"}},
+		{"LOCK", "/dir/new.txt", nil, []string{"bind /dir"}},
+		{"DELETE", "/dir/file.txt", nil, []string{"unbind /dir"}},
+		{"COPY", "/dir/file.txt", map[string]string{"Destination": "/dest/new.txt"}, []string{"read /dir/file.txt", "bind /dest"}},
+		{"COPY", "/dir/file.txt", map[string]string{"Destination": "/dest/file.txt"}, []string{"read /dir/file.txt", "bind /dest", "unbind /dest"}},
+		{"COPY", "/dir/file.txt", map[string]string{"Destination": "/dest/file.txt", "Overwrite": "F"}, []string{"read /dir/file.txt", "bind /dest"}},
+		{"MOVE", "/dir/file.txt", map[string]string{"Destination": "/dest/file.txt"}, []string{"unbind /dir", "bind /dest", "unbind /dest"}},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+		var az recordingAuthorizer
+		if err := AuthorizeRequest(req, &az, "", exists); err != nil {
+			t.Errorf("AuthorizeRequest(%v %v) = %v", tc.method, tc.path, err)
+		} else if !reflect.DeepEqual([]string(az), tc.want) {
+			t.Errorf("AuthorizeRequest(%v %v %v) checked %q, want %q", tc.method, tc.path, tc.header, az, tc.want)
+		}
+	}
+}
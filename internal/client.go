@@ -91,6 +91,7 @@ type HTTPClient interface {
 type Client struct {
 	http     HTTPClient
 	endpoint *url.URL
+	retry    *RetryPolicy
 }
 
 func NewClient(c HTTPClient, endpoint string) (*Client, error) {
@@ -109,6 +110,12 @@ func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 	return &Client{http: c, endpoint: u}, nil
 }
 
+// SetRetryPolicy sets the policy used to retry failed requests. A nil policy
+// disables retries.
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.retry = policy
+}
+
 func (c *Client) ResolveHref(p string) *url.URL {
 	if !strings.HasPrefix(p, "/") {
 		p = path.Join(c.endpoint.Path, p)
@@ -143,7 +150,7 @@ func (c *Client) NewXMLRequest(method string, path string, v interface{}) (*http
 }
 
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	resp, err := c.http.Do(req)
+	resp, err := c.doRetry(req)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +188,60 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+// doRetry sends a request, sending it again according to the retry policy.
+func (c *Client) doRetry(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.http.Do(req)
+		delay, ok := c.retry.retryDelay(req, resp, err, attempt)
+		if !ok {
+			return resp, err
+		}
+
+		body, bodyErr := rewindBody(req)
+		if bodyErr != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+}
+
+func rewindBody(req *http.Request) (io.ReadCloser, error) {
+	if req.GetBody == nil {
+		return req.Body, nil
+	}
+	return req.GetBody()
+}
+
 func (c *Client) DoMultiStatus(req *http.Request) (*MultiStatus, error) {
+	var resps []Response
+	ms, err := c.DoMultiStatusFunc(req, func(resp *Response) error {
+		resps = append(resps, *resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ms.Responses = resps
+	return ms, nil
+}
+
+// DoMultiStatusFunc performs a request which returns a multi-status response.
+// Response elements are decoded one at a time and passed to fn, so that large
+// responses don't need to be held in memory. If fn returns an error, decoding
+// stops and the error is returned.
+//
+// The returned MultiStatus contains the remaining fields of the response,
+// without any Responses.
+func (c *Client) DoMultiStatusFunc(req *http.Request, fn func(resp *Response) error) (*MultiStatus, error) {
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
@@ -192,16 +252,88 @@ func (c *Client) DoMultiStatus(req *http.Request) (*MultiStatus, error) {
 		return nil, fmt.Errorf("HTTP multi-status request failed: %v", resp.Status)
 	}
 
-	// TODO: the response can be quite large, support streaming Response elements
+	return decodeMultiStatus(xml.NewDecoder(resp.Body), fn)
+}
+
+// MultiStatusError decodes the body of a 207 Multi-Status response, which
+// lists the resources a request failed to process, and joins their errors
+// with errors.Join. It returns nil for other responses.
+func MultiStatusError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil
+	}
+
+	var errs []error
+	_, err := decodeMultiStatus(xml.NewDecoder(resp.Body), func(resp *Response) error {
+		if err := resp.Err(); err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	multiStatusName         = xml.Name{Namespace, "multistatus"}
+	responseName            = xml.Name{Namespace, "response"}
+	responseDescriptionName = xml.Name{Namespace, "responsedescription"}
+)
+
+func decodeMultiStatus(d *xml.Decoder, fn func(resp *Response) error) (*MultiStatus, error) {
 	var ms MultiStatus
-	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
-		return nil, err
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("webdav: missing multistatus element in response")
+		} else if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name != multiStatusName {
+				return nil, fmt.Errorf("webdav: expected multistatus element in response, got <%v>", start.Name.Local)
+			}
+			ms.XMLName = start.Name
+			break
+		}
 	}
 
-	return &ms, nil
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name {
+			case responseName:
+				var resp Response
+				if err := d.DecodeElement(&resp, &tok); err != nil {
+					return nil, err
+				}
+				if err := fn(&resp); err != nil {
+					return nil, err
+				}
+			case responseDescriptionName:
+				err = d.DecodeElement(&ms.ResponseDescription, &tok)
+			case SyncTokenName:
+				err = d.DecodeElement(&ms.SyncToken, &tok)
+			default:
+				err = d.Skip()
+			}
+			if err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			return &ms, nil
+		}
+	}
 }
 
-func (c *Client) PropFind(ctx context.Context, path string, depth Depth, propfind *PropFind) (*MultiStatus, error) {
+func (c *Client) NewPropFindRequest(path string, depth Depth, propfind *PropFind) (*http.Request, error) {
 	req, err := c.NewXMLRequest("PROPFIND", path, propfind)
 	if err != nil {
 		return nil, err
@@ -209,6 +341,15 @@ func (c *Client) PropFind(ctx context.Context, path string, depth Depth, propfin
 
 	req.Header.Add("Depth", depth.String())
 
+	return req, nil
+}
+
+func (c *Client) PropFind(ctx context.Context, path string, depth Depth, propfind *PropFind) (*MultiStatus, error) {
+	req, err := c.NewPropFindRequest(path, depth, propfind)
+	if err != nil {
+		return nil, err
+	}
+
 	return c.DoMultiStatus(req.WithContext(ctx))
 }
 
@@ -267,14 +408,7 @@ func (c *Client) Options(ctx context.Context, path string) (classes map[string]b
 
 // SyncCollection perform a `sync-collection` REPORT operation on a resource
 func (c *Client) SyncCollection(ctx context.Context, path, syncToken string, level Depth, limit *Limit, prop *Prop) (*MultiStatus, error) {
-	q := SyncCollectionQuery{
-		SyncToken: syncToken,
-		SyncLevel: level.String(),
-		Limit:     limit,
-		Prop:      prop,
-	}
-
-	req, err := c.NewXMLRequest("REPORT", path, &q)
+	req, err := c.newSyncCollectionRequest(path, syncToken, level, limit, prop)
 	if err != nil {
 		return nil, err
 	}
@@ -286,3 +420,32 @@ func (c *Client) SyncCollection(ctx context.Context, path, syncToken string, lev
 
 	return ms, nil
 }
+
+// SyncCollectionFunc is like SyncCollection, but calls fn for each response
+// element instead of collecting them. See DoMultiStatusFunc.
+func (c *Client) SyncCollectionFunc(ctx context.Context, path, syncToken string, level Depth, limit *Limit, prop *Prop, fn func(resp *Response) error) (*MultiStatus, error) {
+	req, err := c.newSyncCollectionRequest(path, syncToken, level, limit, prop)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.DoMultiStatusFunc(req.WithContext(ctx), fn)
+}
+
+func (c *Client) newSyncCollectionRequest(path, syncToken string, level Depth, limit *Limit, prop *Prop) (*http.Request, error) {
+	// RFC 6578 spells the infinite sync level differently from the Depth
+	// header field
+	syncLevel := level.String()
+	if level == DepthInfinity {
+		syncLevel = "infinite"
+	}
+
+	q := SyncCollectionQuery{
+		SyncToken: syncToken,
+		SyncLevel: syncLevel,
+		Limit:     limit,
+		Prop:      prop,
+	}
+
+	return c.NewXMLRequest("REPORT", path, &q)
+}
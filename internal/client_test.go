@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMultiStatus = `<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>/a</D:href>
+    <D:status>HTTP/1.1 200 OK</D:status>
+  </D:response>
+  <D:unknown><D:foo/></D:unknown>
+  <D:response>
+    <D:href>/b</D:href>
+    <D:status>HTTP/1.1 404 Not Found</D:status>
+  </D:response>
+  <D:sync-token>http://example.com/ns/sync/1234</D:sync-token>
+</D:multistatus>`
+
+func TestDecodeMultiStatus(t *testing.T) {
+	var hrefs []string
+	ms, err := decodeMultiStatus(xml.NewDecoder(strings.NewReader(testMultiStatus)), func(resp *Response) error {
+		hrefs = append(hrefs, resp.Hrefs[0].Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decodeMultiStatus() = %v", err)
+	}
+	if got := strings.Join(hrefs, " "); got != "/a /b" {
+		t.Errorf("decodeMultiStatus() responses = %v, want %v", got, "/a /b")
+	}
+	if ms.SyncToken != "http://example.com/ns/sync/1234" {
+		t.Errorf("decodeMultiStatus() sync-token = %q", ms.SyncToken)
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	_, err = decodeMultiStatus(xml.NewDecoder(strings.NewReader(testMultiStatus)), func(resp *Response) error {
+		n++
+		return errStop
+	})
+	if err != errStop || n != 1 {
+		t.Errorf("decodeMultiStatus() = %v after %v responses, want %v after 1", err, n, errStop)
+	}
+}
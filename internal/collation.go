@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Collations used by text matches in CalDAV and CardDAV filters, defined in
+// RFC 4790 and RFC 5051.
+const (
+	CollationOctet          = "i;octet"
+	CollationASCIICaseMap   = "i;ascii-casemap"
+	CollationUnicodeCaseMap = "i;unicode-casemap"
+)
+
+// IsSupportedCollation reports whether a collation is supported by
+// FoldCollation.
+func IsSupportedCollation(collation string) bool {
+	switch collation {
+	case CollationOctet, CollationASCIICaseMap, CollationUnicodeCaseMap:
+		return true
+	default:
+		return false
+	}
+}
+
+// FoldCollation returns the canonical form of s for a collation: two strings
+// are equal under the collation if and only if their canonical forms are
+// equal. Since characters are mapped one by one, substrings are preserved as
+// well. ok is false if the collation isn't supported.
+func FoldCollation(collation, s string) (folded string, ok bool) {
+	switch collation {
+	case CollationOctet:
+		return s, true
+	case CollationASCIICaseMap:
+		return strings.Map(func(r rune) rune {
+			if 'a' <= r && r <= 'z' {
+				return r - 'a' + 'A'
+			}
+			return r
+		}, s), true
+	case CollationUnicodeCaseMap:
+		// RFC 5051 section 2: each character is mapped to its titlecase,
+		// then to its compatibility decomposition
+		var sb strings.Builder
+		for _, r := range s {
+			sb.WriteString(norm.NFKD.String(string(unicode.ToTitle(r))))
+		}
+		return sb.String(), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"testing"
+)
+
+var foldCollationTests = []struct {
+	collation string
+	a, b      string
+	equal     bool
+}{
+	{CollationOctet, "Hello", "Hello", true},
+	{CollationOctet, "Hello", "hello", false},
+	{CollationASCIICaseMap, "Hello", "hELLO", true},
+	{CollationASCIICaseMap, "Été", "éTÉ", false},
+	{CollationUnicodeCaseMap, "Été", "éTÉ", true},
+	{CollationUnicodeCaseMap, "Straße", "STRASSE", false},
+	{CollationUnicodeCaseMap, "Ǆemal", "ǆemal", true},
+	{CollationUnicodeCaseMap, "Ångström", "A\u030angstro\u0308m", true},
+	{CollationUnicodeCaseMap, "①", "1", true},
+}
+
+func TestFoldCollation(t *testing.T) {
+	for _, tc := range foldCollationTests {
+		a, ok := FoldCollation(tc.collation, tc.a)
+		if !ok {
+			t.Fatalf("FoldCollation(%q) = unsupported", tc.collation)
+		}
+		b, _ := FoldCollation(tc.collation, tc.b)
+		if equal := a == b; equal != tc.equal {
+			t.Errorf("FoldCollation(%q): %q == %q is %v, want %v", tc.collation, tc.a, tc.b, equal, tc.equal)
+		}
+	}
+
+	if _, ok := FoldCollation("i;basic", "Hello"); ok {
+		t.Errorf("FoldCollation(\"i;basic\") = supported")
+	}
+	if IsSupportedCollation("i;basic") || !IsSupportedCollation(CollationOctet) {
+		t.Errorf("IsSupportedCollation() returned wrong results")
+	}
+}
@@ -21,11 +21,29 @@ var (
 	GetLastModifiedName  = xml.Name{Namespace, "getlastmodified"}
 	GetETagName          = xml.Name{Namespace, "getetag"}
 
+	QuotaAvailableBytesName = xml.Name{Namespace, "quota-available-bytes"}
+	QuotaUsedBytesName      = xml.Name{Namespace, "quota-used-bytes"}
+
+	SyncTokenName                   = xml.Name{Namespace, "sync-token"}
+	SyncCollectionName              = xml.Name{Namespace, "sync-collection"}
+	ValidSyncTokenName              = xml.Name{Namespace, "valid-sync-token"}
+	SupportedReportName             = xml.Name{Namespace, "supported-report"}
+	SupportedReportSetName          = xml.Name{Namespace, "supported-report-set"}
+	NumberOfMatchesWithinLimitsName = xml.Name{Namespace, "number-of-matches-within-limits"}
+
 	CollectionName = xml.Name{Namespace, "collection"}
 	PrincipalName  = xml.Name{Namespace, "principal"}
 
 	CurrentUserPrincipalName    = xml.Name{Namespace, "current-user-principal"}
 	CurrentUserPrivilegeSetName = xml.Name{Namespace, "current-user-privilege-set"}
+	ACLName                     = xml.Name{Namespace, "acl"}
+	OwnerName                   = xml.Name{Namespace, "owner"}
+
+	LockDiscoveryName = xml.Name{Namespace, "lockdiscovery"}
+	SupportedLockName = xml.Name{Namespace, "supportedlock"}
+
+	SetName    = xml.Name{Namespace, "set"}
+	RemoveName = xml.Name{Namespace, "remove"}
 )
 
 type Status struct {
@@ -382,6 +400,48 @@ type GetETag struct {
 	ETag    ETag     `xml:",chardata"`
 }
 
+// https://tools.ietf.org/html/rfc4331#section-3
+type QuotaAvailableBytes struct {
+	XMLName xml.Name `xml:"DAV: quota-available-bytes"`
+	Bytes   int64    `xml:",chardata"`
+}
+
+// https://tools.ietf.org/html/rfc4331#section-4
+type QuotaUsedBytes struct {
+	XMLName xml.Name `xml:"DAV: quota-used-bytes"`
+	Bytes   int64    `xml:",chardata"`
+}
+
+// https://tools.ietf.org/html/rfc6578#section-4
+type SyncToken struct {
+	XMLName xml.Name `xml:"DAV: sync-token"`
+	Token   string   `xml:",chardata"`
+}
+
+// https://tools.ietf.org/html/rfc3253#section-3.1.5
+type SupportedReportSet struct {
+	XMLName         xml.Name          `xml:"DAV: supported-report-set"`
+	SupportedReport []SupportedReport `xml:"supported-report"`
+}
+
+type SupportedReport struct {
+	Report Report `xml:"DAV: report"`
+}
+
+type Report struct {
+	Raw []RawXMLValue `xml:",any"`
+}
+
+// NewSupportedReportSet creates a supported-report-set listing the specified
+// report root elements.
+func NewSupportedReportSet(names ...xml.Name) *SupportedReportSet {
+	l := make([]SupportedReport, len(names))
+	for i, name := range names {
+		l[i].Report.Raw = []RawXMLValue{*NewRawXMLElement(name, nil, nil)}
+	}
+	return &SupportedReportSet{SupportedReport: l}
+}
+
 type ETag string
 
 func (etag *ETag) UnmarshalText(b []byte) error {
@@ -431,20 +491,17 @@ type CurrentUserPrincipal struct {
 // https://tools.ietf.org/html/rfc4918#section-14.19
 type PropertyUpdate struct {
 	XMLName xml.Name `xml:"DAV: propertyupdate"`
-	Remove  []Remove `xml:"remove"`
-	Set     []Set    `xml:"set"`
+	// Instructions are applied in document order, see RFC 4918 section 9.2.
+	Instructions []PropertyUpdateInstruction `xml:",any"`
 }
 
+// PropertyUpdateInstruction is a set or remove element.
+//
 // https://tools.ietf.org/html/rfc4918#section-14.23
-type Remove struct {
-	XMLName xml.Name `xml:"DAV: remove"`
-	Prop    Prop     `xml:"prop"`
-}
-
 // https://tools.ietf.org/html/rfc4918#section-14.26
-type Set struct {
-	XMLName xml.Name `xml:"DAV: set"`
-	Prop    Prop     `xml:"prop"`
+type PropertyUpdateInstruction struct {
+	XMLName xml.Name
+	Prop    Prop `xml:"prop"`
 }
 
 // https://tools.ietf.org/html/rfc6578#section-6.1
@@ -456,6 +513,19 @@ type SyncCollectionQuery struct {
 	Prop      *Prop    `xml:"prop"`
 }
 
+// Recursive reports whether the report applies to all members of the
+// collection, rather than its internal members only.
+func (q *SyncCollectionQuery) Recursive() (bool, error) {
+	switch q.SyncLevel {
+	case "1":
+		return false, nil
+	case "infinite":
+		return true, nil
+	default:
+		return false, HTTPErrorf(http.StatusBadRequest, "webdav: invalid sync-level %q", q.SyncLevel)
+	}
+}
+
 // https://tools.ietf.org/html/rfc5323#section-5.17
 type Limit struct {
 	XMLName  xml.Name `xml:"DAV: limit"`
@@ -468,9 +538,142 @@ type CurrentUserPrivilegeSet struct {
 	Privilege []Privilege
 }
 
+// NewCurrentUserPrivilegeSet creates a new current-user-privilege-set
+// property.
+func NewCurrentUserPrivilegeSet(privs ...xml.Name) *CurrentUserPrivilegeSet {
+	l := make([]Privilege, len(privs))
+	for i, priv := range privs {
+		l[i] = *NewPrivilege(priv)
+	}
+	return &CurrentUserPrivilegeSet{Privilege: l}
+}
+
 // https://tools.ietf.org/html/rfc3744#section-5.4
 type Privilege struct {
-	XMLName xml.Name  `xml:"DAV: privilege"`
-	Read    *struct{} `xml:"DAV: read,omitempty"`
-	Write   *struct{} `xml:"DAV: write,omitempty"`
+	XMLName xml.Name      `xml:"DAV: privilege"`
+	Raw     []RawXMLValue `xml:",any"`
+}
+
+// NewPrivilege creates a new privilege element.
+func NewPrivilege(name xml.Name) *Privilege {
+	return &Privilege{Raw: []RawXMLValue{*NewRawXMLElement(name, nil, nil)}}
+}
+
+// Name returns the name of the privilege.
+func (p *Privilege) Name() (name xml.Name, ok bool) {
+	if len(p.Raw) != 1 {
+		return xml.Name{}, false
+	}
+	return p.Raw[0].XMLName()
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.11
+type LockInfo struct {
+	XMLName   xml.Name  `xml:"DAV: lockinfo"`
+	LockScope LockScope `xml:"lockscope"`
+	LockType  LockType  `xml:"locktype"`
+	Owner     *Owner    `xml:"owner,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.13
+type LockScope struct {
+	XMLName   xml.Name  `xml:"DAV: lockscope"`
+	Exclusive *struct{} `xml:"exclusive,omitempty"`
+	Shared    *struct{} `xml:"shared,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.15
+type LockType struct {
+	XMLName xml.Name  `xml:"DAV: locktype"`
+	Write   *struct{} `xml:"write,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.17
+type Owner struct {
+	XMLName  xml.Name `xml:"DAV: owner"`
+	InnerXML string   `xml:",innerxml"`
+}
+
+// UnmarshalXML implements xml.Unmarshaler. The contents of the element are
+// re-encoded so that InnerXML doesn't depend on namespace prefixes declared
+// in the request.
+func (o *Owner) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw RawXMLValue
+	if err := raw.UnmarshalXML(d, start); err != nil {
+		return err
+	}
+	inner, err := raw.InnerXML()
+	if err != nil {
+		return err
+	}
+
+	o.XMLName = start.Name
+	o.InnerXML = inner
+	return nil
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.1
+type ActiveLock struct {
+	XMLName   xml.Name   `xml:"DAV: activelock"`
+	LockScope LockScope  `xml:"lockscope"`
+	LockType  LockType   `xml:"locktype"`
+	Depth     Depth      `xml:"depth"`
+	Owner     *Owner     `xml:"owner,omitempty"`
+	Timeout   Timeout    `xml:"timeout"`
+	LockToken *LockToken `xml:"locktoken,omitempty"`
+	LockRoot  LockRoot   `xml:"lockroot"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.14
+type LockToken struct {
+	XMLName xml.Name `xml:"DAV: locktoken"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.12
+type LockRoot struct {
+	XMLName xml.Name `xml:"DAV: lockroot"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-15.8
+type LockDiscovery struct {
+	XMLName     xml.Name     `xml:"DAV: lockdiscovery"`
+	ActiveLocks []ActiveLock `xml:"activelock"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-15.10
+type SupportedLock struct {
+	XMLName     xml.Name    `xml:"DAV: supportedlock"`
+	LockEntries []LockEntry `xml:"lockentry"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.10
+type LockEntry struct {
+	XMLName   xml.Name  `xml:"DAV: lockentry"`
+	LockScope LockScope `xml:"lockscope"`
+	LockType  LockType  `xml:"locktype"`
+}
+
+// NewLockTokenSubmittedError creates a DAV:lock-token-submitted error for the
+// provided lock roots.
+func NewLockTokenSubmittedError(roots ...string) error {
+	children := make([]RawXMLValue, len(roots))
+	for i, root := range roots {
+		href := Href{Path: root}
+		children[i] = *NewRawXMLElement(xml.Name{Namespace, "href"}, nil, []RawXMLValue{
+			{tok: xml.CharData(href.String())},
+		})
+	}
+	return NewConditionError(http.StatusLocked, xml.Name{Namespace, "lock-token-submitted"}, children...)
+}
+
+// NewConditionError creates an error carrying a precondition or postcondition
+// element, as defined in RFC 4918 section 16.
+func NewConditionError(code int, name xml.Name, children ...RawXMLValue) error {
+	elt := NewRawXMLElement(name, nil, children)
+	return &HTTPError{
+		Code: code,
+		Err:  &Error{Raw: []RawXMLValue{*elt}},
+	}
 }
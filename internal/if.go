@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IfList is a list of conditions in an If header, as defined in RFC 4918
+// section 10.4. All conditions of a list need to be true for the list to
+// match.
+type IfList struct {
+	// Resource is the resource tag, if any. An empty string means the list
+	// applies to the request URI.
+	Resource   string
+	Conditions []IfCondition
+}
+
+// IfCondition is a single condition in an If header list. Exactly one of
+// Token or ETag is set.
+type IfCondition struct {
+	Not   bool
+	Token string
+	ETag  string
+}
+
+// ParseIf parses an If header.
+func ParseIf(s string) ([]IfList, error) {
+	p := ifParser{s: s}
+	var lists []IfList
+	var resource string
+	tagged := false
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		switch p.peek() {
+		case '<':
+			if len(lists) > 0 && !tagged {
+				return nil, fmt.Errorf("webdav: malformed If header: mixed tagged and untagged lists")
+			}
+			tag, err := p.readDelimited('<', '>')
+			if err != nil {
+				return nil, err
+			}
+			resource = tag
+			tagged = true
+			p.skipSpace()
+			if p.eof() || p.peek() != '(' {
+				return nil, fmt.Errorf("webdav: malformed If header: expected list after resource tag")
+			}
+		case '(':
+			conds, err := p.readList()
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, IfList{Resource: resource, Conditions: conds})
+		default:
+			return nil, fmt.Errorf("webdav: malformed If header: unexpected character %q", p.peek())
+		}
+	}
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("webdav: malformed If header: no list")
+	}
+	return lists, nil
+}
+
+type ifParser struct {
+	s string
+}
+
+func (p *ifParser) eof() bool {
+	return len(p.s) == 0
+}
+
+func (p *ifParser) peek() byte {
+	return p.s[0]
+}
+
+func (p *ifParser) skipSpace() {
+	p.s = strings.TrimLeft(p.s, " \t\r\n")
+}
+
+func (p *ifParser) readDelimited(start, end byte) (string, error) {
+	if p.eof() || p.peek() != start {
+		return "", fmt.Errorf("webdav: malformed If header: expected %q", start)
+	}
+	i := strings.IndexByte(p.s, end)
+	if i < 0 {
+		return "", fmt.Errorf("webdav: malformed If header: missing %q", end)
+	}
+	v := p.s[1:i]
+	p.s = p.s[i+1:]
+	return v, nil
+}
+
+func (p *ifParser) readList() ([]IfCondition, error) {
+	p.s = p.s[1:] // skip "("
+	var conds []IfCondition
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, fmt.Errorf("webdav: malformed If header: unterminated list")
+		}
+		if p.peek() == ')' {
+			p.s = p.s[1:]
+			break
+		}
+
+		var cond IfCondition
+		if strings.HasPrefix(p.s, "Not") {
+			cond.Not = true
+			p.s = p.s[len("Not"):]
+			p.skipSpace()
+			if p.eof() {
+				return nil, fmt.Errorf("webdav: malformed If header: unterminated list")
+			}
+		}
+
+		var err error
+		switch p.peek() {
+		case '<':
+			cond.Token, err = p.readDelimited('<', '>')
+		case '[':
+			var etag string
+			etag, err = p.readDelimited('[', ']')
+			if err == nil {
+				var e ETag
+				err = e.UnmarshalText([]byte(strings.TrimPrefix(etag, "W/")))
+				cond.ETag = string(e)
+			}
+		default:
+			err = fmt.Errorf("webdav: malformed If header: unexpected character %q", p.peek())
+		}
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("webdav: malformed If header: empty list")
+	}
+	return conds, nil
+}
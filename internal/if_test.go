@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseIfTests = []struct {
+	name  string
+	input string
+	want  []IfList
+}{
+	{
+		name:  "token",
+		input: `(<urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2>)`,
+		want: []IfList{{Conditions: []IfCondition{
+			{Token: "urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2"},
+		}}},
+	},
+	{
+		name:  "token and etag",
+		input: `(<urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2> ["I am an ETag"]) (["I am another ETag"])`,
+		want: []IfList{
+			{Conditions: []IfCondition{
+				{Token: "urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2"},
+				{ETag: "I am an ETag"},
+			}},
+			{Conditions: []IfCondition{
+				{ETag: "I am another ETag"},
+			}},
+		},
+	},
+	{
+		name:  "not",
+		input: `(Not <urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2> <urn:uuid:58f202ac-22cf-11d1-b12d-002035b29092>)`,
+		want: []IfList{{Conditions: []IfCondition{
+			{Not: true, Token: "urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2"},
+			{Token: "urn:uuid:58f202ac-22cf-11d1-b12d-002035b29092"},
+		}}},
+	},
+	{
+		name:  "tagged",
+		input: `</resource1> (<urn:uuid:a> [W/"A weak ETag"]) (["strong ETag"]) </resource2> (<urn:uuid:b>)`,
+		want: []IfList{
+			{Resource: "/resource1", Conditions: []IfCondition{
+				{Token: "urn:uuid:a"},
+				{ETag: "A weak ETag"},
+			}},
+			{Resource: "/resource1", Conditions: []IfCondition{
+				{ETag: "strong ETag"},
+			}},
+			{Resource: "/resource2", Conditions: []IfCondition{
+				{Token: "urn:uuid:b"},
+			}},
+		},
+	},
+}
+
+func TestParseIf(t *testing.T) {
+	for _, tc := range parseIfTests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseIf(tc.input)
+			if err != nil {
+				t.Fatalf("ParseIf() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseIf() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseIf_invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"()",
+		"(<urn:uuid:a>",
+		"<urn:uuid:a>",
+		"(<urn:uuid:a>) </resource> (<urn:uuid:b>)",
+		"(foo)",
+	} {
+		if _, err := ParseIf(s); err == nil {
+			t.Errorf("ParseIf(%q) = nil, want an error", s)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Depth indicates whether a request applies to the resource's members. It's
@@ -49,6 +52,72 @@ func (d Depth) String() string {
 	panic("webdav: invalid Depth value")
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (d Depth) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Depth) UnmarshalText(b []byte) error {
+	v, err := ParseDepth(string(b))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Timeout is a lock timeout, as defined in RFC 4918 section 10.7. A zero
+// Timeout means "Infinite".
+type Timeout time.Duration
+
+// ParseTimeout parses a Timeout header. The first supported value is
+// returned.
+func ParseTimeout(s string) (Timeout, error) {
+	for _, v := range strings.Split(s, ",") {
+		var t Timeout
+		if err := t.UnmarshalText([]byte(strings.TrimSpace(v))); err == nil {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("webdav: invalid Timeout value")
+}
+
+// String formats the timeout.
+func (t Timeout) String() string {
+	if t <= 0 {
+		return "Infinite"
+	}
+	secs := int64(time.Duration(t) / time.Second)
+	if time.Duration(t)%time.Second != 0 {
+		secs++
+	}
+	return fmt.Sprintf("Second-%d", secs)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t Timeout) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *Timeout) UnmarshalText(b []byte) error {
+	s := string(b)
+	if s == "Infinite" {
+		*t = 0
+		return nil
+	}
+	if !strings.HasPrefix(s, "Second-") {
+		return fmt.Errorf("webdav: invalid Timeout value %q", s)
+	}
+	secs, err := strconv.ParseUint(strings.TrimPrefix(s, "Second-"), 10, 32)
+	if err != nil || secs == 0 {
+		return fmt.Errorf("webdav: invalid Timeout value %q", s)
+	}
+	*t = Timeout(time.Duration(secs) * time.Second)
+	return nil
+}
+
 // ParseOverwrite parses an Overwrite header.
 func ParseOverwrite(s string) (bool, error) {
 	switch s {
@@ -93,6 +162,16 @@ func IsNotFound(err error) bool {
 	return false
 }
 
+// IsForbidden checks whether an error denies access to a resource, with a
+// 401 or 403 status code.
+func IsForbidden(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == http.StatusForbidden || httpErr.Code == http.StatusUnauthorized
+	}
+	return false
+}
+
 func HTTPErrorf(code int, format string, a ...interface{}) *HTTPError {
 	return &HTTPError{code, fmt.Errorf(format, a...)}
 }
@@ -122,3 +201,25 @@ func (err *HrefError) Error() string {
 func (err *HrefError) Unwrap() error {
 	return err.Err
 }
+
+// HrefErrors extracts the per-resource errors from an error, as created by
+// errors.Join. It returns false if err contains errors not associated with a
+// resource.
+func HrefErrors(err error) ([]*HrefError, bool) {
+	switch err := err.(type) {
+	case *HrefError:
+		return []*HrefError{err}, true
+	case interface{ Unwrap() []error }:
+		var l []*HrefError
+		for _, e := range err.Unwrap() {
+			hrefErrs, ok := HrefErrors(e)
+			if !ok {
+				return nil, false
+			}
+			l = append(l, hrefErrs...)
+		}
+		return l, len(l) > 0
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContentRange is the value of a Content-Range header, as defined in RFC 9110
+// section 14.4, used for partial PUT requests.
+type ContentRange struct {
+	// First and Last are the positions of the first and last bytes of the
+	// range. Both are -1 for an unsatisfied range ("bytes */length").
+	First, Last int64
+	// Length is the complete length of the representation, or -1 if
+	// unknown.
+	Length int64
+}
+
+// ParseContentRange parses a Content-Range header.
+func ParseContentRange(s string) (*ContentRange, error) {
+	rest := strings.TrimPrefix(s, "bytes ")
+	if rest == s {
+		return nil, fmt.Errorf("webdav: malformed Content-Range: expected bytes unit")
+	}
+
+	i := strings.IndexByte(rest, '/')
+	if i < 0 {
+		return nil, fmt.Errorf("webdav: malformed Content-Range: missing complete length")
+	}
+	rng, length := rest[:i], rest[i+1:]
+
+	cr := ContentRange{First: -1, Last: -1, Length: -1}
+	if length != "*" {
+		var err error
+		if cr.Length, err = parseRangeInt(length); err != nil {
+			return nil, err
+		}
+	}
+
+	if rng == "*" {
+		if cr.Length < 0 {
+			return nil, fmt.Errorf("webdav: malformed Content-Range: missing range")
+		}
+		return &cr, nil
+	}
+
+	i = strings.IndexByte(rng, '-')
+	if i < 0 {
+		return nil, fmt.Errorf("webdav: malformed Content-Range: invalid range")
+	}
+	var err error
+	if cr.First, err = parseRangeInt(rng[:i]); err != nil {
+		return nil, err
+	}
+	if cr.Last, err = parseRangeInt(rng[i+1:]); err != nil {
+		return nil, err
+	}
+	if cr.Last < cr.First || (cr.Length >= 0 && cr.Last >= cr.Length) {
+		return nil, fmt.Errorf("webdav: malformed Content-Range: invalid range")
+	}
+	return &cr, nil
+}
+
+func parseRangeInt(s string) (int64, error) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, fmt.Errorf("webdav: malformed Content-Range: invalid integer %q", s)
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("webdav: malformed Content-Range: %v", err)
+	}
+	return v, nil
+}
+
+// String formats the Content-Range header.
+func (cr *ContentRange) String() string {
+	rng := "*"
+	if cr.First >= 0 {
+		rng = fmt.Sprintf("%v-%v", cr.First, cr.Last)
+	}
+	length := "*"
+	if cr.Length >= 0 {
+		length = strconv.FormatInt(cr.Length, 10)
+	}
+	return "bytes " + rng + "/" + length
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseContentRangeTests = []struct {
+	input string
+	want  ContentRange
+}{
+	{"bytes 0-99/1000", ContentRange{First: 0, Last: 99, Length: 1000}},
+	{"bytes 100-199/*", ContentRange{First: 100, Last: 199, Length: -1}},
+	{"bytes */1000", ContentRange{First: -1, Last: -1, Length: 1000}},
+}
+
+func TestParseContentRange(t *testing.T) {
+	for _, tc := range parseContentRangeTests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseContentRange(tc.input)
+			if err != nil {
+				t.Fatalf("ParseContentRange() = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("ParseContentRange() = %#v, want %#v", *got, tc.want)
+			}
+			if s := got.String(); s != tc.input {
+				t.Errorf("String() = %q, want %q", s, tc.input)
+			}
+		})
+	}
+}
+
+func TestParseContentRange_invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"0-99/1000",
+		"bytes 0-99",
+		"bytes */*",
+		"bytes 99-0/1000",
+		"bytes 0-1000/1000",
+		"bytes -1-99/1000",
+		"bytes 0-+99/1000",
+	} {
+		if _, err := ParseContentRange(s); err == nil {
+			t.Errorf("ParseContentRange(%q) = nil, want an error", s)
+		}
+	}
+}
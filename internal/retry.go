@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how failed requests are retried. It's exposed as
+// webdav.RetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent,
+	// including the first attempt.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It defaults to
+	// 500 milliseconds.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between two attempts. It defaults to 30
+	// seconds.
+	MaxBackoff time.Duration
+}
+
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// idempotentMethods lists the methods which can be safely sent again after a
+// network error, as defined in RFC 7231 section 4.2.2 and RFC 4918.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	"PROPFIND":         true,
+	"PROPPATCH":        true,
+	"REPORT":           true,
+}
+
+// retryDelay returns how long to wait before sending a request again, or
+// false if the request shouldn't be sent again. attempt is the number of
+// attempts made so far.
+func (p *RetryPolicy) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+	if err != nil && req.Context().Err() != nil {
+		return 0, false
+	}
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			// The server hasn't processed the request
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			if !idempotentMethods[req.Method] {
+				return 0, false
+			}
+		default:
+			return 0, false
+		}
+
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if d > maxBackoff {
+				return 0, false
+			}
+			return d, true
+		}
+	} else if !idempotentMethods[req.Method] {
+		return 0, false
+	}
+
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	// Add jitter so that clients don't all retry at the same time
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	return backoff, true
+}
+
+// parseRetryAfter parses a Retry-After header field, defined in RFC 7231
+// section 7.1.3.
+func parseRetryAfter(s string, now time.Time) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// sleepContext waits for a duration, unless the context is cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
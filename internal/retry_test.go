@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"Wed, 21 Oct 2015 07:28:30 GMT", 30 * time.Second, true},
+		{"Wed, 21 Oct 2015 07:27:00 GMT", 0, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tc := range tests {
+		d, ok := parseRetryAfter(tc.s, now)
+		if d != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tc.s, d, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestClient_retry(t *testing.T) {
+	var attempts int
+	var bodies []string
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	req, _ := c.NewRequest(http.MethodPut, "/file.txt", strings.NewReader("hello"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if attempts != 3 || bodies[0] != "hello" || bodies[2] != "hello" {
+		t.Errorf("attempts = %v, bodies = %q", attempts, bodies)
+	}
+
+	// Non-idempotent requests aren't retried after a bad gateway
+	attempts = 0
+	status = http.StatusBadGateway
+	req, _ = c.NewRequest("LOCK", "/file.txt", nil)
+	if _, err := c.Do(req); HTTPErrorFromError(err).Code != http.StatusBadGateway || attempts != 1 {
+		t.Errorf("LOCK: Do() = %v after %v attempts, want 502 after 1 attempt", err, attempts)
+	}
+
+	// Requests whose body can't be replayed aren't retried
+	attempts = 0
+	status = http.StatusServiceUnavailable
+	req, _ = c.NewRequest(http.MethodPut, "/file.txt", io.NopCloser(strings.NewReader("hello")))
+	if _, err := c.Do(req); HTTPErrorFromError(err).Code != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("PUT with unreplayable body: Do() = %v after %v attempts, want 503 after 1 attempt", err, attempts)
+	}
+}
+
+func TestClient_retryContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := c.NewRequest(http.MethodGet, "/", nil)
+	start := time.Now()
+	if _, err := c.Do(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("Do() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Do() didn't return when the context was cancelled")
+	}
+}
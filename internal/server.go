@@ -8,6 +8,7 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 )
 
@@ -20,8 +21,10 @@ func ServeError(w http.ResponseWriter, err error) {
 
 	var errElt *Error
 	if errors.As(err, &errElt) {
+		w.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
 		w.WriteHeader(code)
-		ServeXML(w).Encode(errElt)
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(errElt)
 		return
 	}
 
@@ -56,15 +59,83 @@ func ServeXML(w http.ResponseWriter) *xml.Encoder {
 }
 
 func ServeMultiStatus(w http.ResponseWriter, ms *MultiStatus) error {
-	// TODO: streaming
+	w.Header().Add("Content-Type", "application/xml; charset=\"utf-8\"")
 	w.WriteHeader(http.StatusMultiStatus)
-	return ServeXML(w).Encode(ms)
+	w.Write([]byte(xml.Header))
+	return xml.NewEncoder(w).Encode(ms)
+}
+
+// MultiStatusWriter writes a multi-status response incrementally: each
+// response element is sent to the client as soon as it's written.
+//
+// The status line is only sent with the first response element, so that
+// errors occurring before that can still be replied with a regular error
+// response.
+type MultiStatusWriter struct {
+	w   http.ResponseWriter
+	enc *xml.Encoder
+}
+
+func NewMultiStatusWriter(w http.ResponseWriter) *MultiStatusWriter {
+	return &MultiStatusWriter{w: w}
+}
+
+func (mw *MultiStatusWriter) start() error {
+	if mw.enc != nil {
+		return nil
+	}
+
+	mw.w.Header().Add("Content-Type", "application/xml; charset=\"utf-8\"")
+	mw.w.WriteHeader(http.StatusMultiStatus)
+	if _, err := mw.w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+
+	mw.enc = xml.NewEncoder(mw.w)
+	return mw.enc.EncodeToken(xml.StartElement{Name: multiStatusName})
+}
+
+// Started reports whether the status line has been sent.
+func (mw *MultiStatusWriter) Started() bool {
+	return mw.enc != nil
+}
+
+// WriteResponse writes a response element and flushes it to the client.
+func (mw *MultiStatusWriter) WriteResponse(resp *Response) error {
+	if err := mw.start(); err != nil {
+		return err
+	}
+	if err := mw.enc.Encode(resp); err != nil {
+		return err
+	}
+	return mw.flush()
+}
+
+func (mw *MultiStatusWriter) flush() error {
+	if err := mw.enc.Flush(); err != nil {
+		return err
+	}
+	if f, ok := mw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Close terminates the multi-status response.
+func (mw *MultiStatusWriter) Close() error {
+	if err := mw.start(); err != nil {
+		return err
+	}
+	if err := mw.enc.EncodeToken(xml.EndElement{Name: multiStatusName}); err != nil {
+		return err
+	}
+	return mw.flush()
 }
 
 type Backend interface {
 	Options(r *http.Request) (caps []string, allow []string, err error)
 	HeadGet(w http.ResponseWriter, r *http.Request) error
-	PropFind(r *http.Request, pf *PropFind, depth Depth) (*MultiStatus, error)
+	PropFind(r *http.Request, pf *PropFind, depth Depth, mw *MultiStatusWriter) error
 	PropPatch(r *http.Request, pu *PropertyUpdate) (*Response, error)
 	Put(w http.ResponseWriter, r *http.Request) error
 	Delete(r *http.Request) error
@@ -73,6 +144,20 @@ type Backend interface {
 	Move(r *http.Request, dest *Href, overwrite bool) (created bool, err error)
 }
 
+// LockBackend is an optional interface which can be implemented by a Backend
+// to support WebDAV class 2 locking.
+type LockBackend interface {
+	Lock(r *http.Request, info *LockInfo, depth Depth, timeout Timeout) (lock *ActiveLock, created bool, err error)
+	RefreshLock(r *http.Request, token string, timeout Timeout) (*ActiveLock, error)
+	Unlock(r *http.Request, token string) error
+}
+
+// ACLBackend is an optional interface which can be implemented by a Backend
+// to support the ACL method, as defined in RFC 3744 section 8.1.
+type ACLBackend interface {
+	ACL(r *http.Request, acl *ACL) error
+}
+
 type Handler struct {
 	Backend Backend
 }
@@ -90,7 +175,6 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case http.MethodPut:
 			err = h.Backend.Put(w, r)
 		case http.MethodDelete:
-			// TODO: send a multistatus in case of partial failure
 			err = h.Backend.Delete(r)
 			if err == nil {
 				w.WriteHeader(http.StatusNoContent)
@@ -106,16 +190,44 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		case "COPY", "MOVE":
 			err = h.handleCopyMove(w, r)
+		case "LOCK":
+			err = h.handleLock(w, r)
+		case "UNLOCK":
+			err = h.handleUnlock(w, r)
+		case "ACL":
+			err = h.handleACL(w, r)
 		default:
 			err = HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
 		}
 	}
 
 	if err != nil {
-		ServeError(w, err)
+		if hrefErrs, ok := HrefErrors(err); ok && !isRequestURIError(r, hrefErrs) {
+			// Partial failure, e.g. a DELETE of a collection where some
+			// members couldn't be removed
+			err = serveHrefErrors(w, hrefErrs)
+		}
+		if err != nil {
+			ServeError(w, err)
+		}
 	}
 }
 
+// isRequestURIError checks whether the errors only concern the resource
+// identified by the request URI, in which case they are sent as a regular
+// error response instead of a multi-status.
+func isRequestURIError(r *http.Request, hrefErrs []*HrefError) bool {
+	return len(hrefErrs) == 1 && path.Clean(hrefErrs[0].Href.Path) == path.Clean(r.URL.Path)
+}
+
+func serveHrefErrors(w http.ResponseWriter, hrefErrs []*HrefError) error {
+	resps := make([]Response, len(hrefErrs))
+	for i, hrefErr := range hrefErrs {
+		resps[i] = *NewErrorResponse(hrefErr.Href.Path, hrefErr.Err)
+	}
+	return ServeMultiStatus(w, NewMultiStatus(resps...))
+}
+
 func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) error {
 	caps, allow, err := h.Backend.Options(r)
 	if err != nil {
@@ -152,12 +264,25 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	ms, err := h.Backend.PropFind(r, &propfind, depth)
-	if err != nil {
-		return err
+	mw := NewMultiStatusWriter(w)
+	if err := h.Backend.PropFind(r, &propfind, depth, mw); err != nil {
+		hrefErrs, ok := HrefErrors(err)
+		if !mw.Started() && (!ok || isRequestURIError(r, hrefErrs)) {
+			return err
+		}
+		if !ok {
+			// The status line has already been sent, report the error in
+			// the body instead
+			hrefErrs = []*HrefError{{Href: url.URL{Path: r.URL.Path}, Err: err}}
+		}
+		for _, hrefErr := range hrefErrs {
+			if err := mw.WriteResponse(NewErrorResponse(hrefErr.Href.Path, hrefErr.Err)); err != nil {
+				return err
+			}
+		}
 	}
 
-	return ServeMultiStatus(w, ms)
+	return mw.Close()
 }
 
 type PropFindFunc func(raw *RawXMLValue) (interface{}, error)
@@ -314,3 +439,143 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request) error {
 	}
 	return nil
 }
+
+func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) error {
+	lb, ok := h.Backend.(LockBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
+	}
+
+	var timeout Timeout
+	if s := r.Header.Get("Timeout"); s != "" {
+		var err error
+		timeout, err = ParseTimeout(s)
+		if err != nil {
+			return &HTTPError{http.StatusBadRequest, err}
+		}
+	}
+
+	var (
+		lock    *ActiveLock
+		created bool
+		err     error
+	)
+	if IsRequestBodyEmpty(r) {
+		// A LOCK request without a body refreshes an existing lock, see RFC
+		// 4918 section 9.10.2
+		token, err := parseRefreshToken(r.Header.Get("If"))
+		if err != nil {
+			return err
+		}
+		lock, err = lb.RefreshLock(r, token, timeout)
+		if err != nil {
+			return err
+		}
+	} else {
+		depth := DepthInfinity
+		if s := r.Header.Get("Depth"); s != "" {
+			depth, err = ParseDepth(s)
+			if err != nil {
+				return &HTTPError{http.StatusBadRequest, err}
+			}
+			if depth == DepthOne {
+				return HTTPErrorf(http.StatusBadRequest, `webdav: "Depth: 1" is not supported in LOCK request`)
+			}
+		}
+
+		var info LockInfo
+		if err := DecodeXMLRequest(r, &info); err != nil {
+			return err
+		}
+		if info.LockType.Write == nil {
+			return HTTPErrorf(http.StatusBadRequest, "webdav: unsupported lock type")
+		}
+		if (info.LockScope.Exclusive == nil) == (info.LockScope.Shared == nil) {
+			return HTTPErrorf(http.StatusBadRequest, "webdav: expected exactly one of exclusive or shared lock scope")
+		}
+
+		lock, created, err = lb.Lock(r, &info, depth, timeout)
+		if err != nil {
+			return err
+		}
+		if lock.LockToken != nil {
+			w.Header().Set("Lock-Token", "<"+lock.LockToken.Href.String()+">")
+		}
+	}
+
+	prop, err := EncodeProp(&LockDiscovery{ActiveLocks: []ActiveLock{*lock}})
+	if err != nil {
+		return err
+	}
+
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
+	w.WriteHeader(code)
+	w.Write([]byte(xml.Header))
+	return xml.NewEncoder(w).Encode(prop)
+}
+
+func parseRefreshToken(s string) (string, error) {
+	if s == "" {
+		return "", HTTPErrorf(http.StatusBadRequest, "webdav: missing If header in LOCK refresh request")
+	}
+	lists, err := ParseIf(s)
+	if err != nil {
+		return "", &HTTPError{http.StatusBadRequest, err}
+	}
+	var token string
+	for _, l := range lists {
+		for _, cond := range l.Conditions {
+			if cond.Token == "" || cond.Not {
+				continue
+			}
+			if token != "" && token != cond.Token {
+				return "", HTTPErrorf(http.StatusBadRequest, "webdav: multiple lock tokens in LOCK refresh request")
+			}
+			token = cond.Token
+		}
+	}
+	if token == "" {
+		return "", HTTPErrorf(http.StatusBadRequest, "webdav: missing lock token in LOCK refresh request")
+	}
+	return token, nil
+}
+
+func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request) error {
+	lb, ok := h.Backend.(LockBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
+	}
+
+	s := strings.TrimSpace(r.Header.Get("Lock-Token"))
+	if len(s) < 2 || s[0] != '<' || s[len(s)-1] != '>' {
+		return HTTPErrorf(http.StatusBadRequest, "webdav: missing or malformed Lock-Token header in UNLOCK request")
+	}
+
+	if err := lb.Unlock(r, s[1:len(s)-1]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) handleACL(w http.ResponseWriter, r *http.Request) error {
+	ab, ok := h.Backend.(ACLBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
+	}
+
+	var acl ACL
+	if err := DecodeXMLRequest(r, &acl); err != nil {
+		return err
+	}
+
+	if err := ab.ACL(r, &acl); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
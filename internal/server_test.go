@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMultiStatusWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	mw := NewMultiStatusWriter(w)
+	if mw.Started() {
+		t.Fatalf("Started() = true before any response")
+	}
+	for _, p := range []string{"/a", "/b"} {
+		if err := mw.WriteResponse(NewOKResponse(p)); err != nil {
+			t.Fatalf("WriteResponse() = %v", err)
+		}
+		if !w.Flushed {
+			t.Errorf("WriteResponse() didn't flush")
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	res := w.Result()
+	if res.StatusCode != http.StatusMultiStatus {
+		t.Errorf("status = %v, want %v", res.StatusCode, http.StatusMultiStatus)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var ms MultiStatus
+	if err := xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	if len(ms.Responses) != 2 || ms.Responses[1].Hrefs[0].Path != "/b" {
+		t.Errorf("decoded multistatus = %+v", ms)
+	}
+}
+
+type testDeleteBackend struct {
+	Backend
+	err error
+}
+
+func (b *testDeleteBackend) Delete(r *http.Request) error {
+	return b.err
+}
+
+func TestHandler_partialDelete(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "member",
+			err: errors.Join(
+				&HrefError{Href: url.URL{Path: "/dir/a"}, Err: HTTPErrorf(http.StatusForbidden, "forbidden")},
+				&HrefError{Href: url.URL{Path: "/dir/b"}, Err: HTTPErrorf(http.StatusLocked, "locked")},
+			),
+			want: http.StatusMultiStatus,
+		},
+		{
+			name: "request-uri",
+			err:  &HrefError{Href: url.URL{Path: "/dir"}, Err: HTTPErrorf(http.StatusForbidden, "forbidden")},
+			want: http.StatusForbidden,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := Handler{Backend: &testDeleteBackend{err: tc.err}}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/dir", nil))
+
+			res := w.Result()
+			if res.StatusCode != tc.want {
+				t.Fatalf("status = %v, want %v", res.StatusCode, tc.want)
+			}
+			if tc.want != http.StatusMultiStatus {
+				return
+			}
+
+			var ms MultiStatus
+			if err := xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+				t.Fatalf("failed to decode multistatus: %v", err)
+			}
+			if len(ms.Responses) != 2 || ms.Responses[1].Status.Code != http.StatusLocked {
+				t.Errorf("decoded multistatus = %+v", ms)
+			}
+		})
+	}
+}
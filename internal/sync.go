@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+)
+
+// CheckSyncCollectionDepth checks the Depth header of a sync-collection
+// REPORT request. RFC 6578 section 3.2 requires "Depth: 0".
+func CheckSyncCollectionDepth(h http.Header) error {
+	s := h.Get("Depth")
+	if s == "" {
+		return nil
+	}
+	depth, err := ParseDepth(s)
+	if err != nil {
+		return &HTTPError{Code: http.StatusBadRequest, Err: err}
+	} else if depth != DepthZero {
+		return HTTPErrorf(http.StatusBadRequest, `webdav: only "Depth: 0" is accepted in sync-collection REPORT request`)
+	}
+	return nil
+}
+
+// CheckLimit returns an error if n changes exceed the limit requested by the
+// client, as defined in RFC 6578 section 3.6.
+func (q *SyncCollectionQuery) CheckLimit(n int) error {
+	if q.Limit != nil && uint(n) > q.Limit.NResults {
+		return NewConditionError(http.StatusInsufficientStorage, NumberOfMatchesWithinLimitsName)
+	}
+	return nil
+}
+
+// NewSyncCollectionMultiStatus creates the reply to a sync-collection
+// report. Members which have been removed are reported with 404 Not Found.
+// If truncated is set, only some of the changes are listed and the
+// request-URI is reported with 507 Insufficient Storage, as defined in
+// RFC 6578 section 3.6.
+func NewSyncCollectionMultiStatus(reqPath, syncToken string, resps []Response, deleted []string, truncated bool) *MultiStatus {
+	ms := &MultiStatus{Responses: resps, SyncToken: syncToken}
+	for _, p := range deleted {
+		ms.Responses = append(ms.Responses, Response{
+			Hrefs:  []Href{{Path: p}},
+			Status: &Status{Code: http.StatusNotFound},
+		})
+	}
+	if truncated {
+		ms.Responses = append(ms.Responses, Response{
+			Hrefs:  []Href{{Path: reqPath}},
+			Status: &Status{Code: http.StatusInsufficientStorage},
+			Error:  &Error{Raw: []RawXMLValue{*NewRawXMLElement(NumberOfMatchesWithinLimitsName, nil, nil)}},
+		})
+	}
+	return ms
+}
+
+// IsSyncTokenError checks whether an error has been caused by an invalid
+// sync token. RFC 6578 specifies a DAV:valid-sync-token precondition, but
+// some servers reply with 410 Gone instead.
+func IsSyncTokenError(err error) bool {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	if httpErr.Code == http.StatusGone {
+		return true
+	}
+
+	var davErr *Error
+	if httpErr.Code != http.StatusForbidden || !errors.As(httpErr.Err, &davErr) {
+		return false
+	}
+	for _, raw := range davErr.Raw {
+		if name, ok := raw.XMLName(); ok && name == ValidSyncTokenName {
+			return true
+		}
+	}
+	return false
+}
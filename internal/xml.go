@@ -89,6 +89,22 @@ func (val *RawXMLValue) Decode(v interface{}) error {
 	return xml.NewTokenDecoder(val.TokenReader()).Decode(&v)
 }
 
+// InnerXML encodes the children of the XML value. Namespaces are declared
+// explicitly, so the result doesn't depend on prefixes defined by ancestors.
+func (val *RawXMLValue) InnerXML() (string, error) {
+	var sb strings.Builder
+	enc := xml.NewEncoder(&sb)
+	for _, child := range val.children {
+		if err := child.MarshalXML(enc, xml.StartElement{}); err != nil {
+			return "", err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
 func (val *RawXMLValue) XMLName() (name xml.Name, ok bool) {
 	if start, ok := val.tok.(xml.StartElement); ok {
 		return start.Name, true
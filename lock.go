@@ -0,0 +1,533 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// LockDetails describes a WebDAV write lock.
+type LockDetails struct {
+	// Root is the path of the locked resource.
+	Root string
+	// Shared indicates that the lock is a shared lock. Otherwise, it's an
+	// exclusive lock.
+	Shared bool
+	// ZeroDepth indicates that the lock only applies to the root resource,
+	// and not to its descendants.
+	ZeroDepth bool
+	// OwnerXML is the raw XML contents of the DAV:owner element supplied by
+	// the client, if any.
+	OwnerXML string
+	// Timeout is the duration after which the lock expires. Zero means the
+	// lock never expires.
+	Timeout time.Duration
+}
+
+// Lock is an active WebDAV lock.
+type Lock struct {
+	LockDetails
+
+	// Token is the lock token, as defined in RFC 4918 section 6.5.
+	Token string
+	// Expires is the time when the lock expires. A zero value means the lock
+	// never expires.
+	Expires time.Time
+}
+
+// LockSystem manages WebDAV locks. It can be used to support WebDAV class 2
+// compliance, as defined in RFC 4918 section 18.2.
+//
+// LockSystem implementations don't need to check lock tokens submitted by
+// clients: the Handler takes care of this.
+type LockSystem interface {
+	// Create creates a new lock. If the lock conflicts with an existing lock,
+	// an error with a 423 Locked status code should be returned.
+	Create(ctx context.Context, details *LockDetails) (*Lock, error)
+	// Refresh resets the timeout of an existing lock.
+	Refresh(ctx context.Context, token string, timeout time.Duration) (*Lock, error)
+	// Unlock removes an existing lock.
+	Unlock(ctx context.Context, token string) error
+	// Lookup returns the active locks which apply to a resource: locks held
+	// on the resource itself and infinite-depth locks held on its ancestors.
+	// If recursive is set, locks held on its descendants are returned as
+	// well.
+	Lookup(ctx context.Context, name string, recursive bool) ([]Lock, error)
+}
+
+// NewMemLockSystem creates a new in-memory LockSystem.
+func NewMemLockSystem() LockSystem {
+	return &memLockSystem{locks: make(map[string]*Lock)}
+}
+
+type memLockSystem struct {
+	mu    sync.Mutex
+	locks map[string]*Lock
+}
+
+var _ LockSystem = (*memLockSystem)(nil)
+
+func cleanLockPath(name string) string {
+	name = path.Clean("/" + name)
+	return name
+}
+
+// isAncestorPath reports whether the resource at name is a strict descendant
+// of the resource at parent.
+func isAncestorPath(parent, name string) bool {
+	if parent == "/" {
+		return name != "/"
+	}
+	return strings.HasPrefix(name, parent+"/")
+}
+
+func (l *Lock) appliesTo(name string, recursive bool) bool {
+	if l.Root == name {
+		return true
+	}
+	if !l.ZeroDepth && isAncestorPath(l.Root, name) {
+		return true
+	}
+	return recursive && isAncestorPath(name, l.Root)
+}
+
+func (l *Lock) conflicts(details *LockDetails) bool {
+	if l.Shared && details.Shared {
+		return false
+	}
+	if l.Root == details.Root {
+		return true
+	}
+	if !l.ZeroDepth && isAncestorPath(l.Root, details.Root) {
+		return true
+	}
+	return !details.ZeroDepth && isAncestorPath(details.Root, l.Root)
+}
+
+func (ls *memLockSystem) expire(now time.Time) {
+	for token, l := range ls.locks {
+		if !l.Expires.IsZero() && !now.Before(l.Expires) {
+			delete(ls.locks, token)
+		}
+	}
+}
+
+func newLockToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// Random UUID, see RFC 4122 section 4.4
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func lockExpiry(now time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(timeout)
+}
+
+func (ls *memLockSystem) Create(ctx context.Context, details *LockDetails) (*Lock, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	ls.expire(now)
+
+	d := *details
+	d.Root = cleanLockPath(d.Root)
+	for _, l := range ls.locks {
+		if l.conflicts(&d) {
+			return nil, internal.NewConditionError(http.StatusLocked, xml.Name{internal.Namespace, "no-conflicting-lock"})
+		}
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	l := &Lock{
+		LockDetails: d,
+		Token:       token,
+		Expires:     lockExpiry(now, d.Timeout),
+	}
+	ls.locks[token] = l
+
+	lock := *l
+	return &lock, nil
+}
+
+func (ls *memLockSystem) Refresh(ctx context.Context, token string, timeout time.Duration) (*Lock, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	ls.expire(now)
+
+	l, ok := ls.locks[token]
+	if !ok {
+		return nil, NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("webdav: unknown lock token"))
+	}
+	l.Timeout = timeout
+	l.Expires = lockExpiry(now, timeout)
+
+	lock := *l
+	return &lock, nil
+}
+
+func (ls *memLockSystem) Unlock(ctx context.Context, token string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.expire(time.Now())
+
+	if _, ok := ls.locks[token]; !ok {
+		return NewHTTPError(http.StatusConflict, fmt.Errorf("webdav: unknown lock token"))
+	}
+	delete(ls.locks, token)
+	return nil
+}
+
+func (ls *memLockSystem) Lookup(ctx context.Context, name string, recursive bool) ([]Lock, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.expire(time.Now())
+
+	name = cleanLockPath(name)
+	var l []Lock
+	for _, lock := range ls.locks {
+		if lock.appliesTo(name, recursive) {
+			l = append(l, *lock)
+		}
+	}
+	return l, nil
+}
+
+var supportedLock = &internal.SupportedLock{
+	LockEntries: []internal.LockEntry{
+		{
+			LockScope: internal.LockScope{Exclusive: &struct{}{}},
+			LockType:  internal.LockType{Write: &struct{}{}},
+		},
+		{
+			LockScope: internal.LockScope{Shared: &struct{}{}},
+			LockType:  internal.LockType{Write: &struct{}{}},
+		},
+	},
+}
+
+func activeLockFromLock(lock *Lock, now time.Time) *internal.ActiveLock {
+	al := internal.ActiveLock{
+		LockType:  internal.LockType{Write: &struct{}{}},
+		Depth:     internal.DepthInfinity,
+		LockToken: &internal.LockToken{Href: internal.Href{Opaque: lock.Token}},
+		LockRoot:  internal.LockRoot{Href: internal.Href{Path: lock.Root}},
+	}
+	if lock.Shared {
+		al.LockScope.Shared = &struct{}{}
+	} else {
+		al.LockScope.Exclusive = &struct{}{}
+	}
+	if lock.ZeroDepth {
+		al.Depth = internal.DepthZero
+	}
+	if lock.OwnerXML != "" {
+		al.Owner = &internal.Owner{InnerXML: lock.OwnerXML}
+	}
+	if !lock.Expires.IsZero() {
+		al.Timeout = internal.Timeout(lock.Expires.Sub(now))
+		if al.Timeout <= 0 {
+			al.Timeout = internal.Timeout(time.Second)
+		}
+	}
+	return &al
+}
+
+// submittedLockTokens evaluates the If header of a request and returns the
+// lock tokens submitted by the client.
+func (b *backend) submittedLockTokens(r *http.Request) (map[string]bool, error) {
+	s := r.Header.Get("If")
+	if s == "" {
+		return nil, nil
+	}
+	lists, err := internal.ParseIf(s)
+	if err != nil {
+		return nil, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+	}
+
+	ctx := r.Context()
+	tokens := make(map[string]bool)
+	matched := false
+	for _, l := range lists {
+		name := r.URL.Path
+		if l.Resource != "" {
+			u, err := url.Parse(l.Resource)
+			if err != nil {
+				return nil, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+			}
+			name = u.Path
+		}
+
+		ok, err := b.evalIfList(ctx, name, l.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		matched = true
+		for _, cond := range l.Conditions {
+			if cond.Token != "" && !cond.Not {
+				tokens[cond.Token] = true
+			}
+		}
+	}
+	if !matched {
+		return nil, NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("webdav: If header condition failed"))
+	}
+	return tokens, nil
+}
+
+func (b *backend) evalIfList(ctx context.Context, name string, conds []internal.IfCondition) (bool, error) {
+	for _, cond := range conds {
+		var ok bool
+		if cond.Token != "" {
+			locks, err := b.LockSystem.Lookup(ctx, name, false)
+			if err != nil {
+				return false, err
+			}
+			for _, l := range locks {
+				if l.Token == cond.Token {
+					ok = true
+					break
+				}
+			}
+		} else {
+			fi, err := b.FileSystem.Stat(ctx, name)
+			if err != nil && !internal.IsNotFound(err) {
+				return false, err
+			}
+			ok = fi != nil && fi.ETag == cond.ETag
+		}
+		if ok == cond.Not {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// confirmLocks checks that the client has submitted the lock tokens required
+// to modify the resource at name. If recursive is set, locks held on
+// descendants are checked too. If membership is set, the request adds or
+// removes name from its parent collection, so locks held on the parent are
+// checked too.
+func (b *backend) confirmLocks(r *http.Request, name string, recursive, membership bool) error {
+	if b.LockSystem == nil {
+		return nil
+	}
+
+	tokens, err := b.submittedLockTokens(r)
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+	name = cleanLockPath(name)
+	locks, err := b.LockSystem.Lookup(ctx, name, recursive)
+	if err != nil {
+		return err
+	}
+	if membership && name != "/" {
+		parentLocks, err := b.LockSystem.Lookup(ctx, path.Dir(name), false)
+		if err != nil {
+			return err
+		}
+		locks = append(locks, parentLocks...)
+	}
+
+	var roots []string
+	seen := make(map[string]bool)
+	for _, l := range locks {
+		if tokens[l.Token] || seen[l.Root] {
+			continue
+		}
+		seen[l.Root] = true
+		roots = append(roots, l.Root)
+	}
+	if len(roots) > 0 {
+		return internal.NewLockTokenSubmittedError(roots...)
+	}
+	return nil
+}
+
+// releaseLocks removes the locks held on a resource and its descendants,
+// after the resource has been deleted or moved away.
+func (b *backend) releaseLocks(r *http.Request, name string) error {
+	if b.LockSystem == nil {
+		return nil
+	}
+
+	ctx := r.Context()
+	name = cleanLockPath(name)
+	locks, err := b.LockSystem.Lookup(ctx, name, true)
+	if err != nil {
+		return err
+	}
+	for _, l := range locks {
+		if l.Root != name && !isAncestorPath(name, l.Root) {
+			continue
+		}
+		if err := b.LockSystem.Unlock(ctx, l.Token); err != nil && !isHTTPStatus(err, http.StatusConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+func isHTTPStatus(err error, code int) bool {
+	httpErr := internal.HTTPErrorFromError(err)
+	return httpErr != nil && httpErr.Code == code
+}
+
+func (b *backend) lockDiscovery(ctx context.Context, name string) (*internal.LockDiscovery, error) {
+	locks, err := b.LockSystem.Lookup(ctx, name, false)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ld := &internal.LockDiscovery{ActiveLocks: make([]internal.ActiveLock, len(locks))}
+	for i := range locks {
+		al := activeLockFromLock(&locks[i], now)
+		// Lock tokens are only returned in the LOCK response, see RFC 4918
+		// section 15.8
+		al.LockToken = nil
+		ld.ActiveLocks[i] = *al
+	}
+	return ld, nil
+}
+
+// maxLockTimeout is the maximum duration of a lock. It's also used when the
+// client doesn't request a timeout or requests an infinite one, so that locks
+// left behind by clients eventually expire.
+const maxLockTimeout = time.Hour
+
+// lockTimeout returns the timeout requested by a client, capped to
+// maxLockTimeout.
+func lockTimeout(timeout internal.Timeout) time.Duration {
+	d := time.Duration(timeout)
+	if d <= 0 || d > maxLockTimeout {
+		return maxLockTimeout
+	}
+	return d
+}
+
+func (b *backend) Lock(r *http.Request, info *internal.LockInfo, depth internal.Depth, timeout internal.Timeout) (*internal.ActiveLock, bool, error) {
+	if b.LockSystem == nil {
+		return nil, false, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: locking not supported")
+	}
+
+	ctx := r.Context()
+	_, err := b.FileSystem.Stat(ctx, r.URL.Path)
+	create := internal.IsNotFound(err)
+	if err != nil && !create {
+		return nil, false, err
+	}
+	if create {
+		if err := b.confirmLocks(r, r.URL.Path, false, true); err != nil {
+			return nil, false, err
+		}
+	}
+
+	details := LockDetails{
+		Root:      r.URL.Path,
+		Shared:    info.LockScope.Shared != nil,
+		ZeroDepth: depth == internal.DepthZero,
+		Timeout:   lockTimeout(timeout),
+	}
+	if info.Owner != nil {
+		details.OwnerXML = info.Owner.InnerXML
+	}
+	lock, err := b.LockSystem.Create(ctx, &details)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if create {
+		// Locking an unmapped URL creates an empty resource, see RFC 4918
+		// section 9.10.4. The lock is acquired first, so that no resource
+		// is left behind if it conflicts with another lock.
+		opts := CreateOptions{IfNoneMatch: "*"}
+		_, _, err := b.FileSystem.Create(ctx, r.URL.Path, http.NoBody, &opts)
+		if internal.IsNotFound(err) {
+			err = &internal.HTTPError{Code: http.StatusConflict, Err: err}
+		}
+		if err != nil {
+			b.LockSystem.Unlock(ctx, lock.Token)
+			return nil, false, err
+		}
+	}
+
+	return activeLockFromLock(lock, time.Now()), create, nil
+}
+
+func (b *backend) RefreshLock(r *http.Request, token string, timeout internal.Timeout) (*internal.ActiveLock, error) {
+	if b.LockSystem == nil {
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: locking not supported")
+	}
+
+	ctx := r.Context()
+	cur := b.lookupLock(ctx, r.URL.Path, token)
+	if cur == nil {
+		return nil, internal.NewConditionError(http.StatusPreconditionFailed, xml.Name{internal.Namespace, "lock-token-matches-request-uri"})
+	}
+	// Without a Timeout header, the lock is refreshed with its current
+	// timeout
+	if r.Header.Get("Timeout") == "" {
+		timeout = internal.Timeout(cur.Timeout)
+	}
+	lock, err := b.LockSystem.Refresh(ctx, token, lockTimeout(timeout))
+	if err != nil {
+		return nil, err
+	}
+	return activeLockFromLock(lock, time.Now()), nil
+}
+
+func (b *backend) Unlock(r *http.Request, token string) error {
+	if b.LockSystem == nil {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: locking not supported")
+	}
+
+	ctx := r.Context()
+	if b.lookupLock(ctx, r.URL.Path, token) == nil {
+		return internal.NewConditionError(http.StatusConflict, xml.Name{internal.Namespace, "lock-token-matches-request-uri"})
+	}
+	return b.LockSystem.Unlock(ctx, token)
+}
+
+// lookupLock returns the lock with the specified token applying to a
+// resource, or nil if there is none.
+func (b *backend) lookupLock(ctx context.Context, name, token string) *Lock {
+	locks, err := b.LockSystem.Lookup(ctx, name, false)
+	if err != nil {
+		return nil
+	}
+	for i := range locks {
+		if locks[i].Token == token {
+			return &locks[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+const lockInfoExclusive = `<?xml version="1.0" encoding="utf-8" ?>
+<D:lockinfo xmlns:D='DAV:'>
+  <D:lockscope><D:exclusive/></D:lockscope>
+  <D:locktype><D:write/></D:locktype>
+  <D:owner><D:href>http://example.org/~ejw/contact.html</D:href></D:owner>
+</D:lockinfo>`
+
+func newTestLockHandler(t *testing.T) http.Handler {
+	return &Handler{
+		FileSystem: LocalFileSystem(t.TempDir()),
+		LockSystem: NewMemLockSystem(),
+	}
+}
+
+func doTestRequest(h http.Handler, method, path, body string, header map[string]string) *http.Response {
+	var req *http.Request
+	if body != "" {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/xml")
+	} else {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestLock(t *testing.T) {
+	h := newTestLockHandler(t)
+
+	res := doTestRequest(h, http.MethodOptions, "/", "", nil)
+	if dav := res.Header.Get("DAV"); !strings.Contains(dav, "2") {
+		t.Errorf("OPTIONS: DAV header = %q, want class 2", dav)
+	}
+
+	res = doTestRequest(h, "LOCK", "/file.txt", lockInfoExclusive, map[string]string{"Timeout": "Second-3600"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("LOCK: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	token := res.Header.Get("Lock-Token")
+	if !strings.HasPrefix(token, "<urn:uuid:") {
+		t.Fatalf("LOCK: Lock-Token = %q", token)
+	}
+
+	res = doTestRequest(h, "LOCK", "/file.txt", lockInfoExclusive, nil)
+	if res.StatusCode != http.StatusLocked {
+		t.Errorf("conflicting LOCK: status = %v, want %v", res.StatusCode, http.StatusLocked)
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/file.txt", "", nil)
+	if res.StatusCode != http.StatusLocked {
+		t.Errorf("PUT without token: status = %v, want %v", res.StatusCode, http.StatusLocked)
+	}
+
+	res = doTestRequest(h, http.MethodDelete, "/", "", nil)
+	if res.StatusCode != http.StatusLocked {
+		t.Errorf("DELETE parent without token: status = %v, want %v", res.StatusCode, http.StatusLocked)
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/file.txt", "", map[string]string{"If": "(<urn:uuid:wrong>)"})
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with wrong token: status = %v, want %v", res.StatusCode, http.StatusPreconditionFailed)
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/file.txt", "", map[string]string{"If": "(" + token + ")"})
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("PUT with token: status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+
+	res = doTestRequest(h, "LOCK", "/file.txt", "", map[string]string{"If": "(" + token + ")", "Timeout": "Infinite"})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("LOCK refresh: status = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+
+	res = doTestRequest(h, "UNLOCK", "/file.txt", "", map[string]string{"Lock-Token": token})
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("UNLOCK: status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+
+	res = doTestRequest(h, http.MethodDelete, "/file.txt", "", nil)
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE after UNLOCK: status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestLock_shared(t *testing.T) {
+	h := newTestLockHandler(t)
+	shared := strings.Replace(lockInfoExclusive, "<D:exclusive/>", "<D:shared/>", 1)
+
+	for i := 0; i < 2; i++ {
+		res := doTestRequest(h, "LOCK", "/file.txt", shared, nil)
+		if res.StatusCode/100 != 2 {
+			t.Fatalf("shared LOCK #%v: status = %v", i, res.StatusCode)
+		}
+	}
+
+	res := doTestRequest(h, "LOCK", "/file.txt", lockInfoExclusive, nil)
+	if res.StatusCode != http.StatusLocked {
+		t.Errorf("exclusive LOCK: status = %v, want %v", res.StatusCode, http.StatusLocked)
+	}
+}
+
+func TestLock_timeout(t *testing.T) {
+	ls := NewMemLockSystem()
+	h := &Handler{FileSystem: LocalFileSystem(t.TempDir()), LockSystem: ls}
+
+	lockTimeout := func() time.Duration {
+		locks, err := ls.Lookup(context.Background(), "/file.txt", false)
+		if err != nil || len(locks) != 1 {
+			t.Fatalf("Lookup() = %v, %v", locks, err)
+		}
+		return locks[0].Timeout
+	}
+
+	res := doTestRequest(h, "LOCK", "/file.txt", lockInfoExclusive, map[string]string{"Timeout": "Infinite"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("LOCK: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	token := res.Header.Get("Lock-Token")
+	if d := lockTimeout(); d != maxLockTimeout {
+		t.Errorf("infinite LOCK: timeout = %v, want %v", d, maxLockTimeout)
+	}
+
+	res = doTestRequest(h, "LOCK", "/file.txt", "", map[string]string{"If": "(" + token + ")", "Timeout": "Second-60"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("LOCK refresh: status = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+	if d := lockTimeout(); d != time.Minute {
+		t.Errorf("LOCK refresh: timeout = %v, want %v", d, time.Minute)
+	}
+
+	res = doTestRequest(h, "LOCK", "/file.txt", "", map[string]string{"If": "(" + token + ")"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("LOCK refresh without timeout: status = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+	if d := lockTimeout(); d != time.Minute {
+		t.Errorf("LOCK refresh without timeout: timeout = %v, want %v", d, time.Minute)
+	}
+}
+
+type conflictingLockSystem struct {
+	LockSystem
+}
+
+func (ls conflictingLockSystem) Create(ctx context.Context, details *LockDetails) (*Lock, error) {
+	return nil, internal.HTTPErrorf(http.StatusLocked, "webdav: lock conflict")
+}
+
+func TestLock_conflictUnmapped(t *testing.T) {
+	fs := LocalFileSystem(t.TempDir())
+	h := &Handler{FileSystem: fs, LockSystem: conflictingLockSystem{NewMemLockSystem()}}
+
+	res := doTestRequest(h, "LOCK", "/file.txt", lockInfoExclusive, nil)
+	if res.StatusCode != http.StatusLocked {
+		t.Fatalf("LOCK: status = %v, want %v", res.StatusCode, http.StatusLocked)
+	}
+	if _, err := fs.Stat(context.Background(), "/file.txt"); !internal.IsNotFound(err) {
+		t.Errorf("Stat() after failed LOCK = %v, want not found", err)
+	}
+}
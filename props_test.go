@@ -0,0 +1,123 @@
+package webdav
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const propPatchSetAuthor = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="http://ns.example.com/standards/z39.50/">
+  <D:set>
+    <D:prop>
+      <Z:Authors><Z:Author>Jim Whitehead</Z:Author></Z:Authors>
+    </D:prop>
+  </D:set>
+</D:propertyupdate>`
+
+const propFindAllProp = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`
+
+func readTestBody(t *testing.T, res *http.Response) string {
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	return string(b)
+}
+
+func TestPropPatch(t *testing.T) {
+	h := &Handler{FileSystem: LocalFileSystem(t.TempDir())}
+
+	res := doTestRequest(h, http.MethodPut, "/file.txt", "hello", nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status = %v", res.StatusCode)
+	}
+
+	res = doTestRequest(h, "PROPPATCH", "/file.txt", propPatchSetAuthor, nil)
+	if body := readTestBody(t, res); res.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "200 OK") {
+		t.Fatalf("PROPPATCH: status = %v, body = %v", res.StatusCode, body)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/file.txt", propFindAllProp, map[string]string{"Depth": "0"})
+	if body := readTestBody(t, res); !strings.Contains(body, "Jim Whitehead") {
+		t.Errorf("PROPFIND allprop: missing dead property in %v", body)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/", propFindAllProp, map[string]string{"Depth": "1"})
+	if body := readTestBody(t, res); strings.Contains(body, ".webdav.") {
+		t.Errorf("PROPFIND: reserved file exposed in %v", body)
+	}
+
+	res = doTestRequest(h, "MOVE", "/file.txt", "", map[string]string{"Destination": "/moved.txt"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE: status = %v", res.StatusCode)
+	}
+	res = doTestRequest(h, "COPY", "/moved.txt", "", map[string]string{"Destination": "/copied.txt"})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("COPY: status = %v", res.StatusCode)
+	}
+	for _, name := range []string{"/moved.txt", "/copied.txt"} {
+		res = doTestRequest(h, "PROPFIND", name, propFindAllProp, map[string]string{"Depth": "0"})
+		if body := readTestBody(t, res); !strings.Contains(body, "Jim Whitehead") {
+			t.Errorf("PROPFIND %v: missing dead property in %v", name, body)
+		}
+	}
+
+	res = doTestRequest(h, http.MethodDelete, "/copied.txt", "", nil)
+	res = doTestRequest(h, http.MethodPut, "/copied.txt", "hello", nil)
+	res = doTestRequest(h, "PROPFIND", "/copied.txt", propFindAllProp, map[string]string{"Depth": "0"})
+	if body := readTestBody(t, res); strings.Contains(body, "Jim Whitehead") {
+		t.Errorf("PROPFIND after DELETE: stale dead property in %v", body)
+	}
+}
+
+func TestPropPatch_protected(t *testing.T) {
+	h := &Handler{FileSystem: LocalFileSystem(t.TempDir())}
+	doTestRequest(h, http.MethodPut, "/file.txt", "hello", nil)
+
+	update := strings.Replace(propPatchSetAuthor, "</D:prop>", "<D:getetag>\"foo\"</D:getetag></D:prop>", 1)
+	res := doTestRequest(h, "PROPPATCH", "/file.txt", update, nil)
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "403 Forbidden") || !strings.Contains(body, "424 Failed Dependency") {
+		t.Errorf("PROPPATCH: body = %v", body)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/file.txt", propFindAllProp, map[string]string{"Depth": "0"})
+	if body := readTestBody(t, res); strings.Contains(body, "Jim Whitehead") {
+		t.Errorf("PROPFIND: failed PROPPATCH was partially applied: %v", body)
+	}
+}
+
+func TestPropPatch_order(t *testing.T) {
+	const (
+		setThenRemove = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:example">
+  <D:set><D:prop><Z:color>red</Z:color></D:prop></D:set>
+  <D:remove><D:prop><Z:color/></D:prop></D:remove>
+</D:propertyupdate>`
+		removeThenSet = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:example">
+  <D:remove><D:prop><Z:color/></D:prop></D:remove>
+  <D:set><D:prop><Z:color>blue</Z:color></D:prop></D:set>
+</D:propertyupdate>`
+	)
+
+	for _, fs := range []FileSystem{LocalFileSystem(t.TempDir()), NewMemFileSystem()} {
+		h := &Handler{FileSystem: fs}
+		doTestRequest(h, http.MethodPut, "/file.txt", "hello", nil)
+
+		doTestRequest(h, "PROPPATCH", "/file.txt", setThenRemove, nil)
+		res := doTestRequest(h, "PROPFIND", "/file.txt", propFindAllProp, map[string]string{"Depth": "0"})
+		if body := readTestBody(t, res); strings.Contains(body, "red") {
+			t.Errorf("%T: PROPFIND after set then remove: property still present in %v", fs, body)
+		}
+
+		doTestRequest(h, "PROPPATCH", "/file.txt", removeThenSet, nil)
+		res = doTestRequest(h, "PROPFIND", "/file.txt", propFindAllProp, map[string]string{"Depth": "0"})
+		if body := readTestBody(t, res); !strings.Contains(body, "blue") {
+			t.Errorf("%T: PROPFIND after remove then set: property missing in %v", fs, body)
+		}
+	}
+}
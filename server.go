@@ -3,9 +3,13 @@ package webdav
 import (
 	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 
@@ -13,6 +17,9 @@ import (
 )
 
 // FileSystem is a WebDAV server backend.
+//
+// ReadDir, RemoveAll, Copy and Move can report failures affecting only some
+// of the files involved by joining errors created with NewResourceError.
 type FileSystem interface {
 	Open(ctx context.Context, name string) (io.ReadCloser, error)
 	Stat(ctx context.Context, name string) (*FileInfo, error)
@@ -24,10 +31,91 @@ type FileSystem interface {
 	Move(ctx context.Context, name, dest string, options *MoveOptions) (created bool, err error)
 }
 
+// PropertyStore is an optional interface which can be implemented by a
+// FileSystem to store dead properties.
+//
+// Implementations are responsible for keeping properties consistent when
+// files are copied, moved or removed.
+type PropertyStore interface {
+	// Properties returns the dead properties of a file.
+	Properties(ctx context.Context, name string) ([]Property, error)
+	// PatchProperties atomically sets and removes dead properties of a file.
+	// Instructions are applied in order, so a property set then removed
+	// ends up removed.
+	PatchProperties(ctx context.Context, name string, patch []PropertyPatch) error
+}
+
+// DirWalker is an optional interface which can be implemented by a FileSystem
+// to list directories incrementally. The Handler uses it to stream PROPFIND
+// responses instead of buffering the whole listing.
+//
+// Like ReadDir, WalkDir may skip files which can't be listed and report them
+// with errors created by NewResourceError, joined with errors.Join.
+type DirWalker interface {
+	// WalkDir calls fn for each file in a directory, including the directory
+	// itself, in the same order as ReadDir. If fn returns an error, WalkDir
+	// stops and returns that error.
+	WalkDir(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error
+}
+
+// QuotaFileSystem is an optional interface which can be implemented by a
+// FileSystem to report storage quotas. The Handler exposes them as RFC 4331
+// properties on collections, and rejects uploads which don't fit.
+type QuotaFileSystem interface {
+	// Quota returns the storage quota applying to a collection.
+	Quota(ctx context.Context, name string) (*Quota, error)
+	// AvailableBytes returns the number of bytes which can still be stored
+	// in a collection, or a negative value if unknown. Unlike Quota, it's
+	// called for every upload, so it should be cheap to compute.
+	AvailableBytes(ctx context.Context, name string) (int64, error)
+}
+
+// UploadFileSystem is an optional interface which can be implemented by a
+// FileSystem to support resumable uploads via partial PUT requests. Data is
+// accumulated in a pending upload, which isn't visible to clients until it's
+// committed.
+type UploadFileSystem interface {
+	// UploadSize returns the number of bytes received so far for the pending
+	// upload of a file. It returns a 404 error if there is none.
+	UploadSize(ctx context.Context, name string) (int64, error)
+	// WriteUpload appends data to the pending upload of a file, and returns
+	// its new size. An offset of zero starts a new upload, discarding any
+	// previous one. Other offsets must be equal to the size of the pending
+	// upload, otherwise a 416 error is returned. If reading the body fails,
+	// the data received so far is kept.
+	WriteUpload(ctx context.Context, name string, offset int64, body io.Reader) (size int64, err error)
+	// CommitUpload atomically replaces a file with its pending upload.
+	CommitUpload(ctx context.Context, name string, opts *CreateOptions) (fileInfo *FileInfo, created bool, err error)
+}
+
+// ChangeTracker is an optional interface which can be implemented by a
+// FileSystem to support the sync-collection report defined in RFC 6578, which
+// lets clients fetch the changes made to a collection since their last
+// synchronization.
+type ChangeTracker interface {
+	// SyncToken returns a token identifying the current state of a
+	// collection. It must be an absolute URI.
+	SyncToken(ctx context.Context, name string) (string, error)
+	// Changes returns the members of a collection which have been created,
+	// updated or removed since the state identified by token, along with the
+	// token of the current state. An empty token lists all members. Only the
+	// internal members are considered unless recursive is set. The collection
+	// itself isn't included.
+	//
+	// If the token isn't valid anymore, an error wrapping ErrInvalidSyncToken
+	// is returned.
+	Changes(ctx context.Context, name, token string, recursive bool) (changes []Change, newToken string, err error)
+}
+
 // Handler handles WebDAV HTTP requests. It can be used to create a WebDAV
 // server.
 type Handler struct {
 	FileSystem FileSystem
+	// LockSystem enables WebDAV class 2 locking when set.
+	LockSystem LockSystem
+	// Authorizer enables access control when set. The current user's
+	// principal is obtained with PrincipalFromContext.
+	Authorizer Authorizer
 }
 
 // ServeHTTP implements http.Handler.
@@ -37,7 +125,23 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := backend{h.FileSystem}
+	b := backend{
+		FileSystem: h.FileSystem,
+		LockSystem: h.LockSystem,
+		Authorizer: h.Authorizer,
+	}
+	if err := b.authorize(r); err != nil {
+		internal.ServeError(w, err)
+		return
+	}
+
+	if ct, ok := h.FileSystem.(ChangeTracker); ok && r.Method == "REPORT" {
+		if err := b.serveReport(w, r, ct); err != nil {
+			internal.ServeError(w, err)
+		}
+		return
+	}
+
 	hh := internal.Handler{Backend: &b}
 	hh.ServeHTTP(w, r)
 }
@@ -51,14 +155,36 @@ func NewHTTPError(statusCode int, cause error) error {
 	return &internal.HTTPError{Code: statusCode, Err: cause}
 }
 
+// NewResourceError creates a new error associated with a specific resource.
+// Backends can join such errors with errors.Join to report a partial failure
+// (e.g. some files of a directory could not be removed), which is replied with
+// a "207 Multi-Status" response listing the failed resources.
+func NewResourceError(name string, err error) error {
+	return &internal.HrefError{Href: url.URL{Path: name}, Err: err}
+}
+
 type backend struct {
 	FileSystem FileSystem
+	LockSystem LockSystem
+	Authorizer Authorizer
 }
 
 func (b *backend) Options(r *http.Request) (caps []string, allow []string, err error) {
+	if b.LockSystem != nil {
+		caps = append(caps, "2")
+	}
+	_, hasACL := b.Authorizer.(ACLStore)
+	if b.Authorizer != nil {
+		caps = append(caps, "access-control")
+	}
+
 	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
 	if internal.IsNotFound(err) {
-		return nil, []string{http.MethodOptions, http.MethodPut, "MKCOL"}, nil
+		allow = []string{http.MethodOptions, http.MethodPut, "MKCOL"}
+		if b.LockSystem != nil {
+			allow = append(allow, "LOCK")
+		}
+		return caps, allow, nil
 	} else if err != nil {
 		return nil, nil, err
 	}
@@ -67,6 +193,7 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 		http.MethodOptions,
 		http.MethodDelete,
 		"PROPFIND",
+		"PROPPATCH",
 		"COPY",
 		"MOVE",
 	}
@@ -74,8 +201,17 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 	if !fi.IsDir {
 		allow = append(allow, http.MethodHead, http.MethodGet, http.MethodPut)
 	}
+	if b.LockSystem != nil {
+		allow = append(allow, "LOCK", "UNLOCK")
+	}
+	if _, ok := b.FileSystem.(ChangeTracker); ok && fi.IsDir {
+		allow = append(allow, "REPORT")
+	}
+	if hasACL {
+		allow = append(allow, "ACL")
+	}
 
-	return nil, allow, nil
+	return caps, allow, nil
 }
 
 func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
@@ -115,44 +251,62 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth) (*internal.MultiStatus, error) {
-	// TODO: use partial error Response on error
-
+func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth, mw *internal.MultiStatusWriter) error {
 	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
 	if depth != internal.DepthZero && fi.IsDir {
-		children, err := b.FileSystem.ReadDir(r.Context(), r.URL.Path, depth == internal.DepthInfinity)
-		if err != nil {
-			return nil, err
-		}
+		return walkDir(r.Context(), b.FileSystem, r.URL.Path, depth == internal.DepthInfinity, func(child *FileInfo) error {
+			if err := b.authorizeRead(r.Context(), child.Path); err != nil {
+				return mw.WriteResponse(internal.NewErrorResponse(child.Path, err))
+			}
 
-		resps = make([]internal.Response, len(children))
-		for i, child := range children {
-			resp, err := b.propFindFile(propfind, &child)
+			resp, err := b.propFindFile(r.Context(), propfind, child)
 			if err != nil {
-				return nil, err
+				resp = internal.NewErrorResponse(child.Path, err)
 			}
-			resps[i] = *resp
-		}
-	} else {
-		resp, err := b.propFindFile(propfind, fi)
-		if err != nil {
-			return nil, err
-		}
+			return mw.WriteResponse(resp)
+		})
+	}
 
-		resps = []internal.Response{*resp}
+	resp, err := b.propFindFile(r.Context(), propfind, fi)
+	if err != nil {
+		return err
+	}
+	return mw.WriteResponse(resp)
+}
+
+// walkDir lists a directory with DirWalker if supported, and falls back to
+// ReadDir otherwise.
+func walkDir(ctx context.Context, fs FileSystem, name string, recursive bool, fn func(fi *FileInfo) error) error {
+	if walker, ok := fs.(DirWalker); ok {
+		return walker.WalkDir(ctx, name, recursive, fn)
 	}
 
-	return internal.NewMultiStatus(resps...), nil
+	l, err := fs.ReadDir(ctx, name, recursive)
+	if _, ok := internal.HrefErrors(err); err != nil && !ok {
+		return err
+	}
+	for i := range l {
+		if err := fn(&l[i]); err != nil {
+			return err
+		}
+	}
+	return err
 }
 
-func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*internal.Response, error) {
+func (b *backend) propFindFile(ctx context.Context, propfind *internal.PropFind, fi *FileInfo) (*internal.Response, error) {
 	props := make(map[xml.Name]internal.PropFindFunc)
 
+	if b.LockSystem != nil {
+		props[internal.SupportedLockName] = internal.PropFindValue(supportedLock)
+		props[internal.LockDiscoveryName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return b.lockDiscovery(ctx, fi.Path)
+		}
+	}
+
 	props[internal.ResourceTypeName] = func(*internal.RawXMLValue) (interface{}, error) {
 		var types []xml.Name
 		if fi.IsDir {
@@ -185,54 +339,225 @@ func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*inte
 		}
 	}
 
+	// These properties aren't returned for allprop: the access control
+	// properties of RFC 3744 section 5, the quota properties of RFC 4331
+	// section 3 and the sync token of RFC 6578 section 4
+	if propfind.AllProp == nil {
+		if b.Authorizer != nil {
+			b.aclProps(ctx, props, fi.Path)
+		}
+		if qfs, ok := b.FileSystem.(QuotaFileSystem); ok && fi.IsDir {
+			b.quotaProps(ctx, props, qfs, fi.Path)
+		}
+		if ct, ok := b.FileSystem.(ChangeTracker); ok && fi.IsDir {
+			props[internal.SyncTokenName] = func(*internal.RawXMLValue) (interface{}, error) {
+				token, err := ct.SyncToken(ctx, fi.Path)
+				if err != nil {
+					return nil, err
+				}
+				return &internal.SyncToken{Token: token}, nil
+			}
+		}
+	}
+
+	if store, ok := b.FileSystem.(PropertyStore); ok {
+		deadProps, err := store.Properties(ctx, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+		for i := range deadProps {
+			prop := &deadProps[i]
+			if _, ok := props[prop.XMLName]; !ok {
+				props[prop.XMLName] = internal.PropFindValue(prop)
+			}
+		}
+	}
+
 	return internal.NewPropFindResponse(fi.Path, propfind, props)
 }
 
-func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
-	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
+// quotaProps adds RFC 4331 properties to a PROPFIND response.
+func (b *backend) quotaProps(ctx context.Context, props map[xml.Name]internal.PropFindFunc, qfs QuotaFileSystem, name string) {
+	props[internal.QuotaAvailableBytesName] = func(*internal.RawXMLValue) (interface{}, error) {
+		available, err := qfs.AvailableBytes(ctx, name)
+		if err != nil {
+			return nil, err
+		} else if available < 0 {
+			return nil, internal.HTTPErrorf(http.StatusNotFound, "webdav: available storage unknown")
+		}
+		return &internal.QuotaAvailableBytes{Bytes: available}, nil
+	}
+	props[internal.QuotaUsedBytesName] = func(*internal.RawXMLValue) (interface{}, error) {
+		quota, err := qfs.Quota(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return &internal.QuotaUsedBytes{Bytes: quota.Used}, nil
+	}
+}
+
+// serveReport dispatches a REPORT request on its root element. Only the
+// sync-collection report is supported.
+func (b *backend) serveReport(w http.ResponseWriter, r *http.Request, ct ChangeTracker) error {
+	var report reportReq
+	if err := internal.DecodeXMLRequest(r, &report); err != nil {
+		return err
+	}
+
+	if report.SyncCollection != nil {
+		return b.serveSyncCollection(w, r, ct, report.SyncCollection)
+	}
+	return internal.NewConditionError(http.StatusForbidden, internal.SupportedReportName)
+}
+
+// serveSyncCollection replies to a sync-collection report, defined in
+// RFC 6578 section 3.
+func (b *backend) serveSyncCollection(w http.ResponseWriter, r *http.Request, ct ChangeTracker, query *internal.SyncCollectionQuery) error {
+	if err := internal.CheckSyncCollectionDepth(r.Header); err != nil {
+		return err
+	}
+
+	recursive, err := query.Recursive()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	resp := &internal.Response{Hrefs: []internal.Href{internal.Href{Path: fi.Path}}}
+	changes, token, err := ct.Changes(r.Context(), r.URL.Path, query.SyncToken, recursive)
+	if errors.Is(err, ErrInvalidSyncToken) {
+		return internal.NewConditionError(http.StatusForbidden, internal.ValidSyncTokenName)
+	} else if err != nil {
+		return err
+	}
 
-	for _, set := range update.Set {
-		for _, raw := range set.Prop.Raw {
-			xmlName, ok := raw.XMLName()
-			if !ok {
-				continue
-			}
+	if err := query.CheckLimit(len(changes)); err != nil {
+		return err
+	}
+
+	propfind := &internal.PropFind{Prop: query.Prop}
+	var (
+		resps   []internal.Response
+		deleted []string
+	)
+	for _, change := range changes {
+		if change.FileInfo == nil {
+			deleted = append(deleted, change.Path)
+			continue
+		}
 
-			emptyVal := internal.NewRawXMLElement(xmlName, nil, nil)
+		if err := b.authorizeRead(r.Context(), change.Path); err != nil {
+			resps = append(resps, *internal.NewErrorResponse(change.Path, err))
+			continue
+		}
 
-			if err := resp.EncodeProp(http.StatusForbidden, emptyVal); err != nil {
-				return nil, err
-			}
+		resp, err := b.propFindFile(r.Context(), propfind, change.FileInfo)
+		if err != nil {
+			resp = internal.NewErrorResponse(change.Path, err)
 		}
+		resps = append(resps, *resp)
+	}
+
+	ms := internal.NewSyncCollectionMultiStatus(r.URL.Path, token, resps, deleted, false)
+	return internal.ServeMultiStatus(w, ms)
+}
+
+// protectedProps contains the live properties computed by the server, which
+// cannot be altered by clients.
+var protectedProps = map[xml.Name]bool{
+	internal.ResourceTypeName:     true,
+	internal.GetContentLengthName: true,
+	internal.GetContentTypeName:   true,
+	internal.GetLastModifiedName:  true,
+	internal.GetETagName:          true,
+	internal.LockDiscoveryName:    true,
+	internal.SupportedLockName:    true,
+
+	internal.CurrentUserPrivilegeSetName: true,
+	internal.ACLName:                     true,
+	internal.OwnerName:                   true,
+
+	internal.QuotaAvailableBytesName: true,
+	internal.QuotaUsedBytesName:      true,
+
+	internal.SyncTokenName: true,
+}
+
+func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
+	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
+	if err != nil {
+		return nil, err
+	}
 
+	if err := b.confirmLocks(r, r.URL.Path, false, false); err != nil {
+		return nil, err
 	}
 
-	for _, remove := range update.Remove {
-		for _, raw := range remove.Prop.Raw {
+	store, _ := b.FileSystem.(PropertyStore)
+
+	var (
+		names     []xml.Name
+		forbidden = make(map[xml.Name]bool)
+		patch     []PropertyPatch
+	)
+	for _, inst := range update.Instructions {
+		var remove bool
+		switch inst.XMLName {
+		case internal.SetName:
+			remove = false
+		case internal.RemoveName:
+			remove = true
+		default:
+			return nil, internal.HTTPErrorf(http.StatusBadRequest, "webdav: unexpected element %q %q in propertyupdate", inst.XMLName.Space, inst.XMLName.Local)
+		}
+
+		for _, raw := range inst.Prop.Raw {
 			xmlName, ok := raw.XMLName()
 			if !ok {
 				continue
 			}
+			names = append(names, xmlName)
+			if store == nil || protectedProps[xmlName] {
+				forbidden[xmlName] = true
+				continue
+			}
 
-			emptyVal := internal.NewRawXMLElement(xmlName, nil, nil)
-
-			if err := resp.EncodeProp(http.StatusForbidden, emptyVal); err != nil {
-				return nil, err
+			prop := Property{XMLName: xmlName}
+			if !remove {
+				inner, err := raw.InnerXML()
+				if err != nil {
+					return nil, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+				}
+				prop.InnerXML = inner
 			}
+			patch = append(patch, PropertyPatch{Remove: remove, Property: prop})
 		}
-
 	}
 
-	if len(resp.PropStats) == 0 {
+	if len(names) == 0 {
 		return nil, internal.HTTPErrorf(http.StatusBadRequest,
 			"webdav: request missing properties to update")
 	}
 
+	// PROPPATCH is atomic: if any instruction fails, none is applied
+	code := http.StatusOK
+	if len(forbidden) > 0 {
+		code = http.StatusFailedDependency
+	} else if err := store.PatchProperties(r.Context(), fi.Path, patch); err != nil {
+		return nil, err
+	}
+
+	resp := &internal.Response{Hrefs: []internal.Href{internal.Href{Path: fi.Path}}}
+	for _, xmlName := range names {
+		propCode := code
+		if forbidden[xmlName] {
+			propCode = http.StatusForbidden
+		}
+
+		emptyVal := internal.NewRawXMLElement(xmlName, nil, nil)
+		if err := resp.EncodeProp(propCode, emptyVal); err != nil {
+			return nil, err
+		}
+	}
+
 	return resp, nil
 }
 
@@ -241,14 +566,34 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 	ifMatch := ConditionalMatch(r.Header.Get("If-Match"))
 
 	opts := CreateOptions{
-		IfNoneMatch: ifNoneMatch,
-		IfMatch:     ifMatch,
+		IfNoneMatch:   ifNoneMatch,
+		IfMatch:       ifMatch,
+		ContentType:   r.Header.Get("Content-Type"),
+		ContentLength: r.ContentLength,
+	}
+
+	if err := b.confirmLocks(r, r.URL.Path, false, true); err != nil {
+		return err
+	}
+
+	if s := r.Header.Get("Content-Range"); s != "" {
+		return b.putRange(w, r, s, &opts)
+	}
+
+	if err := b.checkQuota(r, r.ContentLength, true); err != nil {
+		return err
 	}
+
 	fi, created, err := b.FileSystem.Create(r.Context(), r.URL.Path, r.Body, &opts)
 	if err != nil {
 		return err
 	}
 
+	writePutResponse(w, fi, created)
+	return nil
+}
+
+func writePutResponse(w http.ResponseWriter, fi *FileInfo, created bool) {
 	if fi.MIMEType != "" {
 		w.Header().Set("Content-Type", fi.MIMEType)
 	}
@@ -264,10 +609,102 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 	} else {
 		w.WriteHeader(http.StatusNoContent)
 	}
+}
+
+// putRange handles a partial PUT request, which appends a chunk to a pending
+// upload. Once the last chunk is received, the upload is committed and the
+// reply is the same as for a regular PUT. Otherwise, the reply is
+// "202 Accepted" with a Range header indicating the bytes received so far.
+//
+// A request with an unsatisfied range ("bytes */length") and an empty body
+// queries the state of the pending upload, and commits it if complete.
+func (b *backend) putRange(w http.ResponseWriter, r *http.Request, s string, opts *CreateOptions) error {
+	ufs, ok := b.FileSystem.(UploadFileSystem)
+	if !ok {
+		// RFC 9110 section 14.5: servers which don't support partial PUT
+		// must reject requests with a Content-Range header
+		return internal.HTTPErrorf(http.StatusBadRequest, "webdav: partial PUT unsupported")
+	}
+
+	cr, err := internal.ParseContentRange(s)
+	if err != nil {
+		return &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+	}
+
+	var size int64
+	if cr.First < 0 {
+		size, err = ufs.UploadSize(r.Context(), r.URL.Path)
+		if internal.IsNotFound(err) && cr.Length == 0 {
+			// Empty files don't need any chunk
+			size, err = ufs.WriteUpload(r.Context(), r.URL.Path, 0, http.NoBody)
+		} else if internal.IsNotFound(err) {
+			size, err = 0, nil
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		n := cr.Last - cr.First + 1
+		if r.ContentLength >= 0 && r.ContentLength != n {
+			return internal.HTTPErrorf(http.StatusBadRequest, "webdav: Content-Length doesn't match Content-Range")
+		}
+		// The file being overwritten is only replaced once the upload is
+		// committed, so it still uses space
+		if err := b.checkQuota(r, n, false); err != nil {
+			return err
+		}
+		size, err = ufs.WriteUpload(r.Context(), r.URL.Path, cr.First, io.LimitReader(r.Body, n))
+		if err != nil {
+			return err
+		} else if size != cr.Last+1 {
+			return internal.HTTPErrorf(http.StatusBadRequest, "webdav: request body shorter than Content-Range")
+		}
+	}
+
+	if size == cr.Length {
+		opts.ContentLength = size
+		fi, created, err := ufs.CommitUpload(r.Context(), r.URL.Path, opts)
+		if err != nil {
+			return err
+		}
+		writePutResponse(w, fi, created)
+		return nil
+	}
 
+	if size > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%v", size-1))
+	}
+	w.WriteHeader(http.StatusAccepted)
 	return nil
 }
 
+// checkQuota rejects a PUT request early if size bytes don't fit in the quota
+// of the parent collection. If replace is set, the space used by the file
+// being overwritten is considered freed. Uploads of unknown length are left
+// to the FileSystem.
+func (b *backend) checkQuota(r *http.Request, size int64, replace bool) error {
+	qfs, ok := b.FileSystem.(QuotaFileSystem)
+	if !ok || size <= 0 {
+		return nil
+	}
+
+	// Errors (e.g. a missing parent) are reported by Create
+	available, err := qfs.AvailableBytes(r.Context(), path.Dir(path.Clean(r.URL.Path)))
+	if err != nil || available < 0 {
+		return nil
+	}
+
+	if replace {
+		if fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path); err == nil && !fi.IsDir {
+			available += fi.Size
+		}
+	}
+	if size <= available {
+		return nil
+	}
+	return internal.NewConditionError(http.StatusInsufficientStorage, xml.Name{internal.Namespace, "quota-not-exceeded"})
+}
+
 func (b *backend) Delete(r *http.Request) error {
 	ifNoneMatch := ConditionalMatch(r.Header.Get("If-None-Match"))
 	ifMatch := ConditionalMatch(r.Header.Get("If-Match"))
@@ -276,13 +713,24 @@ func (b *backend) Delete(r *http.Request) error {
 		IfNoneMatch: ifNoneMatch,
 		IfMatch:     ifMatch,
 	}
-	return b.FileSystem.RemoveAll(r.Context(), r.URL.Path, &opts)
+
+	if err := b.confirmLocks(r, r.URL.Path, true, true); err != nil {
+		return err
+	}
+
+	if err := b.FileSystem.RemoveAll(r.Context(), r.URL.Path, &opts); err != nil {
+		return err
+	}
+	return b.releaseLocks(r, r.URL.Path)
 }
 
 func (b *backend) Mkcol(r *http.Request) error {
 	if r.Header.Get("Content-Type") != "" {
 		return internal.HTTPErrorf(http.StatusUnsupportedMediaType, "webdav: request body not supported in MKCOL request")
 	}
+	if err := b.confirmLocks(r, r.URL.Path, false, true); err != nil {
+		return err
+	}
 	err := b.FileSystem.Mkdir(r.Context(), r.URL.Path)
 	if internal.IsNotFound(err) {
 		return &internal.HTTPError{Code: http.StatusConflict, Err: err}
@@ -295,6 +743,9 @@ func (b *backend) Copy(r *http.Request, dest *internal.Href, recursive, overwrit
 		NoRecursive: !recursive,
 		NoOverwrite: !overwrite,
 	}
+	if err := b.confirmLocks(r, dest.Path, true, true); err != nil {
+		return false, err
+	}
 	created, err = b.FileSystem.Copy(r.Context(), r.URL.Path, dest.Path, &options)
 	if os.IsExist(err) {
 		return false, &internal.HTTPError{http.StatusPreconditionFailed, err}
@@ -306,11 +757,22 @@ func (b *backend) Move(r *http.Request, dest *internal.Href, overwrite bool) (cr
 	options := MoveOptions{
 		NoOverwrite: !overwrite,
 	}
+	if err := b.confirmLocks(r, r.URL.Path, true, true); err != nil {
+		return false, err
+	}
+	if err := b.confirmLocks(r, dest.Path, true, true); err != nil {
+		return false, err
+	}
 	created, err = b.FileSystem.Move(r.Context(), r.URL.Path, dest.Path, &options)
 	if os.IsExist(err) {
 		return false, &internal.HTTPError{http.StatusPreconditionFailed, err}
+	} else if err != nil {
+		return false, err
 	}
-	return created, err
+	if err := b.releaseLocks(r, r.URL.Path); err != nil {
+		return false, err
+	}
+	return created, nil
 }
 
 // BackendSuppliedHomeSet represents either a CalDAV calendar-home-set or a
@@ -333,6 +795,8 @@ type Capability string
 
 // ServePrincipalOptions holds options for ServePrincipal.
 type ServePrincipalOptions struct {
+	// CurrentUserPrincipalPath is the path of the current user's principal.
+	// If empty, PrincipalFromContext is used.
 	CurrentUserPrincipalPath string
 	HomeSets                 []BackendSuppliedHomeSet
 	Capabilities             []Capability
@@ -364,10 +828,14 @@ func servePrincipalPropfind(w http.ResponseWriter, r *http.Request, options *Ser
 	if err := internal.DecodeXMLRequest(r, &propfind); err != nil {
 		return err
 	}
+	principalPath := options.CurrentUserPrincipalPath
+	if principalPath == "" {
+		principalPath = PrincipalFromContext(r.Context())
+	}
 	props := map[xml.Name]internal.PropFindFunc{
 		internal.ResourceTypeName: internal.PropFindValue(internal.NewResourceType(internal.PrincipalName)),
 		internal.CurrentUserPrincipalName: internal.PropFindValue(&internal.CurrentUserPrincipal{
-			Href: internal.Href{Path: options.CurrentUserPrincipalPath},
+			Href: internal.Href{Path: principalPath},
 		}),
 	}
 
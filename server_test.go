@@ -0,0 +1,172 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type brokenPropsFileSystem struct {
+	*MemFileSystem
+	broken string
+}
+
+func (fs brokenPropsFileSystem) Properties(ctx context.Context, name string) ([]Property, error) {
+	if name == fs.broken {
+		return nil, NewHTTPError(http.StatusForbidden, nil)
+	}
+	return fs.MemFileSystem.Properties(ctx, name)
+}
+
+func TestPropFind_partialError(t *testing.T) {
+	mem := NewMemFileSystem()
+	for _, name := range []string{"/a.txt", "/b.txt"} {
+		if _, _, err := mem.Create(context.Background(), name, io.NopCloser(strings.NewReader("")), &CreateOptions{}); err != nil {
+			t.Fatalf("Create() = %v", err)
+		}
+	}
+	h := &Handler{FileSystem: brokenPropsFileSystem{mem, "/a.txt"}}
+
+	res := doTestRequest(h, "PROPFIND", "/", propFindAllProp, map[string]string{"Depth": "1"})
+	if res.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND: status = %v, want %v", res.StatusCode, http.StatusMultiStatus)
+	}
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "403 Forbidden") || !strings.Contains(body, "<href>/b.txt</href>") {
+		t.Errorf("PROPFIND: body = %v", body)
+	}
+}
+
+const propFindQuota = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:quota-available-bytes/><D:quota-used-bytes/></D:prop></D:propfind>`
+
+func TestQuota(t *testing.T) {
+	fs := NewMemFileSystem()
+	fs.MaxSize = 10
+	h := &Handler{FileSystem: fs}
+
+	res := doTestRequest(h, http.MethodPut, "/a.txt", "hello", nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/", propFindQuota, map[string]string{"Depth": "0"})
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "<quota-available-bytes xmlns=\"DAV:\">5</quota-available-bytes>") || !strings.Contains(body, "<quota-used-bytes xmlns=\"DAV:\">5</quota-used-bytes>") {
+		t.Errorf("PROPFIND: body = %v", body)
+	}
+
+	res = doTestRequest(h, "PROPFIND", "/", propFindAllProp, map[string]string{"Depth": "0"})
+	if body := readTestBody(t, res); strings.Contains(body, "quota") {
+		t.Errorf("PROPFIND allprop: body = %v", body)
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/b.txt", "too large", nil)
+	if res.StatusCode != http.StatusInsufficientStorage {
+		t.Fatalf("PUT: status = %v, want %v", res.StatusCode, http.StatusInsufficientStorage)
+	}
+	if body := readTestBody(t, res); !strings.Contains(body, "quota-not-exceeded") {
+		t.Errorf("PUT: body = %v", body)
+	}
+
+	res = doTestRequest(h, http.MethodPut, "/a.txt", "overwrite!", nil)
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("PUT overwrite: status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+
+	// Pending uploads use space too
+	res = doTestRequest(h, http.MethodPut, "/c.txt", "", map[string]string{"Content-Range": "bytes */0"})
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("PUT empty range: status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	if err := fs.RemoveAll(context.Background(), "/a.txt", &RemoveAllOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	res = doTestRequest(h, http.MethodPut, "/d.txt", "hello", map[string]string{"Content-Range": "bytes 0-4/11"})
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("PUT first chunk: status = %v, want %v", res.StatusCode, http.StatusAccepted)
+	}
+	res = doTestRequest(h, http.MethodPut, "/e.txt", "world!", nil)
+	if res.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("PUT with pending upload: status = %v, want %v", res.StatusCode, http.StatusInsufficientStorage)
+	}
+	res = doTestRequest(h, http.MethodPut, "/d.txt", " world", map[string]string{"Content-Range": "bytes 5-10/11"})
+	if res.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("PUT last chunk: status = %v, want %v", res.StatusCode, http.StatusInsufficientStorage)
+	}
+}
+
+// noUsageFileSystem reports when the used space is computed, which only
+// PROPFIND needs.
+type noUsageFileSystem struct {
+	*MemFileSystem
+	t *testing.T
+}
+
+func (fs noUsageFileSystem) Quota(ctx context.Context, name string) (*Quota, error) {
+	fs.t.Errorf("Quota(%q) called", name)
+	return fs.MemFileSystem.Quota(ctx, name)
+}
+
+func TestQuota_put(t *testing.T) {
+	fs := NewMemFileSystem()
+	fs.MaxSize = 4
+	h := &Handler{FileSystem: noUsageFileSystem{fs, t}}
+
+	res := doTestRequest(h, http.MethodPut, "/a.txt", "hello", nil)
+	if res.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("PUT: status = %v, want %v", res.StatusCode, http.StatusInsufficientStorage)
+	}
+}
+
+const syncCollectionInitial = `<?xml version="1.0" encoding="utf-8" ?>
+<D:sync-collection xmlns:D="DAV:">
+  <D:sync-token/>
+  <D:sync-level>1</D:sync-level>
+  <D:prop><D:getetag/></D:prop>
+</D:sync-collection>`
+
+func TestSyncCollection(t *testing.T) {
+	ctx := context.Background()
+	fs := NewMemFileSystem()
+	for _, name := range []string{"/public.txt", "/secret.txt"} {
+		if _, _, err := fs.Create(ctx, name, io.NopCloser(strings.NewReader("")), &CreateOptions{}); err != nil {
+			t.Fatalf("Create() = %v", err)
+		}
+	}
+	az := NewMemAuthorizer()
+	az.SetACL(ctx, "/", []ACE{
+		{Principal: PrincipalAll, Privileges: []xml.Name{PrivilegeRead}},
+	})
+	az.SetACL(ctx, "/secret.txt", []ACE{
+		{Principal: PrincipalAll, Deny: true, Privileges: []xml.Name{PrivilegeRead}},
+	})
+	h := &Handler{FileSystem: fs, Authorizer: az}
+
+	res := doTestRequest(h, "REPORT", "/", syncCollectionInitial, map[string]string{"Depth": "0"})
+	if res.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("REPORT: status = %v, want %v", res.StatusCode, http.StatusMultiStatus)
+	}
+	body := readTestBody(t, res)
+	if !strings.Contains(body, "<href>/public.txt</href>") || !strings.Contains(body, "<getetag") {
+		t.Errorf("REPORT: body = %v", body)
+	}
+	for _, resp := range strings.Split(body, "</response>") {
+		if strings.Contains(resp, "<href>/secret.txt</href>") && (strings.Contains(resp, "<getetag") || !strings.Contains(resp, "403 Forbidden")) {
+			t.Errorf("REPORT: response = %v, want 403 for /secret.txt", resp)
+		}
+	}
+
+	res = doTestRequest(h, "REPORT", "/", syncCollectionInitial, map[string]string{"Depth": "1"})
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("REPORT with Depth: 1: status = %v, want %v", res.StatusCode, http.StatusBadRequest)
+	}
+
+	res = doTestRequest(h, "REPORT", "/", `<D:expand-property xmlns:D="DAV:"/>`, nil)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("unsupported REPORT: status = %v, want %v", res.StatusCode, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package webdav
+
+import (
+	"os"
+)
+
+func statfsAvailable(p string) (int64, error) {
+	if _, err := os.Stat(p); err != nil {
+		return 0, err
+	}
+	return -1, nil
+}
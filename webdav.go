@@ -4,6 +4,8 @@
 package webdav
 
 import (
+	"encoding/xml"
+	"errors"
 	"time"
 
 	"github.com/emersion/go-webdav/internal"
@@ -19,9 +21,55 @@ type FileInfo struct {
 	ETag     string
 }
 
+// Property is a dead WebDAV property, as set by a client via PROPPATCH.
+type Property struct {
+	XMLName xml.Name
+	// InnerXML is the raw XML content of the property element. Namespaces
+	// must be declared within the value itself.
+	InnerXML string `xml:",innerxml"`
+}
+
+// PropertyPatch is an instruction of a PROPPATCH request, which either sets
+// or removes a dead property.
+type PropertyPatch struct {
+	// Remove is set if the property is removed. Only the XMLName of Property
+	// is used then.
+	Remove   bool
+	Property Property
+}
+
+// Quota describes the storage space of a collection, as defined in RFC 4331.
+type Quota struct {
+	// Available is the number of bytes which can still be stored, or a
+	// negative value if unknown.
+	Available int64
+	// Used is the number of bytes already stored.
+	Used int64
+}
+
+// Change describes a member of a collection which has been created, updated
+// or removed, as reported by a sync-collection report (RFC 6578).
+type Change struct {
+	Path string
+	// FileInfo is the current state of the member, or nil if it has been
+	// removed.
+	FileInfo *FileInfo
+}
+
+// ErrInvalidSyncToken is wrapped by errors caused by a sync token which is
+// invalid or which has expired. Clients need to start over with an empty
+// token.
+var ErrInvalidSyncToken = errors.New("webdav: invalid sync token")
+
 type CreateOptions struct {
 	IfMatch     ConditionalMatch
 	IfNoneMatch ConditionalMatch
+
+	// ContentType is the media type of the file, if known.
+	ContentType string
+	// ContentLength is the size of the file in bytes. A zero or negative
+	// value means that the size is unknown.
+	ContentLength int64
 }
 
 type RemoveAllOptions struct {
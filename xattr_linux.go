@@ -0,0 +1,49 @@
+//go:build linux
+// +build linux
+
+package webdav
+
+import (
+	"errors"
+	"syscall"
+)
+
+func getXattr(path, attr string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, attr, nil)
+		if err != nil {
+			return nil, xattrErr(err)
+		}
+		buf := make([]byte, size)
+		size, err = syscall.Getxattr(path, attr, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue // attribute grew in the meantime
+		} else if err != nil {
+			return nil, xattrErr(err)
+		}
+		return buf[:size], nil
+	}
+}
+
+func setXattr(path, attr string, data []byte) error {
+	return xattrErr(syscall.Setxattr(path, attr, data, 0))
+}
+
+func removeXattr(path, attr string) error {
+	return xattrErr(syscall.Removexattr(path, attr))
+}
+
+func xattrErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ENODATA):
+		return errXattrNotExist
+	case errors.Is(err, syscall.ENOTSUP), errors.Is(err, syscall.E2BIG), errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.ERANGE):
+		// Either xattrs aren't supported by the filesystem, or the value
+		// doesn't fit
+		return errXattrUnsupported
+	default:
+		return err
+	}
+}
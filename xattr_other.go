@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package webdav
+
+func getXattr(path, attr string) ([]byte, error) {
+	return nil, errXattrUnsupported
+}
+
+func setXattr(path, attr string, data []byte) error {
+	return errXattrUnsupported
+}
+
+func removeXattr(path, attr string) error {
+	return errXattrUnsupported
+}